@@ -0,0 +1,292 @@
+package app
+
+import (
+	"fmt"
+	"strings"
+	"time"
+	"github.com/gdamore/tcell/v2"
+	"github.com/Rye123/notepad--/search"
+	"github.com/Rye123/notepad--/textbuffer"
+)
+
+// The current search, kept after the find bar is closed so that it can be repeated with Find Next.
+type findState struct {
+	query string
+	options search.Options
+	matches []search.Match // Matches of the query, kept up to date as the buffer changes while the find bar is open
+	origin int // Cursor index when the find bar was opened: searching as you type finds the first match from here
+	stale bool // True if the buffer has changed since the matches were searched for, so some may be missing
+	refresh *time.Timer // Searches again once typing pauses, for a query that can span any number of lines
+}
+
+// How long after the last change the whole buffer is searched again, for a query that can span any number of lines.
+const FIND_REFRESH_DELAY = 300 * time.Millisecond
+
+// Posted to the screen when the matches are to be searched for again.
+type findRefresh struct{}
+
+// Shows `message` in the status bar, if there is one.
+func (ui *UI) setStatusMessage(message string) {
+	if statusbar := ui.statusbar(); statusbar != nil {
+		statusbar.SetMessage(message)
+	}
+}
+
+// Returns true if the find bar is open.
+func (ui *UI) findOpen() bool {
+	findbar := ui.findbar()
+	return findbar != nil && !findbar.IsHidden()
+}
+
+// Opens the find bar, starting with the selected text as the query if it is a single line, and highlights the matches.
+func (ui *UI) Find() {
+	ui.openFindBar(false)
+}
+
+// Opens the find bar with the replace row.
+func (ui *UI) Replace() {
+	ui.openFindBar(true)
+}
+
+func (ui *UI) openFindBar(replacing bool) {
+	findbar, textbox := ui.findbar(), ui.textbox()
+	if findbar == nil {
+		return
+	}
+
+	ui.find.origin = textbox.GetCursorIndex()
+	if start, _, ok := textbox.Selection(); ok {
+		ui.find.origin = start
+		if selected := textbox.SelectedText(); !strings.Contains(selected, "\n") {
+			findbar.SetQuery(selected)
+		}
+	}
+	findbar.SetReplacing(replacing, findbar.Query() != "")
+	findbar.Show()
+	textbox.SetBottomRows(findbar.Rows())
+	ui.focusFindBar()
+	ui.searchAsYouType(findbar.Query(), findbar.Options())
+	ui.redraw()
+}
+
+// Closes the find bar, removing the highlights, and returns to the textbox.
+func (ui *UI) closeFind() {
+	findbar, textbox := ui.findbar(), ui.textbox()
+	findbar.Hide()
+	textbox.SetBottomRows(0)
+	textbox.SetHighlights(nil)
+	ui.setStatusMessage("")
+	ui.focusTextbox()
+	ui.redraw()
+}
+
+// Searches for `query` as it is typed into the find bar, selecting the first match from where the search started.
+func (ui *UI) searchAsYouType(query string, options search.Options) {
+	ui.find.query, ui.find.options = query, options
+	err := ui.updateMatches()
+	textbox := ui.textbox()
+	next, _ := search.Next(ui.find.matches, ui.find.origin)
+	if next >= 0 {
+		ui.selectMatch(next, "")
+		return
+	}
+
+	textbox.ClearSelection()
+	textbox.SetCursorIndex(ui.find.origin)
+	switch {
+	case err != nil:
+		ui.setStatusMessage(err.Error())
+	case query == "":
+		ui.setStatusMessage("")
+	default:
+		ui.setStatusMessage("No matches")
+	}
+}
+
+// Compiles the current query. Returns an error if it is an invalid regular expression.
+func (ui *UI) findPattern() (*search.Pattern, error) {
+	return search.Compile(ui.find.query, ui.find.options)
+}
+
+// Searches the buffer for the current query again, highlighting the matches if the find bar is open.
+// Returns an error if the query is an invalid regular expression, in which case there are no matches.
+func (ui *UI) updateMatches() error {
+	pattern, err := ui.findPattern()
+	ui.find.matches, ui.find.stale = nil, false
+	if err == nil {
+		ui.find.matches = pattern.FindAll(ui.appstate.TextBuffer)
+	}
+	if ui.findOpen() {
+		ui.textbox().SetHighlights(ui.find.matches)
+	}
+	return err
+}
+
+// Moves the matches along with `change` to the buffer, so that the highlights stay on the same text, and searches the changed lines again if the find bar is open.
+// While the find bar is closed, the buffer is searched again by Find Next instead. If the query can span any number of lines, e.g. a regular expression with `.` in single-line mode, it is searched once typing pauses.
+func (ui *UI) bufferChanged(change textbuffer.Change) {
+	ui.find.matches = search.ShiftMatches(ui.find.matches, change)
+	if !ui.findOpen() {
+		ui.find.stale = true
+		return
+	}
+
+	pattern, err := ui.findPattern()
+	switch {
+	case err != nil || ui.find.query == "":
+	case pattern.Unbounded():
+		ui.find.stale = true
+		ui.scheduleRefresh()
+	default:
+		from, to := pattern.SearchRange(ui.appstate.TextBuffer, change.Index, change.InsertedEnd())
+		ui.find.matches = search.SpliceMatches(ui.find.matches, from, to, pattern.FindAllInRange(ui.appstate.TextBuffer, from, to))
+	}
+	ui.textbox().SetHighlights(ui.find.matches)
+}
+
+// Posts a findRefresh to the screen FIND_REFRESH_DELAY from now, putting off the one already scheduled.
+func (ui *UI) scheduleRefresh() {
+	if ui.find.refresh != nil {
+		ui.find.refresh.Reset(FIND_REFRESH_DELAY)
+		return
+	}
+	screen := ui.appstate.Screen
+	ui.find.refresh = time.AfterFunc(FIND_REFRESH_DELAY, func() {
+		screen.PostEvent(tcell.NewEventInterrupt(findRefresh{}))
+	})
+}
+
+// Searches the buffer again if it has changed while the find bar is open.
+func (ui *UI) refreshMatches() {
+	if ui.find.stale && ui.findOpen() {
+		ui.updateMatches()
+	}
+}
+
+// Selects the match at `index` in the textbox, and shows which match it is in the status bar, followed by `notice`.
+func (ui *UI) selectMatch(index int, notice string) {
+	match := ui.find.matches[index]
+	ui.textbox().Select(match.Start, match.End)
+	message := fmt.Sprintf("Match %d of %d", index + 1, len(ui.find.matches))
+	if notice != "" {
+		message += " (" + notice + ")"
+	}
+	ui.setStatusMessage(message)
+}
+
+// Selects the next match after the cursor, wrapping around to the start of the file. Opens the find bar if there is nothing to find.
+func (ui *UI) FindNext() {
+	ui.findAgain(false)
+}
+
+// Selects the previous match before the cursor, wrapping around to the end of the file. Opens the find bar if there is nothing to find.
+func (ui *UI) FindPrevious() {
+	ui.findAgain(true)
+}
+
+func (ui *UI) findAgain(backwards bool) {
+	if ui.find.query == "" {
+		ui.Find()
+		return
+	}
+	if ui.find.stale {
+		ui.updateMatches()
+	}
+	if _, err := ui.findPattern(); err != nil {
+		ui.setStatusMessage(err.Error())
+		return
+	}
+
+	textbox := ui.textbox()
+	start, end := textbox.GetCursorIndex(), textbox.GetCursorIndex()
+	if selStart, selEnd, ok := textbox.Selection(); ok {
+		start, end = selStart, selEnd
+	}
+
+	var index int
+	var wrapped bool
+	notice := "passed the end of the file, continued from the start"
+	if backwards {
+		index, wrapped = search.Previous(ui.find.matches, start)
+		notice = "passed the start of the file, continued from the end"
+	} else {
+		index, wrapped = search.Next(ui.find.matches, end)
+	}
+
+	if index < 0 {
+		ui.setStatusMessage(fmt.Sprintf("Cannot find \"%v\"", ui.find.query))
+		return
+	}
+	if !wrapped {
+		notice = ""
+	}
+	ui.selectMatch(index, notice)
+}
+
+// Replaces the selected match with the replacement in the find bar, then selects the next match. If no match is selected, the next match is only selected.
+func (ui *UI) ReplaceNext() {
+	findbar, textbox := ui.findbar(), ui.textbox()
+	if ui.appstate.ReadOnly {
+		ui.setStatusMessage("Cannot replace in a read-only file")
+		return
+	}
+	if ui.find.query == "" {
+		ui.Replace()
+		return
+	}
+	pattern, err := ui.findPattern()
+	if err != nil {
+		ui.setStatusMessage(err.Error())
+		return
+	}
+
+	// Only the lines around the selection are searched, to expand the replacement of the selected match
+	if start, end, ok := textbox.Selection(); ok {
+		from, to := pattern.SearchRange(ui.appstate.TextBuffer, start, end)
+		for _, replacement := range(pattern.ReplacementsInRange(ui.appstate.TextBuffer, from, to, findbar.Replacement())) {
+			if replacement.Start == start && replacement.End == end {
+				textbox.ReplaceRange(start, end, replacement.Text)
+				break
+			}
+		}
+	}
+	ui.findAgain(false)
+}
+
+// Replaces every match with the replacement in the find bar as a single undoable edit, after confirming how many matches will be replaced.
+func (ui *UI) ReplaceAll() {
+	findbar, textbox := ui.findbar(), ui.textbox()
+	if ui.appstate.ReadOnly {
+		ui.setStatusMessage("Cannot replace in a read-only file")
+		return
+	}
+	pattern, err := ui.findPattern()
+	if err != nil {
+		ui.setStatusMessage(err.Error())
+		return
+	}
+
+	text := ui.appstate.TextBuffer.String()
+	replacements := pattern.ReplacementsInText(text, findbar.Replacement())
+	if ui.find.query == "" || len(replacements) == 0 {
+		ui.setStatusMessage("No matches")
+		return
+	}
+	count := countString(len(replacements), "match", "matches")
+	if !ui.promptConfirm("Replace All", fmt.Sprintf("Replace %v of \"%v\" with \"%v\"?", count, ui.find.query, findbar.Replacement())) {
+		return
+	}
+
+	start, end, result := search.Apply(text, replacements)
+	textbox.ReplaceRange(start, end, result)
+	ui.setStatusMessage("Replaced " + count)
+	ui.redraw()
+}
+
+// Returns `count` followed by the singular or plural noun, e.g. "1 match" or "2 matches".
+func countString(count int, singular, plural string) string {
+	if count == 1 {
+		return fmt.Sprintf("%d %v", count, singular)
+	}
+	return fmt.Sprintf("%d %v", count, plural)
+}
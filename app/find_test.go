@@ -0,0 +1,173 @@
+package app
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"github.com/gdamore/tcell/v2"
+	"github.com/Rye123/notepad--/search"
+)
+
+func TestFind(t *testing.T) {
+	ui, _ := newTestUI(t, "")
+	press := func(events ...[]tcell.Event) {
+		for _, ev := range(seq(events...)) {
+			ui.handleKeyEvent(ev.(*tcell.EventKey))
+		}
+	}
+	expect := func(start, end int, message string) {
+		t.Helper()
+		selStart, selEnd, ok := ui.textbox().Selection()
+		if !ok || selStart != start || selEnd != end {
+			t.Fatalf("Expected selection [%d, %d), instead [%d, %d) (%v)", start, end, selStart, selEnd, ok)
+		}
+		if got := ui.statusbar().Message(); !strings.HasPrefix(got, message) {
+			t.Fatalf("Expected status message starting with %q, instead %q", message, got)
+		}
+	}
+	ctrlF := []tcell.Event{tcell.NewEventKey(tcell.KeyCtrlF, 0, tcell.ModCtrl)}
+	f3 := keys(tcell.KeyF3, tcell.ModNone, 1)
+	shiftF3 := keys(tcell.KeyF3, tcell.ModShift, 1)
+
+	press(typed("one two One"), keys(tcell.KeyEnter, tcell.ModNone, 1), typed("two one"), keys(tcell.KeyHome, tcell.ModCtrl, 1))
+
+	// Matches are found as the query is typed, starting from the cursor
+	press(ctrlF, typed("on"))
+	expect(0, 2, "Match 1 of 3")
+	press(typed("e"), []tcell.Event{tcell.NewEventKey(tcell.KeyRune, 'c', tcell.ModAlt)})
+	expect(0, 3, "Match 1 of 2")
+	if ui.findbar().Query() != "one" || ui.appstate.TextBuffer.String() != "one two One\ntwo one" {
+		t.Fatalf("Expected typing to go to the find bar, instead query %q, buffer %q", ui.findbar().Query(), ui.appstate.TextBuffer.String())
+	}
+
+	// F3 and Shift+F3 wrap around the ends of the file
+	press(f3)
+	expect(16, 19, "Match 2 of 2")
+	press(f3)
+	expect(0, 3, "Match 1 of 2 (passed the end")
+	press(shiftF3)
+	expect(16, 19, "Match 2 of 2 (passed the start")
+	press(typed("x"))
+	if ui.statusbar().Message() != "No matches" {
+		t.Fatalf("Expected no matches, instead %q", ui.statusbar().Message())
+	}
+
+	// Closing the find bar keeps the query for F3, whose message only lasts until the next key
+	press(keys(tcell.KeyBackspace2, tcell.ModNone, 1), keys(tcell.KeyEscape, tcell.ModNone, 1))
+	if ui.findOpen() || ui.statusbar().Message() != "" {
+		t.Fatalf("Expected the find bar to be closed, with no message")
+	}
+	press(f3)
+	expect(16, 19, "Match 2 of 2")
+	press(keys(tcell.KeyRight, tcell.ModNone, 1))
+	if ui.statusbar().Message() != "" {
+		t.Fatalf("Expected the message to be cleared, instead %q", ui.statusbar().Message())
+	}
+
+	// The selection becomes the query, and pastes go to the find bar
+	press(keys(tcell.KeyLeft, tcell.ModShift, 3), ctrlF)
+	if ui.findbar().Query() != "one" {
+		t.Fatalf("Expected the selection as the query, instead %q", ui.findbar().Query())
+	}
+	ui.insertPaste(" two\nthree")
+	if ui.findbar().Query() != "one two" {
+		t.Fatalf("Expected the first line of the paste in the query, instead %q", ui.findbar().Query())
+	}
+}
+
+func TestReplace(t *testing.T) {
+	ui, screen := newTestUI(t, "")
+	press := func(events ...[]tcell.Event) {
+		for _, ev := range(seq(events...)) {
+			ui.handleKeyEvent(ev.(*tcell.EventKey))
+		}
+	}
+	expect := func(content, message string) {
+		t.Helper()
+		if got := ui.appstate.TextBuffer.String(); got != content {
+			t.Fatalf("Expected %q, instead buffer contents: %q", content, got)
+		}
+		if got := ui.statusbar().Message(); got != message {
+			t.Fatalf("Expected status message %q, instead %q", message, got)
+		}
+	}
+	alt := func(ch rune) []tcell.Event {
+		return []tcell.Event{tcell.NewEventKey(tcell.KeyRune, ch, tcell.ModAlt)}
+	}
+	original := "key1=a, key2=b\nfoo"
+
+	press(typed("key1=a, key2=b"), keys(tcell.KeyEnter, tcell.ModNone, 1), typed("foo"), keys(tcell.KeyHome, tcell.ModCtrl, 1))
+	press([]tcell.Event{tcell.NewEventKey(tcell.KeyCtrlH, 0, tcell.ModCtrl)}, alt('r'), typed(`(\w+)=(`))
+	expect(original, "invalid regular expression: missing closing ): `(\\w+)=(`")
+	press(typed(`\w)`), keys(tcell.KeyTab, tcell.ModNone, 1), typed("$2:$1"))
+	expect(original, "Match 1 of 2")
+
+	// Replace All asks first, then makes a single undoable edit
+	injectEvents(screen, typed("N")...)
+	press(alt('a'))
+	expect(original, "Match 1 of 2")
+	injectEvents(screen, typed("Y")...)
+	press(alt('a'))
+	expect("a:key1, b:key2\nfoo", "Replaced 2 matches")
+	press([]tcell.Event{tcell.NewEventKey(tcell.KeyCtrlZ, 0, tcell.ModCtrl)})
+	expect(original, "Replaced 2 matches")
+
+	// Enter replaces the selected match and selects the next one
+	press(keys(tcell.KeyHome, tcell.ModCtrl, 1), keys(tcell.KeyEnter, tcell.ModNone, 1))
+	expect(original, "Match 1 of 2")
+	press(keys(tcell.KeyEnter, tcell.ModNone, 1))
+	expect("a:key1, key2=b\nfoo", "Match 1 of 1")
+	if start, end, _ := ui.textbox().Selection(); start != 8 || end != 14 {
+		t.Fatalf("Expected the next match to be selected, instead [%d, %d)", start, end)
+	}
+	press(keys(tcell.KeyEnter, tcell.ModNone, 1))
+	expect("a:key1, b:key2\nfoo", "Cannot find \"(\\w+)=(\\w)\"")
+}
+
+func TestFindHighlightsFollowChanges(t *testing.T) {
+	ui, _ := newTestUI(t, "")
+	press := func(events ...[]tcell.Event) {
+		for _, ev := range(seq(events...)) {
+			ui.handleKeyEvent(ev.(*tcell.EventKey))
+		}
+	}
+	// Takes the start and end of each expected match
+	expect := func(bounds ...int) {
+		t.Helper()
+		expected := make([]search.Match, 0)
+		for i := 0; i < len(bounds); i += 2 {
+			expected = append(expected, search.Match{Start: bounds[i], End: bounds[i + 1]})
+		}
+		if !reflect.DeepEqual(ui.find.matches, expected) {
+			t.Fatalf("Expected matches %v, instead %v", expected, ui.find.matches)
+		}
+	}
+
+	press(typed("one two one"), []tcell.Event{tcell.NewEventKey(tcell.KeyCtrlF, 0, tcell.ModCtrl)}, typed("one"))
+	expect(0, 3, 8, 11)
+
+	// Matches move with an edit straight away, and the changed line is searched again
+	ui.focusTextbox()
+	press(keys(tcell.KeyLeft, tcell.ModNone, 1), typed("x"))
+	expect(1, 4, 9, 12)
+
+	// Edits made by commands are followed too
+	press(keys(tcell.KeyCtrlZ, tcell.ModCtrl, 1))
+	expect(0, 3, 8, 11)
+
+	// While the find bar is closed, matches only move, and Find Next searches again
+	ui.closeFind()
+	press(keys(tcell.KeyEnd, tcell.ModNone, 1), typed(" one"))
+	expect(0, 3)
+	press(keys(tcell.KeyF3, tcell.ModNone, 1))
+	expect(0, 3, 8, 11, 12, 15)
+
+	// A query that can span any number of lines is searched again once typing pauses
+	press([]tcell.Event{tcell.NewEventKey(tcell.KeyCtrlF, 0, tcell.ModCtrl), tcell.NewEventKey(tcell.KeyRune, 'r', tcell.ModAlt)}, typed(`\s`))
+	expect(0, 4, 8, 12)
+	ui.focusTextbox()
+	press(keys(tcell.KeyHome, tcell.ModCtrl, 1), typed("one "))
+	expect(12, 16)
+	ui.handleEvent(tcell.NewEventInterrupt(findRefresh{}))
+	expect(0, 4, 4, 8, 12, 16)
+}
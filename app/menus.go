@@ -0,0 +1,248 @@
+package app
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+	"github.com/Rye123/notepad--/command"
+	"github.com/Rye123/notepad--/config"
+	"github.com/Rye123/notepad--/tui"
+	"github.com/Rye123/notepad--/util"
+)
+
+// Opens the drop-down menu of the menu bar button at `menuIndex`, and performs the chosen action. Left and Right move to the neighbouring menus.
+func (ui *UI) openMenu(menuIndex int) {
+	menubar := ui.menubar()
+	for {
+		menubar.SetCursorIndex(menuIndex)
+		menuIndex = menubar.GetCursorIndex()
+		menu := tui.NewMenu(ui.appstate, menubar.ButtonX(menuIndex), tui.MENUBAR_ENDROW, ui.menuItems(menuIndex))
+
+		switch ui.runDialog(menu) {
+		case tui.MENU_LEFT:
+			menuIndex--
+		case tui.MENU_RIGHT:
+			menuIndex++
+		case tui.DIALOG_CANCEL:
+			// Back to the menu bar
+			return
+		default:
+			ui.focusTextbox()
+			menu.Selected().Action()
+			return
+		}
+	}
+}
+
+// Returns the items of the drop-down menu of the menu bar button at `menuIndex`, reflecting the current state of the app.
+func (ui *UI) menuItems(menuIndex int) []tui.MenuItem {
+	switch menuIndex {
+	case tui.MENU_FILE:
+		return []tui.MenuItem{
+			ui.commandItem("file.new", 'n'),
+			ui.commandItem("file.open", 'o'),
+			ui.commandItem("file.save", 's'),
+			ui.commandItem("file.saveAs", 'a'),
+			ui.commandItem("file.reopenWithEncoding", 'r'),
+			ui.commandItem("file.saveWithEncoding", 'e'),
+			ui.commandItem("file.exit", 'x'),
+		}
+	case tui.MENU_EDIT:
+		return []tui.MenuItem{
+			ui.commandItem("edit.undo", 'u'),
+			ui.commandItem("edit.redo", 'r'),
+			ui.commandItem("edit.cut", 't'),
+			ui.commandItem("edit.copy", 'c'),
+			ui.commandItem("edit.paste", 'p'),
+			ui.commandItem("edit.find", 'f'),
+			ui.commandItem("edit.findNext", 'n'),
+			ui.commandItem("edit.findPrevious", 'v'),
+			ui.commandItem("edit.replace", 'e'),
+			ui.commandItem("edit.goTo", 'g'),
+			ui.commandItem("edit.selectAll", 'a'),
+			ui.commandItem("edit.timeDate", 'd'),
+		}
+	case tui.MENU_FORMAT:
+		return []tui.MenuItem{
+			ui.commandItem("format.wordWrap", 'w'),
+			{Label: "Font/Theme...", Hotkey: 'f', Disabled: true},
+			ui.commandItem("format.lineEnding", 'l'),
+		}
+	case tui.MENU_VIEW:
+		return []tui.MenuItem{
+			ui.commandItem("view.statusBar", 's'),
+		}
+	case tui.MENU_HELP:
+		return []tui.MenuItem{
+			ui.commandItem("help.about", 'a'),
+		}
+	}
+	return nil
+}
+
+// Returns a menu item that runs the command `name`, with the key bound to it as the shortcut.
+func (ui *UI) commandItem(name string, hotkey rune) tui.MenuItem {
+	cmd, ok := ui.appstate.Commands.Get(name)
+	if !ok {
+		return tui.MenuItem{Label: name, Hotkey: hotkey, Disabled: true}
+	}
+	return tui.MenuItem{
+		Label: cmd.Title,
+		Hotkey: hotkey,
+		Shortcut: ui.appstate.Keymap.BindingFor(name),
+		Disabled: !cmd.IsEnabled(),
+		Checked: cmd.IsChecked(),
+		Action: func() { ui.appstate.Commands.Run(name) },
+	}
+}
+
+// Registers the commands of the app, other than those of the textbox.
+func (ui *UI) registerCommands() {
+	registry := ui.appstate.Commands
+	hasSelection := func() bool {
+		_, _, ok := ui.textbox().Selection()
+		return ok
+	}
+	editable := func() bool {
+		return !ui.appstate.ReadOnly
+	}
+	canCut := func() bool {
+		return editable() && hasSelection()
+	}
+
+	commands := []command.Command{
+		{Name: "file.new", Title: "New", Run: ui.New},
+		{Name: "file.open", Title: "Open...", Run: ui.Open},
+		{Name: "file.save", Title: "Save", Run: func() { ui.Save() }},
+		{Name: "file.saveAs", Title: "Save As...", Run: func() { ui.SaveAs() }},
+		{Name: "file.reopenWithEncoding", Title: "Reopen with Encoding...", Run: ui.ReopenWithEncoding},
+		{Name: "file.saveWithEncoding", Title: "Save with Encoding...", Run: ui.SaveWithEncoding},
+		{Name: "file.exit", Title: "Exit", Run: ui.Exit},
+
+		{Name: "edit.cut", Title: "Cut", Run: ui.Cut, Enabled: canCut},
+		{Name: "edit.copy", Title: "Copy", Run: ui.Copy, Enabled: hasSelection},
+		{Name: "edit.paste", Title: "Paste", Run: ui.Paste, Enabled: editable},
+		{Name: "edit.timeDate", Title: "Time/Date", Run: ui.InsertTimeDate, Enabled: editable},
+		{Name: "edit.find", Title: "Find...", Run: ui.Find},
+		{Name: "edit.findNext", Title: "Find Next", Run: ui.FindNext},
+		{Name: "edit.findPrevious", Title: "Find Previous", Run: ui.FindPrevious},
+		{Name: "edit.replace", Title: "Replace...", Run: ui.Replace, Enabled: editable},
+		{Name: "edit.goTo", Title: "Go To...", Run: ui.GoToLine},
+
+		{Name: "format.wordWrap", Title: "Word Wrap", Run: ui.ToggleWordWrap, Checked: func() bool { return ui.appstate.Options.WordWrap }},
+		{Name: "format.lineEnding", Title: "Line Ending...", Run: ui.ChooseLineEnding, Enabled: editable},
+		{Name: "view.statusBar", Title: "Status Bar", Run: ui.ToggleStatusBar, Checked: func() bool { return !ui.appstate.Options.HideStatusBar }},
+		{Name: "help.about", Title: "About " + ui.appstate.AppName, Run: ui.About},
+
+		{Name: "menu.focus", Title: "Focus Menu Bar", Run: ui.focusMenuBar},
+	}
+
+	// Opening each menu from the keyboard
+	menus := []struct{ name, title string }{{"file", "File"}, {"edit", "Edit"}, {"format", "Format"}, {"view", "View"}, {"help", "Help"}}
+	for i, menu := range(menus) {
+		menuIndex := i
+		commands = append(commands, command.Command{Name: "menu." + menu.name, Title: menu.title + " Menu", Run: func() {
+			ui.focusMenuBar()
+			ui.openMenu(menuIndex)
+		}})
+	}
+
+	for _, cmd := range(commands) {
+		registry.Register(cmd)
+	}
+}
+
+/* MENU ACTIONS */
+
+// Replaces the current file with an empty, untitled one, after offering to save unsaved changes.
+func (ui *UI) New() {
+	if !ui.confirmDiscard() {
+		return
+	}
+	ui.appstate.New()
+	ui.resetTextbox()
+}
+
+// Prompts for a file to open in place of the current one, after offering to save unsaved changes.
+func (ui *UI) Open() {
+	if !ui.confirmDiscard() {
+		return
+	}
+	filename, ok := ui.promptInput("Open", "File name:", "")
+	if !ok || filename == "" {
+		return
+	}
+	if err := ui.appstate.Open(filename); err != nil {
+		ui.promptMessage("Open", fmt.Sprintf("Could not open %v: %v", filename, err))
+		return
+	}
+	ui.resetTextbox()
+}
+
+// Closes the current file after offering to save unsaved changes, and opens the next file given on the command line. Quits the app if there are none left.
+func (ui *UI) Exit() {
+	if !ui.Quit() {
+		return
+	}
+	for len(ui.queue) > 0 {
+		file := ui.queue[0]
+		ui.queue = ui.queue[1:]
+		if err := ui.OpenFile(file); err != nil {
+			ui.promptMessage("Open", fmt.Sprintf("Could not open %v: %v", file.Path, err))
+			continue
+		}
+		return
+	}
+	ui.exiting = true
+}
+
+// Inserts the current time and date at the cursor.
+func (ui *UI) InsertTimeDate() {
+	ui.textbox().InsertText(time.Now().Format("3:04 PM 1/2/2006"))
+}
+
+// Prompts for a position to go to, and moves the cursor there.
+func (ui *UI) GoToLine() {
+	textbox := ui.textbox()
+	_, cursorY := textbox.GetCursorXY()
+	input := strconv.Itoa(cursorY + 1)
+	for {
+		lineCount := textbox.LineCount()
+		var ok bool
+		input, ok = ui.promptInput("Go To Line", fmt.Sprintf("Line (1-%d), line:column, +N, -N or N%%:", lineCount), input)
+		if !ok {
+			return
+		}
+
+		pos, err := util.ParsePosition(input)
+		if err != nil {
+			ui.promptMessage("Go To Line", fmt.Sprintf("%q: %v", input, err))
+			continue
+		}
+		line, col, err := pos.Resolve(cursorY + 1, lineCount)
+		if err != nil {
+			ui.promptMessage("Go To Line", fmt.Sprintf("%q: %v", input, err))
+			continue
+		}
+		textbox.GoTo(line, col)
+		return
+	}
+}
+
+func (ui *UI) ToggleWordWrap() {
+	wordWrap := !ui.appstate.Options.WordWrap
+	ui.textbox().SetWordWrap(wordWrap)
+	ui.redraw()
+	ui.saveConfig(func(cfg *config.Config) { cfg.WordWrap = wordWrap })
+}
+
+func (ui *UI) ToggleStatusBar() {
+	ui.appstate.Options.HideStatusBar = !ui.appstate.Options.HideStatusBar
+	ui.textbox().SetCursorIndex(ui.textbox().GetCursorIndex())
+	ui.redraw()
+	ui.saveConfig(func(cfg *config.Config) { cfg.StatusBar = !ui.appstate.Options.HideStatusBar })
+}
+
+func (ui *UI) About() {
+	ui.promptMessage("About " + ui.appstate.AppName, ui.appstate.AppName + ": A simple text editor for the terminal, in the style of Notepad.")
+}
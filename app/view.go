@@ -2,7 +2,15 @@
 package app
 
 import (
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
 	"github.com/gdamore/tcell/v2"
+	"github.com/Rye123/notepad--/cli"
+	"github.com/Rye123/notepad--/config"
 	"github.com/Rye123/notepad--/tui"
 	"github.com/Rye123/notepad--/util"
 )
@@ -10,74 +18,580 @@ import (
 type UI struct {
 	appstate *util.AppState
 	elements []tui.TUIElem	
+	dialogs *tui.DialogStack // Modal dialogs shown over the elements
+	terminated bool // True once a termination signal has been received
+	exitMessage string
+	exiting bool // True once Exit has been chosen from a menu
+	paste *strings.Builder // Text of the paste in progress, or nil if not pasting
+	config *config.Config // Settings that changes from the menus are saved to, or nil if they aren't saved
+	configPath string
+	args cli.Args // Command line options used when opening files
+	queue []cli.File // Files given on the command line that are still to be opened, in order
+	find findState // The last search made with the find bar
 }
 
 func NewUI(appstate *util.AppState, elements []tui.TUIElem) *UI {
-	return &UI{appstate, elements}
+	ui := &UI{appstate, elements, tui.NewDialogStack(), false, "", false, nil, nil, "", cli.Args{}, nil, findState{}}
+	if menubar := ui.menubar(); menubar != nil {
+		menubar.SetOnOpen(ui.openMenu)
+	}
+	if findbar := ui.findbar(); findbar != nil {
+		findbar.SetOnChange(ui.searchAsYouType)
+		findbar.SetOnSubmit(ui.FindNext)
+		findbar.SetOnReplace(ui.ReplaceNext, ui.ReplaceAll)
+	}
+	ui.appstate.TextBuffer.Subscribe(ui.bufferChanged)
+	ui.registerCommands()
+	return ui
+}
+
+// Opens the first file of `args`, leaving the rest to be opened in turn as each is closed. The options of `args` are used to open each of them.
+// `stdin` is read from if the file is STDIN_PATH. An error is returned if the first file can't be opened.
+func (ui *UI) OpenArgs(args cli.Args, stdin io.Reader) error {
+	ui.args = args
+	ui.appstate.ReadOnly = args.ReadOnly
+	if len(args.Files) == 0 {
+		return nil
+	}
+	ui.queue = args.Files[1:]
+
+	file := args.Files[0]
+	if file.Path != cli.STDIN_PATH {
+		return ui.OpenFile(file)
+	}
+	data, err := io.ReadAll(stdin)
+	if err != nil {
+		return err
+	}
+	if err := ui.appstate.OpenData(data, args.Encoding); err != nil {
+		return err
+	}
+	ui.openedFile(file)
+	return nil
+}
+
+// Opens `file` in place of the current file, with the encoding and line ending given on the command line, and moves the cursor to its position.
+func (ui *UI) OpenFile(file cli.File) error {
+	if err := ui.appstate.OpenWithEncoding(file.Path, ui.args.Encoding); err != nil {
+		return err
+	}
+	ui.openedFile(file)
+	return nil
+}
+
+// Applies the command line options to a file that has just been opened.
+func (ui *UI) openedFile(file cli.File) {
+	if ui.args.LineEnding != "" {
+		ui.appstate.SetLineEndMode(ui.args.LineEnding)
+	}
+	ui.resetTextbox()
+	if textbox := ui.textbox(); textbox != nil && file.Line > 0 {
+		textbox.GoTo(file.Line, file.Col)
+	}
+}
+
+// Sets the config that options changed from the menus, e.g. word wrap, are saved to, and the path of its file.
+func (ui *UI) SetConfig(cfg *config.Config, path string) {
+	ui.config = cfg
+	ui.configPath = path
+}
+
+// Saves a setting changed from the menus to the config file, if there is one. `update` changes the setting in the config.
+// Only the changed setting is saved, so that options given on the command line, e.g. --no-wrap, aren't kept.
+func (ui *UI) saveConfig(update func(cfg *config.Config)) {
+	if ui.config == nil {
+		return
+	}
+	update(ui.config)
+	if err := ui.config.Save(ui.configPath); err != nil {
+		ui.promptMessage("Settings", fmt.Sprintf("Could not save settings to %v: %v", ui.configPath, err))
+	}
 }
 
 func (ui *UI) Display() {
-	defer ui.Quit()
 	screen := ui.appstate.Screen
 	screen.SetCursorStyle(tcell.CursorStyleBlinkingBar)
+	screen.EnablePaste()
+
+	// Termination signals are handled in the event loop
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGTERM, syscall.SIGHUP)
+	defer func() {
+		signal.Stop(signals)
+		close(signals)
+	}()
+	go func() {
+		for sig := range(signals) {
+			screen.PostEvent(tcell.NewEventInterrupt(sig))
+		}
+	}()
 
 renderLoop:
 	for {
 		// Process Events
 		ev := screen.PollEvent()
-		switch ev := ev.(type) {
-		case *tcell.EventResize:
-			screen.Sync()
-		case *tcell.EventKey:
-			quit := ui.handleKeyEvent(ev)
-			if quit {
-				break renderLoop
-			}
+		if ev == nil {
+			// Screen was finalised
+			break renderLoop
+		}
+		quit := ui.handleEvent(ev)
+		if quit || ui.terminated {
+			break renderLoop
 		}
 
-		// Draw Screen (Selectively update the elements)
-		for _, elem := range(ui.elements) {
-			elem.Draw()
+		// Skip drawing until a paste is complete
+		if ui.paste == nil {
+			ui.draw()
 		}
+	}
+}
+
+// Returns a message to be shown to the user after the screen is closed, if any.
+func (ui *UI) ExitMessage() string {
+	return ui.exitMessage
+}
+
+// Handles an event. Returns true if UI is to quit after returning from this function.
+func (ui *UI) handleEvent(ev tcell.Event) bool {
+	switch ev := ev.(type) {
+	case *tcell.EventResize:
+		ui.appstate.Screen.Sync()
+		ui.redraw()
+	case *tcell.EventKey:
+		if ui.paste != nil {
+			ui.paste.WriteString(pastedKeyText(ev))
+			return false
+		}
+		return ui.handleKeyEvent(ev)
+	case *tcell.EventPaste:
+		// Keys between the start and end of a paste are collected, then inserted all at once
+		if ev.Start() {
+			ui.paste = &strings.Builder{}
+		} else if ui.paste != nil {
+			text := ui.paste.String()
+			ui.paste = nil
+			ui.insertPaste(text)
+		}
+	case *tcell.EventInterrupt:
+		if sig, ok := ev.Data().(os.Signal); ok {
+			ui.terminate(sig)
+			return true
+		}
+		if _, ok := ev.Data().(findRefresh); ok {
+			ui.refreshMatches()
+		}
+	}
+	return false
+}
 
-		screen.Show()
+// Returns the text that a key event within a paste stands for.
+func pastedKeyText(keyEvent *tcell.EventKey) string {
+	switch keyEvent.Key() {
+	case tcell.KeyRune:
+		return string(keyEvent.Rune())
+	case tcell.KeyEnter:
+		return "\r"
+	case tcell.KeyLF:
+		return "\n"
+	case tcell.KeyTab:
+		return "\t"
 	}
+	return ""
 }
 
-// Save. Will only save if this is a modified, pre-existing file. If it doesn't exist beforehand (i.e. filename == ""), SaveAs is called.
-func (ui *UI) Save() {
+// Inserts pasted `text` into the top-most dialog if there is one, or the find bar if it is focused, otherwise into the textbox.
+func (ui *UI) insertPaste(text string) {
+	// Dialog inputs and the find bar are single line, so the text is typed in up to the first line end
+	line, _, _ := strings.Cut(util.NormaliseLineEndings(text), "\n")
+	if ui.dialogs.Len() > 0 {
+		for _, ch := range(line) {
+			ui.dialogs.HandleKey(tcell.NewEventKey(tcell.KeyRune, ch, tcell.ModNone))
+		}
+		return
+	}
+	if findbar := ui.findbar(); findbar != nil && findbar.IsActive() {
+		for _, ch := range(line) {
+			findbar.HandleKey(tcell.NewEventKey(tcell.KeyRune, ch, tcell.ModNone))
+		}
+		return
+	}
+
+	ui.focusTextbox()
+	ui.textbox().PasteText(text)
+}
+
+// Closes the app without any prompts after receiving `sig`. Unsaved changes are written to a recovery file instead.
+func (ui *UI) terminate(sig os.Signal) {
+	ui.terminated = true
+	for ui.dialogs.Len() > 0 {
+		ui.dialogs.Top().Close(tui.DIALOG_CANCEL)
+		ui.dialogs.Prune()
+	}
+
 	if !ui.appstate.FileModified {
 		return
 	}
+	recoveryFilename, err := ui.appstate.WriteRecovery()
+	if err != nil {
+		ui.exitMessage = fmt.Sprintf("Received %v, unsaved changes could not be recovered: %v", sig, err)
+	} else {
+		ui.exitMessage = fmt.Sprintf("Received %v, unsaved changes written to %v", sig, recoveryFilename)
+	}
+}
+
+// Draws all the elements, followed by the open dialogs.
+func (ui *UI) draw() {
+	// Draw Screen (Selectively update the elements)
+	for _, elem := range(ui.elements) {
+		elem.Draw()
+	}
+	ui.dialogs.Draw()
+
+	ui.appstate.Screen.Show()
+}
+
+// Clears the screen and redraws every element.
+func (ui *UI) redraw() {
+	ui.appstate.Screen.Clear()
+	for _, elem := range(ui.elements) {
+		elem.Redraw()
+	}
+	ui.draw()
+}
+
+// Opens `dialog` over the other elements. Key events are handled by the dialog until it is closed.
+func (ui *UI) OpenDialog(dialog tui.Modal) {
+	ui.dialogs.Push(dialog)
+}
+
+// Opens `dialog`, and processes events until it is closed. Returns the result of the dialog.
+func (ui *UI) runDialog(dialog tui.Modal) string {
+	screen := ui.appstate.Screen
+	if ui.terminated {
+		dialog.Close(tui.DIALOG_CANCEL)
+		return dialog.Result()
+	}
+	ui.OpenDialog(dialog)
+	ui.draw()
+
+	for !dialog.Closed() {
+		ev := screen.PollEvent()
+		if ev == nil {
+			// Screen was finalised
+			dialog.Close(tui.DIALOG_CANCEL)
+			break
+		}
+		ui.handleEvent(ev)
+		ui.draw()
+	}
+
+	if ui.dialogs.Prune() {
+		ui.redraw()
+	}
+	return dialog.Result()
+}
+
+// Prompts for a line of text, pre-filled with `initial`. ok is false if the prompt was cancelled.
+func (ui *UI) promptInput(title, message, initial string) (value string, ok bool) {
+	dialog, input := tui.NewInputDialog(ui.appstate, title, message, initial)
+	result := ui.runDialog(dialog)
+	return input.Text(), result == tui.DIALOG_OK
+}
+
+// Asks a Yes/No question, returning true for Yes.
+func (ui *UI) promptConfirm(title, message string) bool {
+	return ui.runDialog(tui.NewMessageDialog(ui.appstate, title, message, tui.DIALOG_YES, tui.DIALOG_NO)) == tui.DIALOG_YES
+}
+
+// Shows a message until it is dismissed.
+func (ui *UI) promptMessage(title, message string) {
+	ui.runDialog(tui.NewMessageDialog(ui.appstate, title, message))
+}
+
+// Save. Will only save if this is a modified, pre-existing file. If it doesn't exist beforehand (i.e. filename == ""), SaveAs is called. Returns true if the file was saved.
+func (ui *UI) Save() bool {
+	if !ui.appstate.FileModified {
+		return true
+	}
 
 	// Check if file existed before
 	if ui.appstate.Filename == "" {
-		ui.SaveAs()
-		return
+		return ui.SaveAs()
 	}
 
-	ui.appstate.Save()
-	
+	if !ui.confirmEncoding() {
+		return false
+	}
+	if err := ui.appstate.Save(); err != nil {
+		ui.promptMessage("Save", err.Error())
+		return false
+	}
+	return true
 }
 
-// Save, with a prompt. Will return immediately if the file never existed before and no content has been written
-func (ui *UI) SaveAs() {
+// Save, with a prompt for the filename. Will return immediately if the file never existed before and no content has been written. Returns true if the file was saved.
+func (ui *UI) SaveAs() bool {
 	filename := ui.appstate.Filename
 	if filename == "" {
-		filename = util.GetTemporaryTitle(ui.appstate.TextBuffer.String())
+		if ui.appstate.TextBuffer.Length() == 0 {
+			return false
+		}
+		filename = util.GetTemporaryFilename(ui.appstate.TextBuffer.Line(0))
 		if filename == "" {
-			return
+			filename = "Untitled"
 		}
+		filename += ".txt"
 	}
 
-	ui.appstate.Filename = filename
-	//TODO: Prompt, then call save from appstate.
-	panic("SaveAs not implemented.")
+	for {
+		var ok bool
+		filename, ok = ui.promptInput("Save As", "File name:", filename)
+		if !ok {
+			return false
+		}
+		if filename == "" {
+			continue
+		}
+
+		// Confirm overwriting a different, existing file
+		if filename != ui.appstate.Filename {
+			if _, err := os.Stat(filename); err == nil {
+				if !ui.promptConfirm("Confirm Save As", filename + " already exists. Do you want to replace it?") {
+					continue
+				}
+			}
+		}
+
+		if err := util.CheckWritable(filename); err != nil {
+			ui.promptMessage("Save As", err.Error())
+			continue
+		}
+
+		if !ui.confirmEncoding() {
+			return false
+		}
+
+		if err := ui.appstate.SaveAs(filename); err != nil {
+			ui.promptMessage("Save As", err.Error())
+			continue
+		}
+		return true
+	}
 }
 
-func (ui *UI) Quit() {
-	if ui.appstate.FileModified {
-		ui.SaveAs()
+// Warns about characters that can't be saved in the chosen encoding. Returns true if saving should go ahead.
+func (ui *UI) confirmEncoding() bool {
+	unrepresentable := ui.appstate.Unrepresentable()
+	if len(unrepresentable) == 0 {
+		return true
+	}
+
+	const maxShown = 10
+	shown := string(unrepresentable)
+	if len(unrepresentable) > maxShown {
+		shown = string(unrepresentable[:maxShown]) + "..."
+	}
+	message := fmt.Sprintf("This file contains characters that can't be represented in %v, which will be saved as '%c': %v\nSave anyway?", ui.appstate.Options.Encoding, util.ENCODING_REPLACEMENT, shown)
+	return ui.promptConfirm("Save", message)
+}
+
+// Prompts for an encoding, then saves the file in that encoding under a new name.
+func (ui *UI) SaveWithEncoding() {
+	encoding, ok := ui.promptEncoding("Save with Encoding", "Save in encoding:")
+	if !ok {
+		return
+	}
+
+	oldEncoding := ui.appstate.Options.Encoding
+	ui.appstate.Options.Encoding = encoding
+	if !ui.SaveAs() {
+		ui.appstate.Options.Encoding = oldEncoding
+	}
+}
+
+// Prompts for an encoding, then reloads the file from disk with that encoding, discarding any unsaved changes.
+func (ui *UI) ReopenWithEncoding() {
+	if ui.appstate.Filename == "" {
+		ui.promptMessage("Reopen with Encoding", "This file has not been saved yet.")
+		return
+	}
+	encoding, ok := ui.promptEncoding("Reopen with Encoding", "Reopen in encoding:")
+	if !ok {
+		return
+	}
+	if ui.appstate.FileModified && !ui.promptConfirm("Reopen with Encoding", "Unsaved changes will be lost. Continue?") {
+		return
+	}
+
+	if err := ui.appstate.Reopen(encoding); err != nil {
+		ui.promptMessage("Reopen with Encoding", err.Error())
+		return
+	}
+	ui.resetTextbox()
+}
+
+// Moves the cursor to the start of a newly loaded file.
+func (ui *UI) resetTextbox() {
+	if textbox := ui.textbox(); textbox != nil {
+		textbox.ClearSelection()
+		textbox.SetCursorIndex(0)
+	}
+	ui.redraw()
+}
+
+// Prompts for one of the supported encodings, with the current encoding selected.
+func (ui *UI) promptEncoding(title, message string) (string, bool) {
+	selected := 0
+	for i, encoding := range(util.Encodings) {
+		if encoding == ui.appstate.Options.Encoding {
+			selected = i
+		}
+	}
+
+	dialog, list := tui.NewListDialog(ui.appstate, title, message, util.Encodings, selected)
+	if ui.runDialog(dialog) != tui.DIALOG_OK {
+		return "", false
+	}
+	return list.Selected(), true
+}
+
+// Offers to save unsaved changes before closing the file. Returns true if the app may quit.
+func (ui *UI) Quit() bool {
+	return ui.confirmDiscard()
+}
+
+// Offers to save unsaved changes before they are discarded. Returns true if the changes were saved or may be discarded, and false if cancelled.
+func (ui *UI) confirmDiscard() bool {
+	if !ui.appstate.FileModified {
+		return true
+	}
+
+	filename := ui.appstate.Filename
+	if filename == "" {
+		filename = "Untitled"
+	}
+	message := "Do you want to save changes to " + filename + "?"
+
+	switch ui.runDialog(tui.NewMessageDialog(ui.appstate, ui.appstate.AppName, message, "Save", "Don't Save", "Cancel")) {
+	case "Save":
+		return ui.Save()
+	case "Don't Save":
+		return true
+	default:
+		return false
+	}
+}
+
+// Prompts for the line end mode to use when saving.
+func (ui *UI) ChooseLineEnding() {
+	modes := []string{util.LINE_END_CRLF, util.LINE_END_LF, util.LINE_END_CR}
+	items := make([]string, len(modes))
+	selected := 0
+	for i, mode := range(modes) {
+		options := util.Options{LineEndMode: mode}
+		items[i] = options.LineEndModeString()
+		if mode == ui.appstate.Options.LineEndMode {
+			selected = i
+		}
+	}
+
+	dialog, list := tui.NewListDialog(ui.appstate, "Line Ending", "Convert line endings to:", items, selected)
+	if ui.runDialog(dialog) == tui.DIALOG_OK {
+		ui.appstate.SetLineEndMode(modes[list.GetCursorIndex()])
+	}
+}
+
+// Cuts the selection to the clipboard.
+func (ui *UI) Cut() {
+	if err := ui.textbox().Cut(); err != nil {
+		ui.promptMessage("Cut", fmt.Sprintf("Could not cut to the clipboard: %v", err))
+	}
+}
+
+// Copies the selection to the clipboard.
+func (ui *UI) Copy() {
+	if err := ui.textbox().Copy(); err != nil {
+		ui.promptMessage("Copy", fmt.Sprintf("Could not copy to the clipboard: %v", err))
+	}
+}
+
+// Pastes the clipboard into the textbox.
+func (ui *UI) Paste() {
+	if err := ui.textbox().Paste(); err != nil {
+		ui.promptMessage("Paste", fmt.Sprintf("Could not paste from the clipboard: %v", err))
+	}
+}
+
+// Returns the textbox element, or nil if there is none.
+func (ui *UI) textbox() *tui.Textbox {
+	for _, elem := range(ui.elements) {
+		if textbox, ok := elem.(*tui.Textbox); ok {
+			return textbox
+		}
+	}
+	return nil
+}
+
+// Returns the menu bar element, or nil if there is none.
+func (ui *UI) menubar() *tui.MenuBar {
+	for _, elem := range(ui.elements) {
+		if menubar, ok := elem.(*tui.MenuBar); ok {
+			return menubar
+		}
+	}
+	return nil
+}
+
+// Returns the find bar element, or nil if there is none.
+func (ui *UI) findbar() *tui.FindBar {
+	for _, elem := range(ui.elements) {
+		if findbar, ok := elem.(*tui.FindBar); ok {
+			return findbar
+		}
+	}
+	return nil
+}
+
+// Returns the status bar element, or nil if there is none.
+func (ui *UI) statusbar() *tui.StatusBar {
+	for _, elem := range(ui.elements) {
+		if statusbar, ok := elem.(*tui.StatusBar); ok {
+			return statusbar
+		}
+	}
+	return nil
+}
+
+// Moves focus to the menu bar.
+func (ui *UI) focusMenuBar() {
+	for _, elem := range(ui.elements) {
+		if _, ok := elem.(*tui.MenuBar); ok {
+			elem.Focus()
+		} else {
+			elem.Unfocus()
+		}
+	}
+}
+
+// Moves focus to the find bar.
+func (ui *UI) focusFindBar() {
+	for _, elem := range(ui.elements) {
+		if _, ok := elem.(*tui.FindBar); ok {
+			elem.Focus()
+		} else {
+			elem.Unfocus()
+		}
+	}
+}
+
+// Moves focus back to the textbox.
+func (ui *UI) focusTextbox() {
+	for _, elem := range(ui.elements) {
+		if _, ok := elem.(*tui.Textbox); ok {
+			elem.Focus()
+		} else {
+			elem.Unfocus()
+		}
 	}
 }
 
@@ -85,38 +599,43 @@ func (ui *UI) Quit() {
 func (ui *UI) handleKeyEvent(keyEvent *tcell.EventKey) bool {
 	mod, key := keyEvent.Modifiers(), keyEvent.Key()
 
-	// CONTROL KEYS
-	switch key {
-	case tcell.KeyCtrlS: // Ctrl-S: Save, Ctrl-Alt-S: Save As
-		if mod & tcell.ModAlt != 0 {
-			ui.SaveAs()
-		} else {
-			ui.Save()
+	// Top-most dialog takes all key events
+	if ui.dialogs.HandleKey(keyEvent) {
+		if ui.dialogs.Prune() {
+			ui.redraw()
 		}
-		return false
-	case tcell.KeyCtrlW: // Ctrl-W: Close
-		return true
-	case tcell.KeyEscape: // ESC: Refocus on textbox
-		for _, elem := range(ui.elements) {
-			if _, ok := elem.(*tui.Textbox); ok {
-				elem.Focus()
-			} else {
-				elem.Unfocus()
-			}
-		}
-		
 		return false
 	}
 
-	// If Alt is pressed along with a key, control handed to menubar.
-	if mod & tcell.ModAlt != 0 {
-		for _, elem := range(ui.elements) {
-			if _, ok := elem.(*tui.MenuBar); ok {
-				elem.Focus()
-			} else {
-				elem.Unfocus()
-			}
+	// The menu bar takes unmodified keys while it is focused, to move between and open its menus
+	if menubar := ui.menubar(); menubar != nil && menubar.IsActive() && mod & (tcell.ModCtrl | tcell.ModAlt) == 0 && key != tcell.KeyEscape {
+		menubar.HandleKey(keyEvent)
+		return ui.exiting
+	}
+
+	// Messages from searching with the find bar closed are only shown until the next key
+	if !ui.findOpen() {
+		ui.setStatusMessage("")
+	}
+
+	// The find bar takes the keys that edit the query while it is focused
+	if findbar := ui.findbar(); findbar != nil && findbar.IsActive() && findbar.TakesKey(keyEvent) {
+		findbar.HandleKey(keyEvent)
+		return ui.exiting
+	}
+
+	// Keys bound to commands
+	if ui.appstate.Keymap.Dispatch(keyEvent, ui.appstate.Commands) {
+		return ui.exiting
+	}
+
+	// ESC: Close the find bar, or refocus on textbox
+	if key == tcell.KeyEscape {
+		if ui.findOpen() {
+			ui.closeFind()
 		}
+		ui.focusTextbox()
+		return false
 	}
 
 	// Hand over event to all the elements
@@ -0,0 +1,414 @@
+package app
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+	"github.com/gdamore/tcell/v2"
+	"github.com/Rye123/notepad--/cli"
+	"github.com/Rye123/notepad--/config"
+	"github.com/Rye123/notepad--/tui"
+	"github.com/Rye123/notepad--/util"
+)
+
+// Returns a UI for `filename` on an 80x24 simulation screen, with the standard elements.
+func newTestUI(t *testing.T, filename string) (*UI, tcell.SimulationScreen) {
+	screen := tcell.NewSimulationScreen("")
+	if err := screen.Init(); err != nil {
+		t.Fatalf("%+v", err)
+	}
+	t.Cleanup(screen.Fini)
+	screen.SetSize(80, 24)
+
+	options := util.Options{LineEndMode: "LF", Encoding: "UTF-8", WordWrap: true}
+	appstate := util.InitialiseAppState(screen, filename, options)
+	textbox := tui.NewTextbox(appstate)
+	elems := []tui.TUIElem{
+		tui.NewTitleBar(appstate, textbox),
+		tui.NewMenuBar(appstate),
+		textbox,
+		tui.NewStatusBar(appstate, textbox),
+		tui.NewFindBar(appstate),
+	}
+	return NewUI(appstate, elems), screen
+}
+
+// Posts `events` to the screen from another goroutine, in order, waiting for room in the event queue.
+func injectEvents(screen tcell.SimulationScreen, events ...tcell.Event) {
+	go func() {
+		for _, ev := range(events) {
+			screen.PostEventWait(ev)
+		}
+	}()
+}
+
+// Returns the key events for typing `s`.
+func typed(s string) []tcell.Event {
+	keys := make([]tcell.Event, 0)
+	for _, r := range(s) {
+		keys = append(keys, tcell.NewEventKey(tcell.KeyRune, r, tcell.ModNone))
+	}
+	return keys
+}
+
+// Returns `count` repetitions of `k`.
+func keys(k tcell.Key, mod tcell.ModMask, count int) []tcell.Event {
+	keys := make([]tcell.Event, count)
+	for i := range(keys) {
+		keys[i] = tcell.NewEventKey(k, 0, mod)
+	}
+	return keys
+}
+
+// Concatenates key sequences.
+func seq(parts ...[]tcell.Event) []tcell.Event {
+	all := make([]tcell.Event, 0)
+	for _, part := range(parts) {
+		all = append(all, part...)
+	}
+	return all
+}
+
+func TestSaveAsUntitled(t *testing.T) {
+	dir := t.TempDir()
+	ui, screen := newTestUI(t, "")
+	target := filepath.Join(dir, "notes.txt")
+
+	// Ctrl-S on an untitled buffer opens the Save As dialog, pre-filled with "hello.txt"
+	injectEvents(screen, seq(
+		typed("hello"),
+		keys(tcell.KeyCtrlS, tcell.ModCtrl, 1),
+		keys(tcell.KeyBackspace2, tcell.ModNone, len("hello.txt")),
+		typed(target),
+		keys(tcell.KeyEnter, tcell.ModNone, 1),
+		keys(tcell.KeyCtrlW, tcell.ModCtrl, 1),
+	)...)
+	ui.Display()
+
+	data, err := os.ReadFile(target)
+	if err != nil {
+		t.Fatalf("%+v", err)
+	}
+	if string(data) != "hello" {
+		t.Fatalf("Expected \"hello\", instead file contents: " + string(data))
+	}
+	if ui.appstate.Filename != target || ui.appstate.FileModified {
+		t.Fatalf("Expected appstate to be updated after saving, instead filename: " + ui.appstate.Filename)
+	}
+}
+
+func TestSaveAsOverwrite(t *testing.T) {
+	dir := t.TempDir()
+	existing := filepath.Join(dir, "existing.txt")
+	if err := os.WriteFile(existing, []byte("old"), 0660); err != nil {
+		t.Fatalf("%+v", err)
+	}
+	filename := filepath.Join(dir, "new.txt")
+	ui, screen := newTestUI(t, filename)
+
+	injectEvents(screen, seq(
+		typed("new"),
+		// Declining to overwrite returns to the filename prompt, and cancelling that doesn't save
+		keys(tcell.KeyCtrlS, tcell.ModCtrl | tcell.ModAlt, 1),
+		keys(tcell.KeyBackspace2, tcell.ModNone, len(filename)),
+		typed(existing),
+		keys(tcell.KeyEnter, tcell.ModNone, 1),
+		typed("n"),
+		keys(tcell.KeyEscape, tcell.ModNone, 1),
+		// Accepting overwrites the file
+		keys(tcell.KeyCtrlS, tcell.ModCtrl | tcell.ModAlt, 1),
+		keys(tcell.KeyBackspace2, tcell.ModNone, len(filename)),
+		typed(existing),
+		keys(tcell.KeyEnter, tcell.ModNone, 1),
+		typed("y"),
+		keys(tcell.KeyCtrlW, tcell.ModCtrl, 1),
+	)...)
+	ui.Display()
+
+	data, _ := os.ReadFile(existing)
+	if string(data) != "new" {
+		t.Fatalf("Expected \"new\", instead file contents: " + string(data))
+	}
+	if ui.appstate.Filename != existing {
+		t.Fatalf("Expected filename to be updated, instead filename: " + ui.appstate.Filename)
+	}
+}
+
+func TestQuitUnsavedChanges(t *testing.T) {
+	dir := t.TempDir()
+	filename := filepath.Join(dir, "notes.txt")
+	ui, screen := newTestUI(t, filename)
+
+	injectEvents(screen, seq(
+		typed("abc"),
+		// Cancel returns to editing
+		keys(tcell.KeyCtrlW, tcell.ModCtrl, 1),
+		keys(tcell.KeyEscape, tcell.ModNone, 1),
+		typed("d"),
+		keys(tcell.KeyCtrlW, tcell.ModCtrl, 1),
+		keys(tcell.KeyRight, tcell.ModNone, 2),
+		keys(tcell.KeyEnter, tcell.ModNone, 1),
+		// Save writes the file before closing
+		typed("e"),
+		keys(tcell.KeyCtrlW, tcell.ModCtrl, 1),
+		typed("s"),
+	)...)
+	ui.Display()
+
+	data, _ := os.ReadFile(filename)
+	if string(data) != "abcde" {
+		t.Fatalf("Expected \"abcde\", instead file contents: " + string(data))
+	}
+}
+
+func TestQuitDontSave(t *testing.T) {
+	dir := t.TempDir()
+	filename := filepath.Join(dir, "notes.txt")
+	ui, screen := newTestUI(t, filename)
+
+	injectEvents(screen, seq(
+		typed("abc"),
+		keys(tcell.KeyCtrlW, tcell.ModCtrl, 1),
+		typed("d"),
+	)...)
+	ui.Display()
+
+	if _, err := os.Stat(filename); err == nil {
+		t.Fatalf("Expected file not to be written")
+	}
+}
+
+func TestTerminateWritesRecovery(t *testing.T) {
+	dir := t.TempDir()
+	filename := filepath.Join(dir, "notes.txt")
+	ui, screen := newTestUI(t, filename)
+
+	injectEvents(screen, seq(
+		typed("abc"),
+		// A signal while a dialog is open closes the app anyway
+		keys(tcell.KeyCtrlW, tcell.ModCtrl, 1),
+		[]tcell.Event{tcell.NewEventInterrupt(syscall.SIGTERM)},
+	)...)
+	ui.Display()
+
+	matches, _ := filepath.Glob(filename + ".*.recovered")
+	if len(matches) != 1 {
+		t.Fatalf("Expected 1 recovery file, instead found %d", len(matches))
+	}
+	data, _ := os.ReadFile(matches[0])
+	if string(data) != "abc" {
+		t.Fatalf("Expected \"abc\", instead recovery file contents: " + string(data))
+	}
+	if _, err := os.Stat(filename); err == nil {
+		t.Fatalf("Expected original file not to be written")
+	}
+	if ui.ExitMessage() == "" {
+		t.Fatalf("Expected exit message about the recovery file")
+	}
+}
+
+func TestUndoToSavedState(t *testing.T) {
+	dir := t.TempDir()
+	filename := filepath.Join(dir, "notes.txt")
+	ui, screen := newTestUI(t, filename)
+
+	injectEvents(screen, seq(
+		typed("abc"),
+		keys(tcell.KeyCtrlS, tcell.ModCtrl, 1),
+		typed("de"),
+		keys(tcell.KeyCtrlZ, tcell.ModCtrl, 1),
+		// Closes without a prompt, as there are no unsaved changes
+		keys(tcell.KeyCtrlW, tcell.ModCtrl, 1),
+	)...)
+	ui.Display()
+
+	if ui.appstate.FileModified {
+		t.Fatalf("Expected file to be unmodified after undoing back to the saved state")
+	}
+	if ui.appstate.TextBuffer.String() != "abc" {
+		t.Fatalf("Expected \"abc\", instead buffer contents: " + ui.appstate.TextBuffer.String())
+	}
+}
+
+func TestCutCopyPaste(t *testing.T) {
+	ui, screen := newTestUI(t, "")
+
+	injectEvents(screen, seq(
+		typed("hello world"),
+		// Cut "world", then paste it at the start
+		keys(tcell.KeyLeft, tcell.ModShift, len("world")),
+		keys(tcell.KeyCtrlX, tcell.ModCtrl, 1),
+		keys(tcell.KeyHome, tcell.ModNone, 1),
+		keys(tcell.KeyCtrlV, tcell.ModCtrl, 1),
+		// Copy "worldhello", then paste over the selection at the end
+		keys(tcell.KeyHome, tcell.ModNone, 1),
+		keys(tcell.KeyRight, tcell.ModShift, len("worldhello")),
+		keys(tcell.KeyCtrlC, tcell.ModCtrl, 1),
+		keys(tcell.KeyEnd, tcell.ModNone, 1),
+		keys(tcell.KeyLeft, tcell.ModShift, 1),
+		keys(tcell.KeyCtrlV, tcell.ModCtrl, 1),
+		// Undoing the paste restores the replaced selection
+		keys(tcell.KeyCtrlZ, tcell.ModCtrl, 1),
+		keys(tcell.KeyCtrlW, tcell.ModCtrl, 1),
+		typed("D"),
+	)...)
+	ui.Display()
+
+	if ui.appstate.TextBuffer.String() != "worldhello " {
+		t.Fatalf("Expected \"worldhello \", instead buffer contents: \"%v\"", ui.appstate.TextBuffer.String())
+	}
+	if text, _ := ui.appstate.Clipboard.Get(); text != "worldhello" {
+		t.Fatalf("Expected \"worldhello\" on the clipboard, instead \"%v\"", text)
+	}
+}
+
+// Returns the events a terminal sends for a bracketed paste of `s`.
+func pasted(s string) []tcell.Event {
+	events := []tcell.Event{tcell.NewEventPaste(true)}
+	for _, r := range(s) {
+		switch r {
+		case '\r':
+			events = append(events, tcell.NewEventKey(tcell.KeyEnter, 0, tcell.ModNone))
+		case '\n':
+			events = append(events, tcell.NewEventKey(tcell.KeyLF, 0, tcell.ModNone))
+		case '\t':
+			events = append(events, tcell.NewEventKey(tcell.KeyTab, 0, tcell.ModNone))
+		default:
+			events = append(events, tcell.NewEventKey(tcell.KeyRune, r, tcell.ModNone))
+		}
+	}
+	return append(events, tcell.NewEventPaste(false))
+}
+
+func TestBracketedPaste(t *testing.T) {
+	ui, screen := newTestUI(t, "")
+
+	injectEvents(screen, seq(
+		typed("a b"),
+		keys(tcell.KeyLeft, tcell.ModShift, 1),
+		// Replaces the selection, with line ends normalised
+		pasted("one\r\n\ttwo\rthree\n"),
+		keys(tcell.KeyCtrlW, tcell.ModCtrl, 1),
+		typed("D"),
+	)...)
+	ui.Display()
+
+	if ui.appstate.TextBuffer.String() != "a one\n\ttwo\nthree\n" {
+		t.Fatalf("Unexpected buffer contents after paste: %q", ui.appstate.TextBuffer.String())
+	}
+
+	// The whole paste is undone in one step
+	ui.textbox().Undo()
+	if ui.appstate.TextBuffer.String() != "a b" {
+		t.Fatalf("Expected \"a b\" after undo, instead %q", ui.appstate.TextBuffer.String())
+	}
+}
+
+func TestMenus(t *testing.T) {
+	ui, screen := newTestUI(t, "")
+	cfg, configPath := config.Default(), filepath.Join(t.TempDir(), "config.json")
+	ui.SetConfig(&cfg, configPath)
+	alt := func(ch rune) []tcell.Event {
+		return []tcell.Event{tcell.NewEventKey(tcell.KeyRune, ch, tcell.ModAlt)}
+	}
+
+	injectEvents(screen, seq(
+		typed("abc"),
+		// Edit > Select All, then Edit > Cut
+		alt('e'), typed("a"),
+		alt('e'), typed("t"),
+		// Disabled items do nothing: Edit > Cut with no selection
+		alt('e'), typed("t"),
+		keys(tcell.KeyEscape, tcell.ModNone, 2),
+		// File menu, then Right to the Edit menu, then Paste
+		alt('f'), keys(tcell.KeyRight, tcell.ModNone, 1), typed("p"),
+		// View > Status Bar
+		alt('v'), keys(tcell.KeyEnter, tcell.ModNone, 1),
+		// File > Exit, without saving
+		alt('f'), keys(tcell.KeyUp, tcell.ModNone, 1), keys(tcell.KeyEnter, tcell.ModNone, 1),
+		typed("D"),
+	)...)
+	ui.Display()
+
+	if ui.appstate.TextBuffer.String() != "abc" {
+		t.Fatalf("Expected \"abc\", instead buffer contents: \"%v\"", ui.appstate.TextBuffer.String())
+	}
+	if !ui.appstate.Options.HideStatusBar {
+		t.Fatalf("Expected the status bar to be hidden")
+	}
+
+	// The change is saved to the config file
+	saved, err := config.Load(configPath)
+	if err != nil || saved.StatusBar || !saved.WordWrap {
+		t.Fatalf("Expected the status bar to be hidden in the saved config, instead %+v, %v", saved, err)
+	}
+}
+
+func TestOpenArgs(t *testing.T) {
+	dir := t.TempDir()
+	first, second := filepath.Join(dir, "first.txt"), filepath.Join(dir, "second.txt")
+	os.WriteFile(first, []byte("one\ntwo\nthree"), 0660)
+	os.WriteFile(second, []byte("second"), 0660)
+	ui, screen := newTestUI(t, "")
+
+	// Standard input is read into an untitled buffer, at the given position
+	args := cli.Args{Files: []cli.File{{Path: cli.STDIN_PATH, Line: 2, Col: 2}, {Path: first, Line: 3}, {Path: second}}, ReadOnly: true}
+	if err := ui.OpenArgs(args, strings.NewReader("from\nstdin")); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if ui.appstate.TextBuffer.String() != "from\nstdin" || ui.appstate.Filename != "" || ui.textbox().GetCursorIndex() != 6 {
+		t.Fatalf("Unexpected state after reading stdin: %q, %q, %d", ui.appstate.TextBuffer.String(), ui.appstate.Filename, ui.textbox().GetCursorIndex())
+	}
+
+	// Read-only: typing does nothing. Closing moves on to the next file, after offering to save the buffer read from stdin, and quits after the last.
+	injectEvents(screen, seq(
+		keys(tcell.KeyCtrlW, tcell.ModCtrl, 1),
+		typed("d"),
+		typed("abc"),
+		keys(tcell.KeyCtrlW, tcell.ModCtrl, 1),
+		typed("abc"),
+		keys(tcell.KeyCtrlW, tcell.ModCtrl, 1),
+	)...)
+	ui.Display()
+
+	if ui.appstate.Filename != second || ui.appstate.TextBuffer.String() != "second" {
+		t.Fatalf("Expected the last file to be open and unchanged, instead: %q, %q", ui.appstate.Filename, ui.appstate.TextBuffer.String())
+	}
+
+	// Files are opened at their position
+	if err := ui.OpenFile(args.Files[1]); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if ui.textbox().GetCursorIndex() != 8 {
+		t.Fatalf("Expected the cursor at the start of line 3, instead at %d", ui.textbox().GetCursorIndex())
+	}
+}
+
+func TestGoToLine(t *testing.T) {
+	ui, screen := newTestUI(t, "")
+	ctrlG := tcell.NewEventKey(tcell.KeyCtrlG, 0, tcell.ModCtrl)
+	for _, ev := range(seq(typed("one"), keys(tcell.KeyEnter, tcell.ModNone, 1), typed("two"), keys(tcell.KeyEnter, tcell.ModNone, 1), typed("three"), keys(tcell.KeyEnter, tcell.ModNone, 1), typed("four"))) {
+		ui.handleKeyEvent(ev.(*tcell.EventKey))
+	}
+	expectCursor := func(index int) {
+		t.Helper()
+		if cursor := ui.textbox().GetCursorIndex(); cursor != index {
+			t.Fatalf("Expected cursor at %d, instead %d", index, cursor)
+		}
+	}
+
+	// The prompt starts with the current line
+	injectEvents(screen, seq(keys(tcell.KeyBackspace2, tcell.ModNone, 1), typed("50%"), keys(tcell.KeyEnter, tcell.ModNone, 1))...)
+	ui.handleKeyEvent(ctrlG)
+	expectCursor(4)
+
+	// Lines outside the file are reported, then asked for again
+	injectEvents(screen, seq(
+		keys(tcell.KeyBackspace2, tcell.ModNone, 1), typed("9"), keys(tcell.KeyEnter, tcell.ModNone, 2),
+		keys(tcell.KeyBackspace2, tcell.ModNone, 1), typed("+1:3"), keys(tcell.KeyEnter, tcell.ModNone, 1),
+	)...)
+	ui.handleKeyEvent(ctrlG)
+	expectCursor(10)
+}
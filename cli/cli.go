@@ -0,0 +1,182 @@
+// Parses the command line arguments of notepad--.
+package cli
+
+import (
+	"fmt"
+	"strings"
+	"github.com/Rye123/notepad--/textbuffer"
+	"github.com/Rye123/notepad--/util"
+)
+
+// Path that reads the initial buffer from standard input
+const STDIN_PATH = "-"
+
+const USAGE = `Usage: notepad-- [options] [[+LINE[:COL]] FILE]...
+
+Opens each FILE in turn: the next one is opened when the previous one is closed.
+A first FILE of - reads the initial buffer from standard input.
++LINE[:COL] puts the cursor at line LINE, column COL of the FILE after it.
+
+Options:
+  --readonly             don't allow the files to be edited
+  --encoding NAME        read and save the files in the encoding NAME, e.g. UTF-8 or Windows-1252
+  --line-ending MODE     save the files with LF, CRLF or CR line ends
+  --no-wrap              turn word wrap off
+  --buffer KIND          store the text in a gap buffer (gap, the default), a piece table (piece) or a rope (rope)
+  --config PATH          read settings from PATH instead of $XDG_CONFIG_HOME/notepad--/config.json
+  --version              print the version and exit
+  -h, --help             print this help and exit
+`
+
+// A file to open, and where to put the cursor in it.
+type File struct {
+	Path string // STDIN_PATH to read from standard input
+	Line int // 1-based line to put the cursor on, or 0 if not given
+	Col int // 1-based column to put the cursor on, or 0 if not given
+}
+
+// Args: The parsed command line.
+type Args struct {
+	Files []File
+	ReadOnly bool
+	Encoding string // "" to detect the encoding of each file
+	LineEnding string // "" to keep the line ends of each file
+	NoWrap bool
+	Buffer string // Kind of text buffer, one of textbuffer.Kinds, or "" for the default
+	ConfigPath string // "" for the default path
+	Version bool
+	Help bool
+}
+
+// Parses the command line arguments `args`, not including the program name.
+// Options may be given as --name or -name, and options taking a value as "--name VALUE" or "--name=VALUE". Everything after "--" is a file.
+func Parse(args []string) (Args, error) {
+	parsed := Args{Files: make([]File, 0)}
+	var position *File // Position given for the next file
+
+	addFile := func(path string) error {
+		file := File{Path: path}
+		if position != nil {
+			file.Line, file.Col = position.Line, position.Col
+			position = nil
+		}
+		if path == STDIN_PATH && len(parsed.Files) > 0 {
+			return fmt.Errorf("standard input (-) must be the first file")
+		}
+		parsed.Files = append(parsed.Files, file)
+		return nil
+	}
+
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+
+		// Files
+		if arg == "--" {
+			for _, path := range(args[i + 1:]) {
+				if err := addFile(path); err != nil {
+					return parsed, err
+				}
+			}
+			break
+		}
+		if arg == STDIN_PATH || !strings.HasPrefix(arg, "-") && !strings.HasPrefix(arg, "+") {
+			if err := addFile(arg); err != nil {
+				return parsed, err
+			}
+			continue
+		}
+		if strings.HasPrefix(arg, "+") {
+			if position != nil {
+				return parsed, fmt.Errorf("%v: expected a file after +%v", arg, positionString(*position))
+			}
+			line, col, err := parsePosition(arg[1:])
+			if err != nil {
+				return parsed, fmt.Errorf("%v: %v", arg, err)
+			}
+			position = &File{"", line, col}
+			continue
+		}
+
+		// Options
+		name, value, hasValue := strings.Cut(strings.TrimLeft(arg, "-"), "=")
+		takeValue := func() (string, error) {
+			if hasValue {
+				return value, nil
+			}
+			if i + 1 >= len(args) {
+				return "", fmt.Errorf("%v: expected a value", arg)
+			}
+			i++
+			return args[i], nil
+		}
+		noValue := func() error {
+			if hasValue {
+				return fmt.Errorf("%v: doesn't take a value", arg)
+			}
+			return nil
+		}
+
+		var err error
+		switch name {
+		case "readonly":
+			parsed.ReadOnly, err = true, noValue()
+		case "no-wrap":
+			parsed.NoWrap, err = true, noValue()
+		case "version":
+			parsed.Version, err = true, noValue()
+		case "h", "help":
+			parsed.Help, err = true, noValue()
+		case "config":
+			parsed.ConfigPath, err = takeValue()
+		case "encoding":
+			if value, err = takeValue(); err == nil {
+				parsed.Encoding, err = matchChoice("encoding", value, util.Encodings)
+			}
+		case "buffer":
+			if value, err = takeValue(); err == nil {
+				parsed.Buffer, err = matchChoice("buffer", value, textbuffer.Kinds)
+			}
+		case "line-ending":
+			if value, err = takeValue(); err == nil {
+				parsed.LineEnding, err = matchChoice("line ending", value, []string{util.LINE_END_LF, util.LINE_END_CRLF, util.LINE_END_CR})
+			}
+		default:
+			err = fmt.Errorf("unknown option %v", arg)
+		}
+		if err != nil {
+			return parsed, err
+		}
+	}
+
+	if position != nil {
+		return parsed, fmt.Errorf("+%v: expected a file after it", positionString(*position))
+	}
+	return parsed, nil
+}
+
+// Parses LINE or LINE:COL, where both are positive. Positions relative to the cursor or the length of the file can't be given on the command line.
+func parsePosition(s string) (line int, col int, err error) {
+	pos, err := util.ParsePosition(s)
+	if err != nil {
+		return 0, 0, err
+	}
+	if pos.Relative || pos.Percent {
+		return 0, 0, fmt.Errorf("expected a line number of 1 or more")
+	}
+	return pos.Line, pos.Col, nil
+}
+
+func positionString(position File) string {
+	return util.Position{Line: position.Line, Col: position.Col}.String()
+}
+
+// Returns the choice that `value` names, ignoring case, spaces, hyphens and underscores, e.g. "utf16le" names "UTF-16 LE".
+func matchChoice(kind, value string, choices []string) (string, error) {
+	simplify := strings.NewReplacer(" ", "", "-", "", "_", "")
+	for _, choice := range(choices) {
+		if strings.EqualFold(simplify.Replace(choice), simplify.Replace(value)) {
+			return choice, nil
+		}
+	}
+	return "", fmt.Errorf("unknown %v %q: expected one of %v", kind, value, strings.Join(choices, ", "))
+}
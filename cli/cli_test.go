@@ -0,0 +1,65 @@
+package cli
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"github.com/Rye123/notepad--/textbuffer"
+	"github.com/Rye123/notepad--/util"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		args []string
+		expected Args
+	}{
+		{nil, Args{Files: []File{}}},
+		{[]string{"a.txt", "b.txt"}, Args{Files: []File{{"a.txt", 0, 0}, {"b.txt", 0, 0}}}},
+		{[]string{"+12", "a.txt", "+3:4", "b.txt", "c.txt"}, Args{Files: []File{{"a.txt", 12, 0}, {"b.txt", 3, 4}, {"c.txt", 0, 0}}}},
+		{[]string{"-", "a.txt"}, Args{Files: []File{{STDIN_PATH, 0, 0}, {"a.txt", 0, 0}}}},
+		{
+			[]string{"--readonly", "--no-wrap", "--encoding", "utf16le", "--line-ending=lf", "-config", "my.json", "a.txt"},
+			Args{Files: []File{{"a.txt", 0, 0}}, ReadOnly: true, NoWrap: true, Encoding: util.ENCODING_UTF16LE, LineEnding: util.LINE_END_LF, ConfigPath: "my.json"},
+		},
+		{[]string{"--buffer", "Piece"}, Args{Files: []File{}, Buffer: textbuffer.PIECE_TABLE}},
+		{[]string{"--version"}, Args{Files: []File{}, Version: true}},
+		{[]string{"-h"}, Args{Files: []File{}, Help: true}},
+		{[]string{"--", "--help", "+1"}, Args{Files: []File{{"--help", 0, 0}, {"+1", 0, 0}}}},
+	}
+	for _, test := range(tests) {
+		args, err := Parse(test.args)
+		if err != nil {
+			t.Fatalf("Unexpected error for %q: %v", test.args, err)
+		}
+		if !reflect.DeepEqual(args, test.expected) {
+			t.Fatalf("Expected %+v for %q, instead %+v", test.expected, test.args, args)
+		}
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		args []string
+		expected string // Part of the error message
+	}{
+		{[]string{"--bogus"}, "unknown option --bogus"},
+		{[]string{"--encoding"}, "expected a value"},
+		{[]string{"--encoding", "EBCDIC"}, "unknown encoding \"EBCDIC\""},
+		{[]string{"--line-ending=LFCR"}, "unknown line ending"},
+		{[]string{"--buffer=tree"}, "unknown buffer \"tree\""},
+		{[]string{"--readonly=yes"}, "doesn't take a value"},
+		{[]string{"+0", "a.txt"}, "line number"},
+		{[]string{"+-5", "a.txt"}, "line number"},
+		{[]string{"+50%", "a.txt"}, "line number"},
+		{[]string{"+1:x", "a.txt"}, "column number"},
+		{[]string{"a.txt", "+5"}, "expected a file"},
+		{[]string{"+5", "+6", "a.txt"}, "expected a file after +5"},
+		{[]string{"a.txt", "-"}, "must be the first file"},
+	}
+	for _, test := range(tests) {
+		_, err := Parse(test.args)
+		if err == nil || !strings.Contains(err.Error(), test.expected) {
+			t.Fatalf("Expected error containing %q for %q, instead %v", test.expected, test.args, err)
+		}
+	}
+}
@@ -0,0 +1,152 @@
+package clipboard
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// Clipboard: Somewhere to cut and copy text to, and paste text from.
+type Clipboard interface {
+	// Returns the text on the clipboard.
+	Get() (string, error)
+	// Replaces the text on the clipboard with `text`.
+	Set(text string) error
+}
+
+/* INTERNAL CLIPBOARD */
+
+// Internal: A clipboard that only lives as long as the process.
+type Internal struct {
+	text string
+}
+
+func NewInternal() *Internal {
+	return &Internal{""}
+}
+
+func (clip *Internal) Get() (string, error) {
+	return clip.text, nil
+}
+
+func (clip *Internal) Set(text string) error {
+	clip.text = text
+	return nil
+}
+
+/* OSC 52 CLIPBOARD */
+
+// OSC52: A clipboard that copies to the terminal's clipboard with the OSC 52 escape sequence.
+// Most terminals don't allow programs to read their clipboard, so pasting returns the last text copied by this process; text pasted from outside arrives through the terminal's own paste instead.
+type OSC52 struct {
+	out io.Writer
+	last Internal
+}
+
+// Returns an OSC 52 clipboard that writes escape sequences to `out`, which should be the terminal.
+func NewOSC52(out io.Writer) *OSC52 {
+	return &OSC52{out, Internal{""}}
+}
+
+func (clip *OSC52) Get() (string, error) {
+	return clip.last.Get()
+}
+
+func (clip *OSC52) Set(text string) error {
+	clip.last.Set(text)
+	seq := "\x1b]52;c;" + base64.StdEncoding.EncodeToString([]byte(text)) + "\a"
+	_, err := io.WriteString(clip.out, seq)
+	return err
+}
+
+/* EXTERNAL COMMAND CLIPBOARD */
+
+// Longest time to wait for the copy command's output to be closed after it exits
+const COPY_WAIT_DELAY = 100 * time.Millisecond
+
+// Command: A clipboard that runs external commands such as xclip or wl-copy.
+// The copy command reads the text from its standard input, and the paste command writes the text to its standard output.
+type Command struct {
+	copyCommand []string
+	pasteCommand []string
+}
+
+// Returns a clipboard that runs `copyCommand` to copy and `pasteCommand` to paste. Each is a program path followed by its arguments.
+func NewCommand(copyCommand, pasteCommand []string) (*Command, error) {
+	if len(copyCommand) == 0 || len(pasteCommand) == 0 {
+		return nil, errors.New("clipboard commands must not be empty")
+	}
+	return &Command{copyCommand, pasteCommand}, nil
+}
+
+func (clip *Command) Get() (string, error) {
+	cmd := exec.Command(clip.pasteCommand[0], clip.pasteCommand[1:]...)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return "", commandError(clip.pasteCommand[0], err, stderr.String())
+	}
+	return string(out), nil
+}
+
+// Runs the copy command with `text` as its input. Copy tools such as xclip and xsel leave a child process running to own the clipboard, which keeps the command's standard error open, so its output is only waited for until COPY_WAIT_DELAY after the command exits.
+func (clip *Command) Set(text string) error {
+	cmd := exec.Command(clip.copyCommand[0], clip.copyCommand[1:]...)
+	var stderr bytes.Buffer
+	cmd.Stdin = strings.NewReader(text)
+	cmd.Stderr = &stderr
+	cmd.WaitDelay = COPY_WAIT_DELAY
+	if err := cmd.Run(); err != nil && !errors.Is(err, exec.ErrWaitDelay) {
+		return commandError(clip.copyCommand[0], err, stderr.String())
+	}
+	return nil
+}
+
+func commandError(name string, err error, stderr string) error {
+	if stderr = strings.TrimSpace(stderr); stderr != "" {
+		return fmt.Errorf("%v: %v: %v", name, err, stderr)
+	}
+	return fmt.Errorf("%v: %v", name, err)
+}
+
+/* DETECTION */
+
+// Returns the best clipboard available: the Wayland or X11 clipboard tools if they are installed and a display is available, then OSC 52 if running in a terminal, then the internal clipboard.
+func Detect(terminal io.Writer) Clipboard {
+	if os.Getenv("WAYLAND_DISPLAY") != "" {
+		if clip := lookupCommand([]string{"wl-copy"}, []string{"wl-paste", "--no-newline"}); clip != nil {
+			return clip
+		}
+	}
+	if os.Getenv("DISPLAY") != "" {
+		if clip := lookupCommand([]string{"xclip", "-selection", "clipboard"}, []string{"xclip", "-selection", "clipboard", "-o"}); clip != nil {
+			return clip
+		}
+		if clip := lookupCommand([]string{"xsel", "--clipboard", "--input"}, []string{"xsel", "--clipboard", "--output"}); clip != nil {
+			return clip
+		}
+	}
+	if terminal != nil && os.Getenv("TERM") != "" {
+		return NewOSC52(terminal)
+	}
+	return NewInternal()
+}
+
+// Returns a Command clipboard if both programs are on the PATH, otherwise nil.
+func lookupCommand(copyCommand, pasteCommand []string) *Command {
+	if _, err := exec.LookPath(copyCommand[0]); err != nil {
+		return nil
+	}
+	if _, err := exec.LookPath(pasteCommand[0]); err != nil {
+		return nil
+	}
+	clip, _ := NewCommand(copyCommand, pasteCommand)
+	return clip
+}
@@ -0,0 +1,106 @@
+package clipboard
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestInternal(t *testing.T) {
+	clip := NewInternal()
+	if text, _ := clip.Get(); text != "" {
+		t.Fatalf("Expected empty clipboard, instead \"%v\"", text)
+	}
+	clip.Set("hello\nworld")
+	if text, _ := clip.Get(); text != "hello\nworld" {
+		t.Fatalf("Expected \"hello\\nworld\", instead \"%v\"", text)
+	}
+}
+
+func TestOSC52(t *testing.T) {
+	var out bytes.Buffer
+	clip := NewOSC52(&out)
+	if err := clip.Set("hello"); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if out.String() != "\x1b]52;c;aGVsbG8=\a" {
+		t.Fatalf("Unexpected escape sequence: %q", out.String())
+	}
+	if text, _ := clip.Get(); text != "hello" {
+		t.Fatalf("Expected the last copied text, instead \"%v\"", text)
+	}
+}
+
+// Writes a fake clipboard tool to a temporary directory: the copy command saves its input to a file, and the paste command prints it.
+func fakeCommands(t *testing.T) (copyCommand, pasteCommand []string) {
+	dir := t.TempDir()
+	store := filepath.Join(dir, "clipboard")
+	script := filepath.Join(dir, "fakeclip")
+	contents := "#!/bin/sh\n" +
+		"case \"$1\" in\n" +
+		"copy) cat > \"" + store + "\" ;;\n" +
+		"paste) cat \"" + store + "\" ;;\n" +
+		// Like xclip, leaves a child running in the background that holds the clipboard, and standard error
+		"copy-background) cat > \"" + store + "\"; sleep 5 & ;;\n" +
+		"*) echo \"bad mode\" >&2; exit 1 ;;\n" +
+		"esac\n"
+	if err := os.WriteFile(script, []byte(contents), 0755); err != nil {
+		t.Fatal(err)
+	}
+	return []string{script, "copy"}, []string{script, "paste"}
+}
+
+func TestCommand(t *testing.T) {
+	copyCommand, pasteCommand := fakeCommands(t)
+	clip, err := NewCommand(copyCommand, pasteCommand)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	text := "multi\nline\ttext ünïcode"
+	if err := clip.Set(text); err != nil {
+		t.Fatalf("Unexpected error on copy: %v", err)
+	}
+	got, err := clip.Get()
+	if err != nil {
+		t.Fatalf("Unexpected error on paste: %v", err)
+	}
+	if got != text {
+		t.Fatalf("Expected %q, instead %q", text, got)
+	}
+}
+
+func TestCommandErrors(t *testing.T) {
+	if _, err := NewCommand(nil, []string{"cat"}); err == nil {
+		t.Fatalf("Expected an error for an empty command")
+	}
+
+	copyCommand, _ := fakeCommands(t)
+	clip, _ := NewCommand(copyCommand, []string{copyCommand[0], "bad"})
+	if _, err := clip.Get(); err == nil {
+		t.Fatalf("Expected an error from a failing paste command")
+	}
+	clip, _ = NewCommand([]string{filepath.Join(t.TempDir(), "missing")}, []string{"cat"})
+	if err := clip.Set("text"); err == nil {
+		t.Fatalf("Expected an error from a missing copy command")
+	}
+}
+
+func TestCommandBackgroundChild(t *testing.T) {
+	copyCommand, pasteCommand := fakeCommands(t)
+	clip, _ := NewCommand([]string{copyCommand[0], "copy-background"}, pasteCommand)
+
+	// Copying returns once the command exits, without waiting for its child
+	start := time.Now()
+	if err := clip.Set("text"); err != nil {
+		t.Fatalf("Unexpected error on copy: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 2 * time.Second {
+		t.Fatalf("Expected copying not to wait for the background child, instead took %v", elapsed)
+	}
+	if got, _ := clip.Get(); got != "text" {
+		t.Fatalf("Expected \"text\", instead %q", got)
+	}
+}
@@ -0,0 +1,76 @@
+package command
+
+// The key bindings used unless configured otherwise, from key sequence to command name.
+var DefaultBindings = map[string]string{
+	// File
+	"Ctrl+N": "file.new",
+	"Ctrl+O": "file.open",
+	"Ctrl+S": "file.save",
+	"Ctrl+Alt+S": "file.saveAs",
+	"Ctrl+W": "file.exit",
+
+	// Edit
+	"Ctrl+Z": "edit.undo",
+	"Ctrl+Y": "edit.redo",
+	"Ctrl+X": "edit.cut",
+	"Ctrl+C": "edit.copy",
+	"Ctrl+V": "edit.paste",
+	"Ctrl+A": "edit.selectAll",
+	"F5": "edit.timeDate",
+	"Ctrl+F": "edit.find",
+	"F3": "edit.findNext",
+	"Shift+F3": "edit.findPrevious",
+	"Ctrl+H": "edit.replace",
+	"Ctrl+G": "edit.goTo",
+	"Backspace": "edit.backspace",
+	"Delete": "edit.delete",
+	"Enter": "edit.newline",
+	"Tab": "edit.tab",
+
+	// Cursor movement
+	"Left": "cursor.left",
+	"Right": "cursor.right",
+	"Up": "cursor.lineUp",
+	"Down": "cursor.lineDown",
+	"PgUp": "cursor.pageUp",
+	"PgDn": "cursor.pageDown",
+	"Home": "cursor.lineStart",
+	"End": "cursor.lineEnd",
+	"Ctrl+Home": "cursor.bufferStart",
+	"Ctrl+End": "cursor.bufferEnd",
+
+	// Selection
+	"Shift+Left": "select.left",
+	"Shift+Right": "select.right",
+	"Shift+Up": "select.lineUp",
+	"Shift+Down": "select.lineDown",
+	"Shift+PgUp": "select.pageUp",
+	"Shift+PgDn": "select.pageDown",
+	"Shift+Home": "select.lineStart",
+	"Shift+End": "select.lineEnd",
+	"Ctrl+Shift+Home": "select.bufferStart",
+	"Ctrl+Shift+End": "select.bufferEnd",
+
+	// View
+	"Ctrl+Up": "view.scrollUp",
+	"Ctrl+Down": "view.scrollDown",
+
+	// Menus
+	"F10": "menu.focus",
+	"Alt+F": "menu.file",
+	"Alt+E": "menu.edit",
+	"Alt+O": "menu.format",
+	"Alt+V": "menu.view",
+	"Alt+H": "menu.help",
+}
+
+// Returns a keymap with the default key bindings.
+func DefaultKeymap() *Keymap {
+	keymap := NewKeymap()
+	for sequence, name := range(DefaultBindings) {
+		if err := keymap.Bind(sequence, name); err != nil {
+			panic(err)
+		}
+	}
+	return keymap
+}
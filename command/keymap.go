@@ -0,0 +1,273 @@
+package command
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"unicode"
+	"github.com/gdamore/tcell/v2"
+)
+
+// Chord: A single key press, along with its modifiers.
+type Chord struct {
+	Key tcell.Key
+	Rune rune // For KeyRune, the character. Letters are stored in lowercase when modified.
+	Mod tcell.ModMask
+}
+
+// Names of the keys that can be used in chords, other than characters and Ctrl+letter.
+var keyNames = map[tcell.Key]string{
+	tcell.KeyEnter: "Enter",
+	tcell.KeyBackspace: "Backspace",
+	tcell.KeyTab: "Tab",
+	tcell.KeyBacktab: "Backtab",
+	tcell.KeyEsc: "Esc",
+	tcell.KeyDelete: "Delete",
+	tcell.KeyInsert: "Insert",
+	tcell.KeyUp: "Up",
+	tcell.KeyDown: "Down",
+	tcell.KeyLeft: "Left",
+	tcell.KeyRight: "Right",
+	tcell.KeyHome: "Home",
+	tcell.KeyEnd: "End",
+	tcell.KeyPgUp: "PgUp",
+	tcell.KeyPgDn: "PgDn",
+}
+
+func init() {
+	for i := 0; i < 12; i++ {
+		keyNames[tcell.KeyF1 + tcell.Key(i)] = fmt.Sprintf("F%d", i + 1)
+	}
+}
+
+// Returns the chord of a key event.
+func ChordFromEvent(keyEvent *tcell.EventKey) Chord {
+	key, ch, mod := keyEvent.Key(), keyEvent.Rune(), keyEvent.Modifiers()
+	mod &^= tcell.ModMeta
+
+	switch {
+	case key == tcell.KeyBackspace2:
+		// Terminals differ in which code Backspace sends
+		return Chord{tcell.KeyBackspace, 0, mod}
+	case key >= tcell.KeyCtrlA && key <= tcell.KeyCtrlZ && mod & tcell.ModCtrl != 0:
+		// Ctrl+letter arrives as a control code
+		return Chord{tcell.KeyRune, rune('a' + key - tcell.KeyCtrlA), mod}
+	case key == tcell.KeyCtrlSpace:
+		return Chord{tcell.KeyRune, ' ', mod | tcell.ModCtrl}
+	case key == tcell.KeyRune && mod & (tcell.ModCtrl | tcell.ModAlt) != 0:
+		// Shift is part of the character, so it is only kept for unmodified keys
+		return Chord{tcell.KeyRune, unicode.ToLower(ch), mod &^ tcell.ModShift}
+	case key == tcell.KeyRune:
+		return Chord{tcell.KeyRune, ch, tcell.ModNone}
+	}
+	return Chord{key, 0, mod}
+}
+
+// Parses a chord written as modifiers and a key joined by "+", e.g. "Ctrl+S", "Ctrl+Shift+Home" or "F5".
+func ParseChord(s string) (Chord, error) {
+	parts := strings.Split(s, "+")
+	if strings.HasSuffix(s, "++") || s == "+" {
+		// The plus key itself
+		parts = append(parts[:len(parts) - 2], "+")
+	}
+
+	var mod tcell.ModMask
+	for _, part := range(parts[:len(parts) - 1]) {
+		switch strings.ToLower(part) {
+		case "ctrl":
+			mod |= tcell.ModCtrl
+		case "alt":
+			mod |= tcell.ModAlt
+		case "shift":
+			mod |= tcell.ModShift
+		default:
+			return Chord{}, fmt.Errorf("unknown modifier %q in %q", part, s)
+		}
+	}
+
+	name := parts[len(parts) - 1]
+	if name == "" {
+		return Chord{}, fmt.Errorf("missing key in %q", s)
+	}
+	for key, keyName := range(keyNames) {
+		if strings.EqualFold(name, keyName) {
+			return Chord{key, 0, mod}, nil
+		}
+	}
+	if strings.EqualFold(name, "Space") {
+		name = " "
+	}
+	if runes := []rune(name); len(runes) == 1 {
+		ch := runes[0]
+		if mod & (tcell.ModCtrl | tcell.ModAlt) != 0 {
+			return Chord{tcell.KeyRune, unicode.ToLower(ch), mod &^ tcell.ModShift}, nil
+		}
+		if mod != tcell.ModNone {
+			return Chord{}, fmt.Errorf("characters can only be modified with Ctrl or Alt in %q", s)
+		}
+		return Chord{tcell.KeyRune, ch, tcell.ModNone}, nil
+	}
+	return Chord{}, fmt.Errorf("unknown key %q in %q", name, s)
+}
+
+// Returns the chord as it is written in key bindings, e.g. "Ctrl+Alt+S".
+func (chord Chord) String() string {
+	var b strings.Builder
+	if chord.Mod & tcell.ModCtrl != 0 {
+		b.WriteString("Ctrl+")
+	}
+	if chord.Mod & tcell.ModAlt != 0 {
+		b.WriteString("Alt+")
+	}
+	if chord.Mod & tcell.ModShift != 0 {
+		b.WriteString("Shift+")
+	}
+
+	switch {
+	case chord.Key != tcell.KeyRune:
+		if name, ok := keyNames[chord.Key]; ok {
+			b.WriteString(name)
+		} else {
+			fmt.Fprintf(&b, "Key[%d]", chord.Key)
+		}
+	case chord.Rune == ' ':
+		b.WriteString("Space")
+	case chord.Mod != tcell.ModNone:
+		b.WriteRune(unicode.ToUpper(chord.Rune))
+	default:
+		b.WriteRune(chord.Rune)
+	}
+	return b.String()
+}
+
+// Parses a sequence of chords separated by spaces, e.g. "Ctrl+K Ctrl+U", returning it in its standard form.
+func ParseSequence(s string) (string, error) {
+	fields := strings.Fields(s)
+	if len(fields) == 0 {
+		return "", fmt.Errorf("empty key sequence")
+	}
+	chords := make([]string, len(fields))
+	for i, field := range(fields) {
+		chord, err := ParseChord(field)
+		if err != nil {
+			return "", err
+		}
+		chords[i] = chord.String()
+	}
+	return strings.Join(chords, " "), nil
+}
+
+// Results of handing a key event to a keymap
+type KeyResult int
+const (
+	KEY_UNBOUND KeyResult = iota // The key isn't bound to anything, and should be handled as usual, e.g. typed
+	KEY_PENDING // The key starts a sequence, and the next key is needed
+	KEY_CANCELLED // The key didn't continue the pending sequence, which was discarded along with the key
+	KEY_BOUND // The key completed a sequence bound to a command
+)
+
+// Keymap: Maps key sequences of one or more chords, e.g. "Ctrl+S" or "Ctrl+K Ctrl+U", to command names.
+type Keymap struct {
+	bindings map[string]string // Standard form of each sequence, to command name
+	pending []string // Chords of the sequence typed so far
+}
+
+func NewKeymap() *Keymap {
+	return &Keymap{make(map[string]string), make([]string, 0)}
+}
+
+// Binds the key sequence `sequence` to the command `name`, replacing any existing binding of the sequence.
+// A sequence can't be bound if it starts with another bound sequence, or if another bound sequence starts with it.
+func (keymap *Keymap) Bind(sequence, name string) error {
+	sequence, err := ParseSequence(sequence)
+	if err != nil {
+		return err
+	}
+	for other := range(keymap.bindings) {
+		if other != sequence && (strings.HasPrefix(other, sequence + " ") || strings.HasPrefix(sequence, other + " ")) {
+			return fmt.Errorf("%q conflicts with the binding %q", sequence, other)
+		}
+	}
+	keymap.bindings[sequence] = name
+	return nil
+}
+
+// Removes the binding of `sequence`, if any.
+func (keymap *Keymap) Unbind(sequence string) error {
+	sequence, err := ParseSequence(sequence)
+	if err != nil {
+		return err
+	}
+	delete(keymap.bindings, sequence)
+	return nil
+}
+
+// Returns a copy of the bindings, from key sequence to command name.
+func (keymap *Keymap) Bindings() map[string]string {
+	bindings := make(map[string]string, len(keymap.bindings))
+	for sequence, name := range(keymap.bindings) {
+		bindings[sequence] = name
+	}
+	return bindings
+}
+
+// Returns the shortest key sequence bound to the command `name`, or "" if it isn't bound.
+func (keymap *Keymap) BindingFor(name string) string {
+	sequences := make([]string, 0)
+	for sequence, bound := range(keymap.bindings) {
+		if bound == name {
+			sequences = append(sequences, sequence)
+		}
+	}
+	sort.Slice(sequences, func(i, j int) bool {
+		if len(sequences[i]) != len(sequences[j]) {
+			return len(sequences[i]) < len(sequences[j])
+		}
+		return sequences[i] < sequences[j]
+	})
+	if len(sequences) == 0 {
+		return ""
+	}
+	return sequences[0]
+}
+
+// Returns the chords of the sequence typed so far, or "" if no sequence is pending.
+func (keymap *Keymap) Pending() string {
+	return strings.Join(keymap.pending, " ")
+}
+
+// Handles a key press. Returns the name of the bound command once a sequence is complete.
+func (keymap *Keymap) Handle(keyEvent *tcell.EventKey) (string, KeyResult) {
+	chord := ChordFromEvent(keyEvent).String()
+	sequence := strings.Join(append(keymap.pending, chord), " ")
+	hadPending := len(keymap.pending) > 0
+
+	if name, ok := keymap.bindings[sequence]; ok {
+		keymap.pending = keymap.pending[:0]
+		return name, KEY_BOUND
+	}
+	for other := range(keymap.bindings) {
+		if strings.HasPrefix(other, sequence + " ") {
+			keymap.pending = append(keymap.pending, chord)
+			return "", KEY_PENDING
+		}
+	}
+
+	keymap.pending = keymap.pending[:0]
+	if hadPending {
+		return "", KEY_CANCELLED
+	}
+	return "", KEY_UNBOUND
+}
+
+// Handles a key press, running the bound command from `registry` once a sequence is complete. Returns false if the key should be handled as usual instead, i.e. if it isn't bound, or its command is missing or disabled.
+func (keymap *Keymap) Dispatch(keyEvent *tcell.EventKey, registry *Registry) bool {
+	name, result := keymap.Handle(keyEvent)
+	switch result {
+	case KEY_PENDING, KEY_CANCELLED:
+		return true
+	case KEY_BOUND:
+		return registry.Run(name) == nil
+	}
+	return false
+}
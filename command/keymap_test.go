@@ -0,0 +1,135 @@
+package command
+
+import (
+	"testing"
+	"github.com/gdamore/tcell/v2"
+)
+
+func TestParseChord(t *testing.T) {
+	tests := []struct {
+		input string
+		expected string
+	}{
+		{"Ctrl+S", "Ctrl+S"},
+		{"ctrl+alt+s", "Ctrl+Alt+S"},
+		{"Ctrl+Shift+S", "Ctrl+S"}, // Shift is part of the character
+		{"Shift+Home", "Shift+Home"},
+		{"Ctrl+Shift+home", "Ctrl+Shift+Home"},
+		{"f5", "F5"},
+		{"Alt+Space", "Alt+Space"},
+		{"Ctrl++", "Ctrl++"},
+		{"x", "x"},
+		{"Backspace", "Backspace"},
+	}
+	for _, test := range(tests) {
+		chord, err := ParseChord(test.input)
+		if err != nil {
+			t.Fatalf("Unexpected error parsing %q: %v", test.input, err)
+		}
+		if chord.String() != test.expected {
+			t.Fatalf("Expected %q to parse as %q, instead %q", test.input, test.expected, chord.String())
+		}
+	}
+
+	for _, input := range([]string{"", "Ctrl+", "Hyper+S", "Ctrl+Foo", "Shift+x"}) {
+		if _, err := ParseChord(input); err == nil {
+			t.Fatalf("Expected an error parsing %q", input)
+		}
+	}
+}
+
+func TestChordFromEvent(t *testing.T) {
+	tests := []struct {
+		event *tcell.EventKey
+		expected string
+	}{
+		{tcell.NewEventKey(tcell.KeyCtrlS, 0, tcell.ModCtrl), "Ctrl+S"},
+		{tcell.NewEventKey(tcell.KeyCtrlS, 0, tcell.ModCtrl | tcell.ModAlt), "Ctrl+Alt+S"},
+		{tcell.NewEventKey(tcell.KeyRune, 'F', tcell.ModAlt), "Alt+F"},
+		{tcell.NewEventKey(tcell.KeyRune, 'F', tcell.ModNone), "F"},
+		{tcell.NewEventKey(tcell.KeyBackspace2, 0, tcell.ModNone), "Backspace"},
+		{tcell.NewEventKey(tcell.KeyTab, 0, tcell.ModNone), "Tab"},
+		{tcell.NewEventKey(tcell.KeyEnter, 0, tcell.ModNone), "Enter"},
+		{tcell.NewEventKey(tcell.KeyHome, 0, tcell.ModCtrl | tcell.ModShift), "Ctrl+Shift+Home"},
+	}
+	for _, test := range(tests) {
+		if chord := ChordFromEvent(test.event).String(); chord != test.expected {
+			t.Fatalf("Expected %q for %v, instead %q", test.expected, test.event.Name(), chord)
+		}
+	}
+}
+
+func ctrl(ch rune) *tcell.EventKey {
+	return tcell.NewEventKey(tcell.KeyCtrlA + tcell.Key(ch - 'a'), 0, tcell.ModCtrl)
+}
+
+func TestKeymapSequences(t *testing.T) {
+	keymap := NewKeymap()
+	keymap.Bind("Ctrl+S", "file.save")
+	keymap.Bind("ctrl+k  ctrl+a", "edit.selectAll")
+
+	if name, result := keymap.Handle(ctrl('s')); result != KEY_BOUND || name != "file.save" {
+		t.Fatalf("Expected Ctrl+S to be bound to file.save, instead %q (%v)", name, result)
+	}
+
+	// Multi-key sequences wait for the next key
+	if _, result := keymap.Handle(ctrl('k')); result != KEY_PENDING || keymap.Pending() != "Ctrl+K" {
+		t.Fatalf("Expected Ctrl+K to be pending, instead %v", result)
+	}
+	if name, result := keymap.Handle(ctrl('a')); result != KEY_BOUND || name != "edit.selectAll" {
+		t.Fatalf("Expected Ctrl+K Ctrl+A to be bound to edit.selectAll, instead %q (%v)", name, result)
+	}
+
+	// A key that doesn't continue the sequence cancels it
+	keymap.Handle(ctrl('k'))
+	if _, result := keymap.Handle(ctrl('s')); result != KEY_CANCELLED || keymap.Pending() != "" {
+		t.Fatalf("Expected Ctrl+K Ctrl+S to be cancelled, instead %v", result)
+	}
+	if _, result := keymap.Handle(tcell.NewEventKey(tcell.KeyRune, 'a', tcell.ModNone)); result != KEY_UNBOUND {
+		t.Fatalf("Expected typing to be unbound, instead %v", result)
+	}
+
+	// Sequences can't be prefixes of each other
+	if err := keymap.Bind("Ctrl+K", "other"); err == nil {
+		t.Fatalf("Expected an error binding a prefix of a bound sequence")
+	}
+	if err := keymap.Bind("Ctrl+S Ctrl+S", "other"); err == nil {
+		t.Fatalf("Expected an error binding a sequence starting with a bound sequence")
+	}
+
+	// Rebinding and unbinding
+	keymap.Bind("Ctrl+Alt+S", "file.save")
+	keymap.Bind("Ctrl+S", "file.saveAs")
+	if binding := keymap.BindingFor("file.save"); binding != "Ctrl+Alt+S" {
+		t.Fatalf("Expected file.save to be bound to Ctrl+Alt+S, instead %q", binding)
+	}
+	keymap.Unbind("Ctrl+S")
+	if binding := keymap.BindingFor("file.saveAs"); binding != "" {
+		t.Fatalf("Expected file.saveAs to be unbound, instead %q", binding)
+	}
+}
+
+func TestKeymapDispatch(t *testing.T) {
+	registry := NewRegistry()
+	saved, enabled := 0, false
+	registry.Register(Command{Name: "file.save", Title: "Save", Run: func() { saved++ }})
+	registry.Register(Command{Name: "edit.cut", Title: "Cut", Run: func() {}, Enabled: func() bool { return enabled }})
+	keymap := DefaultKeymap()
+
+	if !keymap.Dispatch(ctrl('s'), registry) || saved != 1 {
+		t.Fatalf("Expected Ctrl+S to run file.save")
+	}
+	if keymap.Dispatch(ctrl('x'), registry) {
+		t.Fatalf("Expected a disabled command not to handle the key")
+	}
+	enabled = true
+	if !keymap.Dispatch(ctrl('x'), registry) {
+		t.Fatalf("Expected an enabled command to handle the key")
+	}
+	if keymap.Dispatch(ctrl('n'), registry) {
+		t.Fatalf("Expected a key bound to a missing command not to be handled")
+	}
+	if keymap.Dispatch(tcell.NewEventKey(tcell.KeyRune, 'a', tcell.ModNone), registry) {
+		t.Fatalf("Expected typing not to be handled")
+	}
+}
@@ -0,0 +1,66 @@
+package command
+
+import (
+	"fmt"
+	"sort"
+)
+
+// Command: A named action that can be bound to keys and shown in menus, e.g. "file.save".
+type Command struct {
+	Name string
+	Title string // Shown to the user, e.g. in menus
+	Run func()
+	Enabled func() bool // Returns false if the command can't be run right now. A nil Enabled is always enabled.
+	Checked func() bool // For commands that toggle an option, returns true if the option is on. A nil Checked is never checked.
+}
+
+func (cmd Command) IsEnabled() bool {
+	return cmd.Enabled == nil || cmd.Enabled()
+}
+
+func (cmd Command) IsChecked() bool {
+	return cmd.Checked != nil && cmd.Checked()
+}
+
+// Registry: Every command available in the app, by name.
+type Registry struct {
+	commands map[string]Command
+}
+
+func NewRegistry() *Registry {
+	return &Registry{make(map[string]Command)}
+}
+
+// Adds `cmd` to the registry, replacing any command with the same name.
+func (registry *Registry) Register(cmd Command) {
+	registry.commands[cmd.Name] = cmd
+}
+
+// Returns the command called `name`, and false if there is none.
+func (registry *Registry) Get(name string) (Command, bool) {
+	cmd, ok := registry.commands[name]
+	return cmd, ok
+}
+
+// Runs the command called `name`. An error is returned if there is no such command, or if it is disabled.
+func (registry *Registry) Run(name string) error {
+	cmd, ok := registry.commands[name]
+	if !ok {
+		return fmt.Errorf("unknown command %v", name)
+	}
+	if !cmd.IsEnabled() {
+		return fmt.Errorf("command %v is disabled", name)
+	}
+	cmd.Run()
+	return nil
+}
+
+// Returns the names of all commands, in alphabetical order.
+func (registry *Registry) Names() []string {
+	names := make([]string, 0, len(registry.commands))
+	for name := range(registry.commands) {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
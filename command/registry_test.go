@@ -0,0 +1,30 @@
+package command
+
+import (
+	"testing"
+)
+
+func TestRegistry(t *testing.T) {
+	registry := NewRegistry()
+	wrap, ran := false, 0
+	registry.Register(Command{Name: "format.wordWrap", Title: "Word Wrap", Run: func() { wrap = !wrap }, Checked: func() bool { return wrap }})
+	registry.Register(Command{Name: "edit.undo", Title: "Undo", Run: func() { ran++ }, Enabled: func() bool { return false }})
+
+	if err := registry.Run("format.wordWrap"); err != nil || !wrap {
+		t.Fatalf("Expected format.wordWrap to run, instead error: %v", err)
+	}
+	if cmd, _ := registry.Get("format.wordWrap"); !cmd.IsChecked() || !cmd.IsEnabled() {
+		t.Fatalf("Expected format.wordWrap to be enabled and checked")
+	}
+	if err := registry.Run("edit.undo"); err == nil || ran != 0 {
+		t.Fatalf("Expected a disabled command not to run")
+	}
+	if err := registry.Run("missing"); err == nil {
+		t.Fatalf("Expected an error running a missing command")
+	}
+
+	names := registry.Names()
+	if len(names) != 2 || names[0] != "edit.undo" || names[1] != "format.wordWrap" {
+		t.Fatalf("Unexpected names: %v", names)
+	}
+}
@@ -0,0 +1,303 @@
+// Loads, validates and saves the user's settings.
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"github.com/gdamore/tcell/v2"
+	"github.com/Rye123/notepad--/clipboard"
+	"github.com/Rye123/notepad--/command"
+	"github.com/Rye123/notepad--/util"
+)
+
+// Name of the directory the config file is kept in, within the user's config directory
+const CONFIG_DIR = "notepad--"
+const CONFIG_FILENAME = "config.json"
+
+// Colours of the app. Colours are names (e.g. "navy") or hex codes (e.g. "#000080"); "" or "default" leaves the terminal's colour.
+type Theme struct {
+	Foreground string `json:"foreground"`
+	Background string `json:"background"`
+	BarForeground string `json:"barForeground"`
+	BarBackground string `json:"barBackground"`
+	SelectionForeground string `json:"selectionForeground"` // If neither selection colour is given, selected text is shown in reverse
+	SelectionBackground string `json:"selectionBackground"`
+}
+
+// Programs to copy and paste with, instead of the clipboard detected at startup. Each is a program name or path followed by its arguments; the copy command reads the text from its standard input, and the paste command writes it to its standard output.
+type ClipboardCommands struct {
+	Copy []string `json:"copy,omitempty"`
+	Paste []string `json:"paste,omitempty"`
+}
+
+// Config: The settings in the config file.
+type Config struct {
+	LineEnding string `json:"lineEnding"` // LF, CRLF or CR: used for new files, and files without line ends
+	Encoding string `json:"encoding"` // Used for new files
+	WordWrap bool `json:"wordWrap"`
+	StatusBar bool `json:"statusBar"`
+	TabWidth int `json:"tabWidth"`
+	Theme Theme `json:"theme"`
+	Keys map[string]string `json:"keys"` // Key sequence to command name, on top of the default bindings. A command name of "" unbinds the sequence.
+	Clipboard ClipboardCommands `json:"clipboard"` // If not set, the clipboard is detected
+}
+
+// ValidationError: Every problem found in a config.
+type ValidationError struct {
+	Problems []string
+}
+
+func (err *ValidationError) Error() string {
+	return strings.Join(err.Problems, "\n")
+}
+
+// Returns the settings used when there is no config file.
+func Default() Config {
+	return Config{
+		LineEnding: util.LINE_END_CRLF,
+		Encoding: util.ENCODING_UTF8,
+		WordWrap: true,
+		StatusBar: true,
+		TabWidth: util.DEFAULT_TAB_WIDTH,
+		Theme: Theme{},
+		Keys: map[string]string{},
+		Clipboard: ClipboardCommands{},
+	}
+}
+
+// Returns the path of the config file: $XDG_CONFIG_HOME/notepad--/config.json, or ~/.config/notepad--/config.json if XDG_CONFIG_HOME isn't set.
+func DefaultPath() (string, error) {
+	configHome := os.Getenv("XDG_CONFIG_HOME")
+	if configHome == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", err
+		}
+		configHome = filepath.Join(home, ".config")
+	}
+	return filepath.Join(configHome, CONFIG_DIR, CONFIG_FILENAME), nil
+}
+
+// Reads the config file at `path`. Settings missing from the file keep their defaults, and a file that doesn't exist gives the default config.
+// The error describes every invalid setting, or where in the file it couldn't be parsed.
+func Load(path string) (Config, error) {
+	cfg := Default()
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return cfg, nil
+	} else if err != nil {
+		return cfg, err
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&cfg); err != nil {
+		return Default(), fmt.Errorf("%v: %v", path, describeDecodeError(data, err))
+	}
+	if cfg.Keys == nil {
+		cfg.Keys = map[string]string{}
+	}
+	if err := cfg.Validate(); err != nil {
+		return Default(), fmt.Errorf("%v: invalid settings:\n%w", path, err)
+	}
+	return cfg, nil
+}
+
+// Returns a description of a JSON decoding error, with the line it occurred on where known.
+func describeDecodeError(data []byte, err error) error {
+	offset := int64(-1)
+	var syntaxErr *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+	if errors.As(err, &syntaxErr) {
+		offset = syntaxErr.Offset
+	} else if errors.As(err, &typeErr) {
+		offset = typeErr.Offset
+		err = fmt.Errorf("%v: expected %v, not %v", typeErr.Field, typeErr.Type, typeErr.Value)
+	} else if err.Error() == "EOF" {
+		return fmt.Errorf("file is empty")
+	}
+	if offset < 0 || offset > int64(len(data)) {
+		return err
+	}
+	line := bytes.Count(data[:offset], []byte("\n")) + 1
+	return fmt.Errorf("line %d: %v", line, err)
+}
+
+// Returns a *ValidationError listing every invalid setting, or nil if all are valid.
+// Key bindings are checked for syntax only, since which commands exist isn't known until the app is set up; see ApplyKeys.
+func (cfg Config) Validate() error {
+	problems := make([]string, 0)
+	switch cfg.LineEnding {
+	case util.LINE_END_LF, util.LINE_END_CRLF, util.LINE_END_CR:
+	default:
+		problems = append(problems, fmt.Sprintf("lineEnding: %q should be one of LF, CRLF or CR", cfg.LineEnding))
+	}
+
+	validEncoding := false
+	for _, encodingName := range(util.Encodings) {
+		validEncoding = validEncoding || cfg.Encoding == encodingName
+	}
+	if !validEncoding {
+		problems = append(problems, fmt.Sprintf("encoding: %q should be one of %v", cfg.Encoding, strings.Join(util.Encodings, ", ")))
+	}
+
+	if cfg.TabWidth < 1 || cfg.TabWidth > 32 {
+		problems = append(problems, fmt.Sprintf("tabWidth: %d should be between 1 and 32", cfg.TabWidth))
+	}
+
+	colours := []struct{ name, value string }{
+		{"foreground", cfg.Theme.Foreground},
+		{"background", cfg.Theme.Background},
+		{"barForeground", cfg.Theme.BarForeground},
+		{"barBackground", cfg.Theme.BarBackground},
+		{"selectionForeground", cfg.Theme.SelectionForeground},
+		{"selectionBackground", cfg.Theme.SelectionBackground},
+	}
+	for _, colour := range(colours) {
+		if _, err := parseColour(colour.value); err != nil {
+			problems = append(problems, fmt.Sprintf("theme.%v: %v", colour.name, err))
+		}
+	}
+
+	for _, sequence := range(sortedKeys(cfg.Keys)) {
+		if _, err := command.ParseSequence(sequence); err != nil {
+			problems = append(problems, fmt.Sprintf("keys: %q: %v", sequence, err))
+		}
+	}
+
+	if (len(cfg.Clipboard.Copy) == 0) != (len(cfg.Clipboard.Paste) == 0) {
+		problems = append(problems, "clipboard: both copy and paste commands should be given, or neither")
+	}
+
+	if len(problems) > 0 {
+		return &ValidationError{problems}
+	}
+	return nil
+}
+
+// Returns the clipboard given by the config: the copy and paste commands if set, otherwise the clipboard detected for `terminal`. The config must be valid.
+// An error is returned if a command's program can't be found.
+func (cfg Config) NewClipboard(terminal io.Writer) (clipboard.Clipboard, error) {
+	commands := cfg.Clipboard
+	if len(commands.Copy) == 0 {
+		return clipboard.Detect(terminal), nil
+	}
+	for _, program := range([]string{commands.Copy[0], commands.Paste[0]}) {
+		if _, err := exec.LookPath(program); err != nil {
+			return nil, fmt.Errorf("clipboard: %v", err)
+		}
+	}
+	return clipboard.NewCommand(commands.Copy, commands.Paste)
+}
+
+// Writes the config to `path`, creating its directory if needed.
+func (cfg Config) Save(path string) error {
+	data, err := json.MarshalIndent(cfg, "", "\t")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0644)
+}
+
+// Returns the app options given by the config.
+func (cfg Config) Options() util.Options {
+	return util.Options{
+		LineEndMode: cfg.LineEnding,
+		Encoding: cfg.Encoding,
+		DefaultEncoding: cfg.Encoding,
+		WordWrap: cfg.WordWrap,
+		HideStatusBar: !cfg.StatusBar,
+		TabWidth: cfg.TabWidth,
+	}
+}
+
+// Sets the styles of the app from the theme. The config must be valid.
+func (cfg Config) ApplyTheme(appstate *util.AppState) {
+	theme := cfg.Theme
+	colour := func(value string) tcell.Color {
+		c, _ := parseColour(value)
+		return c
+	}
+
+	textStyle := tcell.StyleDefault.Foreground(colour(theme.Foreground)).Background(colour(theme.Background))
+	barStyle := tcell.StyleDefault.Foreground(colour(theme.BarForeground)).Background(colour(theme.BarBackground))
+	selectionStyle := textStyle.Reverse(true)
+	if theme.SelectionForeground != "" || theme.SelectionBackground != "" {
+		selectionStyle = tcell.StyleDefault.Foreground(colour(theme.SelectionForeground)).Background(colour(theme.SelectionBackground))
+	}
+
+	appstate.Screen.SetStyle(textStyle)
+	appstate.TextboxStyle = textStyle
+	appstate.BarStyle = barStyle
+	appstate.ButtonStyle = barStyle
+	appstate.ButtonActiveStyle = barStyle.Reverse(true)
+	appstate.SelectionStyle = selectionStyle
+	appstate.DisabledStyle = barStyle.Foreground(tcell.ColorGray)
+}
+
+// Applies the key bindings of the config on top of those in `keymap`. Sequences bound to "" are unbound first, so that they can be reused, e.g. as the start of a longer sequence.
+// An error lists every binding to a command missing from `registry`, and every binding that conflicts with another.
+func (cfg Config) ApplyKeys(keymap *command.Keymap, registry *command.Registry) error {
+	problems := make([]string, 0)
+	sequences := sortedKeys(cfg.Keys)
+	for _, sequence := range(sequences) {
+		if cfg.Keys[sequence] != "" {
+			continue
+		}
+		if err := keymap.Unbind(sequence); err != nil {
+			problems = append(problems, fmt.Sprintf("keys: %q: %v", sequence, err))
+		}
+	}
+
+	for _, sequence := range(sequences) {
+		name := cfg.Keys[sequence]
+		if name == "" {
+			continue
+		}
+		if _, ok := registry.Get(name); !ok {
+			problems = append(problems, fmt.Sprintf("keys: %q: unknown command %q", sequence, name))
+			continue
+		}
+		if err := keymap.Bind(sequence, name); err != nil {
+			problems = append(problems, fmt.Sprintf("keys: %q: %v", sequence, err))
+		}
+	}
+
+	if len(problems) > 0 {
+		return &ValidationError{problems}
+	}
+	return nil
+}
+
+// Returns the colour called `value`, e.g. "navy" or "#000080". "" and "default" are the terminal's colour.
+func parseColour(value string) (tcell.Color, error) {
+	name := strings.ToLower(strings.TrimSpace(value))
+	if name == "" || name == "default" {
+		return tcell.ColorReset, nil
+	}
+	colour := tcell.GetColor(name)
+	if colour == tcell.ColorDefault {
+		return colour, fmt.Errorf("unknown colour %q", value)
+	}
+	return colour, nil
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for key := range(m) {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
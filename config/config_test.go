@@ -0,0 +1,196 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"github.com/gdamore/tcell/v2"
+	"github.com/Rye123/notepad--/command"
+	"github.com/Rye123/notepad--/util"
+)
+
+func writeConfig(t *testing.T, contents string) string {
+	path := filepath.Join(t.TempDir(), CONFIG_FILENAME)
+	if err := os.WriteFile(path, []byte(contents), 0644); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	return path
+}
+
+func TestDefaultPath(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", "/xdg")
+	if path, _ := DefaultPath(); path != "/xdg/notepad--/config.json" {
+		t.Fatalf("Unexpected path with XDG_CONFIG_HOME: %v", path)
+	}
+	t.Setenv("XDG_CONFIG_HOME", "")
+	t.Setenv("HOME", "/home/user")
+	if path, _ := DefaultPath(); path != "/home/user/.config/notepad--/config.json" {
+		t.Fatalf("Unexpected path without XDG_CONFIG_HOME: %v", path)
+	}
+}
+
+func TestLoad(t *testing.T) {
+	// A missing file gives the defaults
+	cfg, err := Load(filepath.Join(t.TempDir(), "missing.json"))
+	if err != nil || cfg.LineEnding != util.LINE_END_CRLF || !cfg.WordWrap || cfg.TabWidth != util.DEFAULT_TAB_WIDTH {
+		t.Fatalf("Expected defaults, instead %+v, %v", cfg, err)
+	}
+
+	// Settings missing from the file keep their defaults
+	path := writeConfig(t, `{"lineEnding": "LF", "tabWidth": 4, "theme": {"background": "navy"}, "keys": {"Ctrl+G": "edit.selectAll"}}`)
+	cfg, err = Load(path)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if cfg.LineEnding != util.LINE_END_LF || cfg.TabWidth != 4 || cfg.Encoding != util.ENCODING_UTF8 || !cfg.StatusBar {
+		t.Fatalf("Unexpected config: %+v", cfg)
+	}
+	if cfg.Theme.Background != "navy" || cfg.Keys["Ctrl+G"] != "edit.selectAll" {
+		t.Fatalf("Unexpected config: %+v", cfg)
+	}
+	options := cfg.Options()
+	if options.LineEndMode != util.LINE_END_LF || options.TabWidth != 4 || options.HideStatusBar {
+		t.Fatalf("Unexpected options: %+v", options)
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	tests := []struct {
+		contents string
+		expected []string // Parts of the error message
+	}{
+		{"{\n\t\"wordWrap\": true,\n\t\"tabWidth\": 4,,\n}", []string{"line 3", "invalid character"}},
+		{"{\n\t\"tabWidth\": \"four\"\n}", []string{"line 2", "tabWidth", "int"}},
+		{`{"fontSize": 12}`, []string{"unknown field", "fontSize"}},
+		{"", []string{"empty"}},
+		{
+			`{"lineEnding": "LFCR", "encoding": "EBCDIC", "tabWidth": 0, "theme": {"foreground": "nope"}, "keys": {"Ctrl+Nope": "file.new"}}`,
+			[]string{"lineEnding: \"LFCR\"", "encoding: \"EBCDIC\"", "tabWidth: 0", "theme.foreground: unknown colour \"nope\"", "keys: \"Ctrl+Nope\""},
+		},
+		{`{"clipboard": {"copy": ["xclip", "-i"]}}`, []string{"clipboard: both copy and paste"}},
+	}
+	for _, test := range(tests) {
+		path := writeConfig(t, test.contents)
+		_, err := Load(path)
+		if err == nil {
+			t.Fatalf("Expected error for %q", test.contents)
+		}
+		for _, part := range(append(test.expected, path)) {
+			if !strings.Contains(err.Error(), part) {
+				t.Fatalf("Expected error for %q to contain %q, instead:\n%v", test.contents, part, err)
+			}
+		}
+	}
+}
+
+func TestSave(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "new", CONFIG_FILENAME)
+	cfg := Default()
+	cfg.TabWidth = 2
+	cfg.WordWrap, cfg.StatusBar = false, false
+	if err := cfg.Save(path); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	loaded, err := Load(path)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if loaded.TabWidth != 2 || loaded.WordWrap || loaded.StatusBar {
+		t.Fatalf("Unexpected config after saving: %+v", loaded)
+	}
+}
+
+func TestNewClipboard(t *testing.T) {
+	// Without commands, the clipboard is detected
+	t.Setenv("WAYLAND_DISPLAY", "")
+	t.Setenv("DISPLAY", "")
+	t.Setenv("TERM", "")
+	cfg := Default()
+	if clip, err := cfg.NewClipboard(nil); err != nil || clip == nil {
+		t.Fatalf("Expected a detected clipboard, instead %v, %v", clip, err)
+	}
+
+	// Commands are run by path, and the one saved in the config is loaded back
+	copyPath := filepath.Join(t.TempDir(), "copy")
+	os.WriteFile(copyPath, []byte("#!/bin/sh\ncat > /dev/null\n"), 0755)
+	cfg.Clipboard = ClipboardCommands{[]string{copyPath}, []string{"echo", "-n", "pasted"}}
+	path := filepath.Join(t.TempDir(), CONFIG_FILENAME)
+	if err := cfg.Save(path); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	loaded, err := Load(path)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	clip, err := loaded.NewClipboard(nil)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if err := clip.Set("text"); err != nil {
+		t.Fatalf("Unexpected error on copy: %v", err)
+	}
+	if text, err := clip.Get(); err != nil || text != "pasted" {
+		t.Fatalf("Expected \"pasted\", instead %q, %v", text, err)
+	}
+
+	// Programs that can't be found are reported
+	cfg.Clipboard.Copy = []string{filepath.Join(t.TempDir(), "missing")}
+	if _, err := cfg.NewClipboard(nil); err == nil || !strings.Contains(err.Error(), "clipboard") {
+		t.Fatalf("Expected an error for a missing program, instead %v", err)
+	}
+}
+
+func TestApplyTheme(t *testing.T) {
+	screen := tcell.NewSimulationScreen("")
+	screen.Init()
+	defer screen.Fini()
+	appstate := util.InitialiseAppState(screen, "", util.Options{})
+
+	cfg := Default()
+	cfg.Theme = Theme{Foreground: "white", Background: "#000080"}
+	cfg.ApplyTheme(appstate)
+	fg, bg, _ := appstate.TextboxStyle.Decompose()
+	if fg != tcell.ColorWhite || bg != tcell.NewHexColor(0x000080) {
+		t.Fatalf("Unexpected textbox colours: %v, %v", fg, bg)
+	}
+	if appstate.SelectionStyle != appstate.TextboxStyle.Reverse(true) {
+		t.Fatalf("Expected selection to be reversed without selection colours")
+	}
+}
+
+func TestApplyKeys(t *testing.T) {
+	registry := command.NewRegistry()
+	for _, name := range([]string{"edit.selectAll", "edit.goTo"}) {
+		registry.Register(command.Command{Name: name, Run: func() {}})
+	}
+
+	// Unbinding frees a prefix for a new binding
+	keymap := command.DefaultKeymap()
+	cfg := Default()
+	cfg.Keys = map[string]string{"Ctrl+G": "", "Ctrl+G Ctrl+G": "edit.goTo", "ctrl+k": "edit.selectAll"}
+	if err := cfg.ApplyKeys(keymap, registry); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	bindings := keymap.Bindings()
+	if bindings["Ctrl+G Ctrl+G"] != "edit.goTo" || bindings["Ctrl+K"] != "edit.selectAll" {
+		t.Fatalf("Unexpected bindings: %v", bindings)
+	}
+	if _, ok := bindings["Ctrl+G"]; ok {
+		t.Fatalf("Expected Ctrl+G to be unbound")
+	}
+
+	// Unknown commands and conflicts are all reported
+	keymap = command.DefaultKeymap()
+	cfg.Keys = map[string]string{"Ctrl+G Ctrl+G": "edit.goTo", "Ctrl+K": "edit.nope"}
+	err := cfg.ApplyKeys(keymap, registry)
+	if err == nil {
+		t.Fatalf("Expected an error")
+	}
+	for _, part := range([]string{"unknown command \"edit.nope\"", "conflicts"}) {
+		if !strings.Contains(err.Error(), part) {
+			t.Fatalf("Expected error to contain %q, instead:\n%v", part, err)
+		}
+	}
+}
@@ -1,9 +1,12 @@
 package main
 
 import (
+	"fmt"
 	"log"
 	"os"
 	"github.com/Rye123/notepad--/app"
+	"github.com/Rye123/notepad--/cli"
+	"github.com/Rye123/notepad--/config"
 	"github.com/Rye123/notepad--/tui"
 	"github.com/Rye123/notepad--/util"
 	"github.com/gdamore/tcell/v2"
@@ -22,13 +25,51 @@ func cleanup(screen tcell.Screen) {
 
 func main() {
 	// Get command line arguments
-	commandArgs := os.Args
-	
-	// Initialise variables
-	filename := ""
-	if len(commandArgs) > 1 {
-		filename = commandArgs[1]
+	args, err := cli.Parse(os.Args[1:])
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "notepad--: %v\nRun 'notepad-- --help' for usage.\n", err)
+		os.Exit(2)
+	}
+	if args.Help {
+		fmt.Print(cli.USAGE)
+		return
+	}
+	if args.Version {
+		fmt.Printf("%v %v\n", util.APP_NAME, util.APP_VERSION)
+		return
+	}
+
+	// Load the config before the screen takes over the terminal, so that any errors can be read
+	configPath := args.ConfigPath
+	if configPath == "" {
+		configPath, err = config.DefaultPath()
+		if err != nil {
+			log.Fatalf("could not find the config file: %v", err)
+		}
+	}
+	cfg, err := config.Load(configPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error in config file %v\n", err)
+		os.Exit(1)
+	}
+	clip, err := cfg.NewClipboard(os.Stdout)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error in config file %v: %v\n", configPath, err)
+		os.Exit(1)
 	}
+
+	// Command line options take precedence over the config
+	options := cfg.Options()
+	if args.Encoding != "" {
+		options.Encoding, options.DefaultEncoding = args.Encoding, args.Encoding
+	}
+	if args.LineEnding != "" {
+		options.LineEndMode = args.LineEnding
+	}
+	if args.NoWrap {
+		options.WordWrap = false
+	}
+	options.Buffer = args.Buffer
 	
 	// Initialise screen
 	screen, err := tcell.NewScreen()
@@ -41,12 +82,9 @@ func main() {
 	}
 
 	// Initialise app state
-	options := util.Options{
-		LineEndMode: "CRLF",
-		Encoding: "UTF-8",
-		WordWrap: true,
-	}
-	appstate := util.InitialiseAppState(screen, filename, options)
+	appstate := util.InitialiseAppState(screen, "", options)
+	appstate.Clipboard = clip
+	cfg.ApplyTheme(appstate)
 
 	// Setup Screen
 	screen.Clear()
@@ -54,15 +92,33 @@ func main() {
 	titlebar := tui.NewTitleBar(appstate, textbox)
 	menubar := tui.NewMenuBar(appstate)
 	statusbar := tui.NewStatusBar(appstate, textbox)
+	findbar := tui.NewFindBar(appstate)
 
 	elems := []tui.TUIElem{
 		titlebar,
 		menubar,
 		textbox,
 		statusbar,
+		findbar,
 	}
 		
 	// Event Loop
 	ui := app.NewUI(appstate, elems)
+	ui.SetConfig(&cfg, configPath)
+	if err := cfg.ApplyKeys(appstate.Keymap, appstate.Commands); err != nil {
+		screen.Fini()
+		fmt.Fprintf(os.Stderr, "Error in config file %v: invalid key bindings:\n%v\n", configPath, err)
+		os.Exit(1)
+	}
+	if err := ui.OpenArgs(args, os.Stdin); err != nil {
+		screen.Fini()
+		log.Fatalf("%+v", err)
+	}
 	ui.Display()
+
+	// Report anything that happened on exit once the screen is gone
+	if msg := ui.ExitMessage(); msg != "" {
+		screen.Fini()
+		log.Println(msg)
+	}
 }
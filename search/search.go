@@ -0,0 +1,341 @@
+// Finds and replaces text in a TextBuffer.
+package search
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"regexp/syntax"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+	"github.com/Rye123/notepad--/textbuffer"
+)
+
+// Options for how the query is matched.
+type Options struct {
+	MatchCase bool // If false, letters match regardless of case
+	WholeWord bool // If true, matches must not be directly preceded or followed by a word character
+	Regex bool // If true, the query is a regular expression in the syntax of Go's regexp package, with ^ and $ matching at line ends
+}
+
+// Match: The range [Start, End) of buffer indices of a match.
+type Match struct {
+	Start int
+	End int
+}
+
+// Replacement: A match, and the text that replaces it.
+type Replacement struct {
+	Match
+	Text string
+}
+
+// Pattern: A query compiled with its options.
+type Pattern struct {
+	query []rune // Plain query, in lowercase unless matching case
+	re *regexp.Regexp // Regular expression, or nil if the query is plain
+	options Options
+	lineSpan int // Most line ends a match can contain, or -1 if there is no limit
+}
+
+// Compiles `query` with `options`. Returns an error if the query is an invalid regular expression.
+func Compile(query string, options Options) (*Pattern, error) {
+	pattern := &Pattern{nil, nil, options, 0}
+	if !options.Regex {
+		pattern.query = []rune(query)
+		for i := range(pattern.query) {
+			pattern.query[i] = pattern.fold(pattern.query[i])
+		}
+		pattern.lineSpan = strings.Count(query, "\n")
+		return pattern, nil
+	}
+
+	flags := "(?m)"
+	if !options.MatchCase {
+		flags = "(?mi)"
+	}
+	re, err := regexp.Compile(flags + query)
+	if err != nil {
+		var syntaxErr *syntax.Error
+		if errors.As(err, &syntaxErr) {
+			return nil, fmt.Errorf("invalid regular expression: %v: `%v`", syntaxErr.Code, strings.TrimPrefix(syntaxErr.Expr, flags))
+		}
+		return nil, err
+	}
+	pattern.re = re
+	if parsed, err := syntax.Parse(flags + query, syntax.Perl); err != nil || matchesAcrossLines(parsed) {
+		pattern.lineSpan = -1
+	}
+	return pattern, nil
+}
+
+// Returns true if the regular expression can't be matched a line at a time: if any part of it can match a line end, or only the start or end of the text.
+func matchesAcrossLines(re *syntax.Regexp) bool {
+	switch re.Op {
+	case syntax.OpAnyChar, syntax.OpBeginText, syntax.OpEndText:
+		return true
+	case syntax.OpLiteral:
+		for _, ch := range(re.Rune) {
+			if ch == '\n' {
+				return true
+			}
+		}
+	case syntax.OpCharClass:
+		for i := 0; i + 1 < len(re.Rune); i += 2 {
+			if re.Rune[i] <= '\n' && '\n' <= re.Rune[i + 1] {
+				return true
+			}
+		}
+	}
+	for _, sub := range(re.Sub) {
+		if matchesAcrossLines(sub) {
+			return true
+		}
+	}
+	return false
+}
+
+// Returns every match of `query` in `buf`, in order. Returns an error if the query is an invalid regular expression.
+func FindAll(buf textbuffer.TextBuffer, query string, options Options) ([]Match, error) {
+	pattern, err := Compile(query, options)
+	if err != nil {
+		return nil, err
+	}
+	return pattern.FindAll(buf), nil
+}
+
+// Returns every match of the pattern in `buf`, in order. Matches don't overlap: each is searched for after the end of the previous one.
+// Empty matches of a regular expression are left out, since there is nothing to select.
+func (pattern *Pattern) FindAll(buf textbuffer.TextBuffer) []Match {
+	return pattern.FindAllInText(buf.String())
+}
+
+// Returns every match of the pattern in `text`, like FindAll.
+func (pattern *Pattern) FindAllInText(text string) []Match {
+	matches := make([]Match, 0)
+	for _, replacement := range(pattern.replacements(text, "", false)) {
+		if replacement.Start < replacement.End {
+			matches = append(matches, replacement.Match)
+		}
+	}
+	return matches
+}
+
+// Returns every match of the pattern in `buf` in order, along with its replacement: `template` with $1, ${name} etc. expanded to the submatches if the query is a regular expression, otherwise `template` itself.
+func (pattern *Pattern) Replacements(buf textbuffer.TextBuffer, template string) []Replacement {
+	return pattern.ReplacementsInText(buf.String(), template)
+}
+
+// Returns every match of the pattern in `text` along with its replacement, like Replacements.
+func (pattern *Pattern) ReplacementsInText(text string, template string) []Replacement {
+	return pattern.replacements(text, template, true)
+}
+
+// Returns true if a match of the pattern can span any number of lines, in which case SearchRange is the whole buffer.
+func (pattern *Pattern) Unbounded() bool {
+	return pattern.lineSpan < 0
+}
+
+// Returns the range [from, to) of `buf` to search again to find every match touching [start, end): the lines containing it, along with as many lines around them as a match can span.
+// This is the whole buffer if a match can span any number of lines, e.g. for a regular expression that can match line ends.
+func (pattern *Pattern) SearchRange(buf textbuffer.TextBuffer, start, end int) (from int, to int) {
+	if pattern.Unbounded() {
+		return 0, buf.Length()
+	}
+	startLine, _ := buf.LineCol(start)
+	endLine, _ := buf.LineCol(end)
+	startLine, endLine = startLine - pattern.lineSpan, endLine + pattern.lineSpan
+	if endLine >= buf.LineCount() - 1 {
+		return buf.LineStart(startLine), buf.Length()
+	}
+	return buf.LineStart(startLine), buf.LineStart(endLine + 1) - 1
+}
+
+// Returns every match of the pattern in the range [from, to) of `buf`, in order, as FindAll would find them if the range is from SearchRange.
+func (pattern *Pattern) FindAllInRange(buf textbuffer.TextBuffer, from, to int) []Match {
+	matches := pattern.FindAllInText(buf.Slice(from, to))
+	for i := range(matches) {
+		matches[i].Start += from
+		matches[i].End += from
+	}
+	return matches
+}
+
+// Returns every match of the pattern in the range [from, to) of `buf` along with its replacement, like Replacements.
+func (pattern *Pattern) ReplacementsInRange(buf textbuffer.TextBuffer, from, to int, template string) []Replacement {
+	replacements := pattern.ReplacementsInText(buf.Slice(from, to), template)
+	for i := range(replacements) {
+		replacements[i].Start += from
+		replacements[i].End += from
+	}
+	return replacements
+}
+
+// Returns the matches in `text`, expanding `template` for each if `expand` is true.
+func (pattern *Pattern) replacements(text string, template string, expand bool) []Replacement {
+	if pattern.re == nil {
+		matches := pattern.findPlain([]rune(text))
+		replacements := make([]Replacement, len(matches))
+		for i, match := range(matches) {
+			replacements[i] = Replacement{match, template}
+		}
+		return replacements
+	}
+
+	// Byte offsets are converted to rune indices as the matches go, since they are in order
+	replacements := make([]Replacement, 0)
+	byteOffset, runeIndex := 0, 0
+	toRuneIndex := func(offset int) int {
+		runeIndex += utf8.RuneCountInString(text[byteOffset:offset])
+		byteOffset = offset
+		return runeIndex
+	}
+	for _, submatches := range(pattern.re.FindAllStringSubmatchIndex(text, -1)) {
+		start, end := submatches[0], submatches[1]
+		if pattern.options.WholeWord && !isWholeWord(text, start, end) {
+			continue
+		}
+		replacement := Replacement{Match{toRuneIndex(start), toRuneIndex(end)}, ""}
+		if expand {
+			replacement.Text = string(pattern.re.ExpandString(nil, template, text, submatches))
+		}
+		replacements = append(replacements, replacement)
+	}
+	return replacements
+}
+
+// Returns the matches of the plain query in `text`.
+func (pattern *Pattern) findPlain(text []rune) []Match {
+	matches := make([]Match, 0)
+	query := pattern.query
+	if len(query) == 0 {
+		return matches
+	}
+
+	for start := 0; start + len(query) <= len(text); start++ {
+		if pattern.fold(text[start]) != query[0] {
+			continue
+		}
+		end := start + 1
+		for end - start < len(query) && pattern.fold(text[end]) == query[end - start] {
+			end++
+		}
+		if end - start < len(query) {
+			continue
+		}
+		if pattern.options.WholeWord && (start > 0 && isWordChar(text[start - 1]) || end < len(text) && isWordChar(text[end])) {
+			continue
+		}
+		matches = append(matches, Match{start, end})
+		start = end - 1
+	}
+	return matches
+}
+
+// Returns `ch` as it is compared: in lowercase unless matching case.
+func (pattern *Pattern) fold(ch rune) rune {
+	if pattern.options.MatchCase {
+		return ch
+	}
+	return unicode.ToLower(ch)
+}
+
+// Returns true if the bytes [start, end) of `text` are not directly preceded or followed by a word character.
+func isWholeWord(text string, start, end int) bool {
+	before, _ := utf8.DecodeLastRuneInString(text[:start])
+	after, _ := utf8.DecodeRuneInString(text[end:])
+	return !(start > 0 && isWordChar(before)) && !(end < len(text) && isWordChar(after))
+}
+
+// Returns true if `ch` is part of a word, i.e. a letter, digit or underscore.
+func isWordChar(ch rune) bool {
+	return ch == '_' || unicode.IsLetter(ch) || unicode.IsDigit(ch)
+}
+
+// Makes every replacement in `text`, returning the range [start, end) of runes of `text` that changes, i.e. from the start of the first replacement to the end of the last, and what it changes to.
+// `replacements` must be in order and not overlap. Only the changed range is returned so that the rest of the text needn't be copied.
+func Apply(text string, replacements []Replacement) (start int, end int, result string) {
+	if len(replacements) == 0 {
+		return 0, 0, ""
+	}
+	start, end = replacements[0].Start, replacements[len(replacements) - 1].End
+
+	var b strings.Builder
+	index := 0 // Rune index of the start of the remaining text
+	for i, replacement := range(replacements) {
+		between := runeBytes(text, replacement.Start - index)
+		if i > 0 {
+			b.WriteString(text[:between])
+		}
+		b.WriteString(replacement.Text)
+		text = text[between:]
+		text = text[runeBytes(text, replacement.End - replacement.Start):]
+		index = replacement.End
+	}
+	return start, end, b.String()
+}
+
+// Returns the number of bytes taken up by the first `count` runes of `s`.
+func runeBytes(s string, count int) int {
+	size := 0
+	for ; count > 0 && size < len(s); count-- {
+		_, n := utf8.DecodeRuneInString(s[size:])
+		size += n
+	}
+	return size
+}
+
+// Returns the index in `matches` of the first match starting at or after `index`. If there is none, the search wraps around to the first match, and wrapped is true.
+// Returns -1 if there are no matches.
+func Next(matches []Match, index int) (next int, wrapped bool) {
+	if len(matches) == 0 {
+		return -1, false
+	}
+	next = sort.Search(len(matches), func(i int) bool { return matches[i].Start >= index })
+	if next == len(matches) {
+		return 0, true
+	}
+	return next, false
+}
+
+// Returns the index in `matches` of the last match ending at or before `index`. If there is none, the search wraps around to the last match, and wrapped is true.
+// Returns -1 if there are no matches.
+func Previous(matches []Match, index int) (previous int, wrapped bool) {
+	if len(matches) == 0 {
+		return -1, false
+	}
+	previous = sort.Search(len(matches), func(i int) bool { return matches[i].End > index }) - 1
+	if previous < 0 {
+		return len(matches) - 1, true
+	}
+	return previous, false
+}
+
+// Returns `matches` with those in the range [from, to) of the buffer replaced by `found`, the matches found by searching that range again, e.g. the range from SearchRange around a change.
+// `matches` must be in order, and as they are after the change, e.g. from ShiftMatches.
+func SpliceMatches(matches []Match, from, to int, found []Match) []Match {
+	before := sort.Search(len(matches), func(i int) bool { return matches[i].End > from })
+	after := sort.Search(len(matches), func(i int) bool { return matches[i].Start >= to })
+	spliced := make([]Match, 0, before + len(found) + len(matches) - after)
+	spliced = append(spliced, matches[:before]...)
+	spliced = append(spliced, found...)
+	return append(spliced, matches[after:]...)
+}
+
+// Returns `matches`, found before `change` was made to the buffer, moved to where the same text is after it.
+// Matches touching or overlapping the changed text are removed, as they may no longer match; searching again finds any new matches there.
+func ShiftMatches(matches []Match, change textbuffer.Change) []Match {
+	deletedEnd, delta := change.DeletedEnd(), change.InsertedEnd() - change.DeletedEnd()
+	shifted := make([]Match, 0, len(matches))
+	for _, match := range(matches) {
+		switch {
+		case match.End < change.Index:
+			shifted = append(shifted, match)
+		case match.Start > deletedEnd:
+			shifted = append(shifted, Match{match.Start + delta, match.End + delta})
+		}
+	}
+	return shifted
+}
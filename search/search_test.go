@@ -0,0 +1,220 @@
+package search
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"github.com/Rye123/notepad--/textbuffer"
+)
+
+func TestFindAll(t *testing.T) {
+	buf := textbuffer.NewGapBuffer()
+	buf.Append("The cat scattered the Cats.\ncat_food, ça, Ça")
+
+	tests := []struct {
+		query string
+		options Options
+		expected []Match
+	}{
+		{"cat", Options{}, []Match{{4, 7}, {9, 12}, {22, 25}, {28, 31}}},
+		{"cat", Options{MatchCase: true}, []Match{{4, 7}, {9, 12}, {28, 31}}},
+		{"cat", Options{WholeWord: true}, []Match{{4, 7}}},
+		{"cats", Options{WholeWord: true}, []Match{{22, 26}}},
+		{"the", Options{MatchCase: true, WholeWord: true}, []Match{{18, 21}}},
+		{"ça", Options{}, []Match{{38, 40}, {42, 44}}},
+		{"aa", Options{}, []Match{}},
+		{"", Options{}, []Match{}},
+		{`c\w+`, Options{Regex: true}, []Match{{4, 7}, {9, 17}, {22, 26}, {28, 36}}},
+		{`ça|^the`, Options{Regex: true}, []Match{{0, 3}, {38, 40}, {42, 44}}},
+		{`ç?a\w*$`, Options{Regex: true, MatchCase: true}, []Match{{43, 44}}},
+		{`cat\w*`, Options{Regex: true, WholeWord: true}, []Match{{4, 7}, {22, 26}, {28, 36}}},
+		{`x*`, Options{Regex: true}, []Match{}},
+	}
+	for _, test := range(tests) {
+		matches, err := FindAll(buf, test.query, test.options)
+		if err != nil || !reflect.DeepEqual(matches, test.expected) {
+			t.Fatalf("Expected %v for %q %+v, instead %v", test.expected, test.query, test.options, matches)
+		}
+	}
+
+	// Matches don't overlap
+	pattern, _ := Compile("aa", Options{})
+	if matches := pattern.FindAllInText("aaaaa"); !reflect.DeepEqual(matches, []Match{{0, 2}, {2, 4}}) {
+		t.Fatalf("Unexpected overlapping matches: %v", matches)
+	}
+}
+
+func TestFindAllInvalidRegex(t *testing.T) {
+	_, err := FindAll(textbuffer.NewGapBuffer(), "(a", Options{Regex: true})
+	if err == nil || err.Error() != "invalid regular expression: missing closing ): `(a`" {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+}
+
+func TestReplacements(t *testing.T) {
+	text := "key1 = ü, key2 = two\nkey3=x"
+	tests := []struct {
+		query string
+		template string
+		options Options
+		expected string
+		count int
+	}{
+		{"key", "name", Options{}, "name1 = ü, name2 = two\nname3=x", 3},
+		{`(\w+) ?= ?(\S+?)(,|$)`, "$2: ${1}$3", Options{Regex: true}, "ü: key1, two: key2\nx: key3", 3},
+		{`^`, "> ", Options{Regex: true}, "> key1 = ü, key2 = two\n> key3=x", 2},
+		{`Ü`, "$1u", Options{}, "key1 = $1u, key2 = two\nkey3=x", 1},
+		{`Ü`, "u", Options{MatchCase: true}, text, 0},
+	}
+	for _, test := range(tests) {
+		pattern, err := Compile(test.query, test.options)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		replacements := pattern.ReplacementsInText(text, test.template)
+		if len(replacements) != test.count {
+			t.Fatalf("Expected %d replacements of %q, instead %v", test.count, test.query, replacements)
+		}
+		start, end, result := Apply(text, replacements)
+		runes := []rune(text)
+		if replaced := string(runes[:start]) + result + string(runes[end:]); replaced != test.expected {
+			t.Fatalf("Expected %q after replacing %q with %q, instead %q", test.expected, test.query, test.template, replaced)
+		}
+	}
+}
+
+func TestSearchRange(t *testing.T) {
+	buf := textbuffer.NewGapBuffer()
+	buf.Append("one ab\ntwo ab ab\nthree\nab four\n\nab")
+
+	tests := []struct {
+		query string
+		options Options
+		start, end int
+		from, to int
+	}{
+		{"ab", Options{}, 12, 14, 7, 16},
+		{"ab", Options{}, 4, 12, 0, 16},
+		{"ab", Options{}, 33, 33, 32, 34},
+		{"ab\nthree", Options{}, 12, 14, 0, 22},
+		{`a\w`, Options{Regex: true}, 12, 14, 7, 16},
+		{`^ab|b$`, Options{Regex: true}, 12, 14, 7, 16},
+		{`b\s+t`, Options{Regex: true}, 12, 14, 0, 34},
+		{`(?s)b.`, Options{Regex: true}, 12, 14, 0, 34},
+		{`ab\z`, Options{Regex: true}, 12, 14, 0, 34},
+	}
+	for _, test := range(tests) {
+		pattern, _ := Compile(test.query, test.options)
+		from, to := pattern.SearchRange(buf, test.start, test.end)
+		if from != test.from || to != test.to {
+			t.Fatalf("Expected [%d, %d) to search around [%d, %d) for %q, instead [%d, %d)", test.from, test.to, test.start, test.end, test.query, from, to)
+		}
+
+		// The range has the same matches as the whole buffer does there
+		all, _ := FindAll(buf, test.query, test.options)
+		expected := make([]Match, 0)
+		for _, match := range(all) {
+			if match.Start >= from && match.End <= to {
+				expected = append(expected, match)
+			}
+		}
+		if matches := pattern.FindAllInRange(buf, from, to); !reflect.DeepEqual(matches, expected) {
+			t.Fatalf("Expected %v in [%d, %d) for %q, instead %v", expected, from, to, test.query, matches)
+		}
+		replacements := pattern.ReplacementsInRange(buf, from, to, "x")
+		for i, replacement := range(replacements) {
+			if replacement.Match != expected[i] || replacement.Text != "x" {
+				t.Fatalf("Expected replacement of %v in [%d, %d) for %q, instead %v", expected[i], from, to, test.query, replacement)
+			}
+		}
+	}
+}
+
+func TestSpliceMatches(t *testing.T) {
+	matches := []Match{{0, 2}, {4, 6}, {8, 10}, {12, 14}}
+	tests := []struct {
+		from, to int
+		found []Match
+		expected []Match
+	}{
+		{3, 7, []Match{{3, 5}}, []Match{{0, 2}, {3, 5}, {8, 10}, {12, 14}}},
+		{2, 12, []Match{}, []Match{{0, 2}, {12, 14}}},
+		{0, 20, []Match{{1, 2}}, []Match{{1, 2}}},
+		{7, 7, []Match{}, matches},
+		{14, 16, []Match{{15, 16}}, []Match{{0, 2}, {4, 6}, {8, 10}, {12, 14}, {15, 16}}},
+	}
+	for _, test := range(tests) {
+		if spliced := SpliceMatches(matches, test.from, test.to, test.found); !reflect.DeepEqual(spliced, test.expected) {
+			t.Fatalf("Expected %v after splicing %v into [%d, %d), instead %v", test.expected, test.found, test.from, test.to, spliced)
+		}
+	}
+	if !reflect.DeepEqual(matches, []Match{{0, 2}, {4, 6}, {8, 10}, {12, 14}}) {
+		t.Fatalf("Expected the matches given not to be changed, instead %v", matches)
+	}
+}
+
+func BenchmarkReplaceAll(b *testing.B) {
+	text := strings.Repeat("The quick brown fox jumps over the lazy dog. ", 100000)
+	pattern, _ := Compile(`(\w+) dog`, Options{Regex: true})
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		b.StopTimer()
+		buf := textbuffer.NewGapBuffer()
+		buf.Append(text)
+		history := textbuffer.NewHistory(buf)
+		b.StartTimer()
+		start, end, result := Apply(buf.String(), pattern.Replacements(buf, "$1 cat"))
+		history.Replace(start, end, result, 0)
+	}
+}
+
+func TestNextPrevious(t *testing.T) {
+	matches := []Match{{2, 4}, {6, 8}, {10, 12}}
+	tests := []struct {
+		index int
+		next int
+		nextWrapped bool
+		previous int
+		previousWrapped bool
+	}{
+		{0, 0, false, 2, true},
+		{2, 0, false, 2, true},
+		{4, 1, false, 0, false},
+		{7, 2, false, 0, false},
+		{11, 0, true, 1, false},
+		{12, 0, true, 2, false},
+	}
+	for _, test := range(tests) {
+		if next, wrapped := Next(matches, test.index); next != test.next || wrapped != test.nextWrapped {
+			t.Fatalf("Expected Next from %d to be %d (%v), instead %d (%v)", test.index, test.next, test.nextWrapped, next, wrapped)
+		}
+		if previous, wrapped := Previous(matches, test.index); previous != test.previous || wrapped != test.previousWrapped {
+			t.Fatalf("Expected Previous from %d to be %d (%v), instead %d (%v)", test.index, test.previous, test.previousWrapped, previous, wrapped)
+		}
+	}
+
+	if next, _ := Next(nil, 0); next != -1 {
+		t.Fatalf("Expected -1 with no matches, instead %d", next)
+	}
+}
+
+func TestShiftMatches(t *testing.T) {
+	matches := []Match{{0, 2}, {4, 6}, {8, 10}, {12, 14}}
+	tests := []struct {
+		edit textbuffer.Edit
+		expected []Match
+	}{
+		{textbuffer.Edit{Index: 3, Deleted: "", Inserted: "abc"}, []Match{{0, 2}, {7, 9}, {11, 13}, {15, 17}}},
+		{textbuffer.Edit{Index: 7, Deleted: "é", Inserted: ""}, []Match{{0, 2}, {4, 6}, {11, 13}}},
+		{textbuffer.Edit{Index: 5, Deleted: "", Inserted: "x"}, []Match{{0, 2}, {9, 11}, {13, 15}}},
+		{textbuffer.Edit{Index: 6, Deleted: "", Inserted: "x"}, []Match{{0, 2}, {9, 11}, {13, 15}}},
+		{textbuffer.Edit{Index: 5, Deleted: "a\nbcd", Inserted: "é"}, []Match{{0, 2}, {8, 10}}},
+		{textbuffer.Edit{Index: 0, Deleted: "", Inserted: ""}, []Match{{4, 6}, {8, 10}, {12, 14}}},
+	}
+	for _, test := range(tests) {
+		shifted := ShiftMatches(matches, textbuffer.Change{Edit: test.edit, Version: 1})
+		if !reflect.DeepEqual(shifted, test.expected) {
+			t.Fatalf("Expected %v after %+v, instead %v", test.expected, test.edit, shifted)
+		}
+	}
+}
@@ -0,0 +1,181 @@
+package textbuffer
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"strings"
+	"testing"
+)
+
+// stackBuffer: The previous GapBuffer, with the runes after the index stored in reverse and moved a rune at a time, kept as it was to compare against.
+// Delete also returns an error, as the benchmarks expect.
+// [0 1 2 3 ...][ GAP ][... 3 2 1 0]
+type stackBuffer struct {
+	left []rune
+	right []rune
+	cursorIndex int
+}
+
+func (buf *stackBuffer) Length() int {
+	return len(buf.left) + len(buf.right)
+}
+
+// Moves index to `newIndex`. If `newIndex` is beyond the bounds of the buffer, it stays at the closest bound.
+func (buf *stackBuffer) MoveIndex(newIndex int) {
+	if newIndex < 0 {
+		newIndex = 0
+	} else if newIndex > buf.Length() {
+		newIndex = buf.Length()
+	}
+	buf.cursorIndex = newIndex
+
+	for buf.cursorIndex > len(buf.left) {
+		// Move characters from buf.right to buf.left
+		ch := buf.right[len(buf.right) - 1]
+		buf.right = buf.right[:len(buf.right) - 1]
+		buf.left = append(buf.left, ch)
+	}
+
+	for buf.cursorIndex < len(buf.left) {
+		// Move characters from buf.left to buf.right
+		ch := buf.left[len(buf.left) - 1]
+		buf.left = buf.left[:len(buf.left) - 1]
+		buf.right = append(buf.right, ch)
+	}
+}
+
+func (buf *stackBuffer) String() string {
+	fullBuffer := make([]rune, buf.Length())
+	copy(fullBuffer, buf.left)
+	for i, ch := range(buf.right) {
+		fullBufferIndex := len(fullBuffer) -1 - i
+		fullBuffer[fullBufferIndex] = ch
+	}
+	return string(fullBuffer)
+}
+
+func (buf *stackBuffer) Insert(index int, ch rune) error {
+	if index < 0 || index > buf.Length() {
+		return errors.New("Index error")
+	}
+	
+	if index != buf.cursorIndex {
+		buf.MoveIndex(index)
+	}
+
+	// invariant after MoveIndex: insertion always appends to left stack
+	buf.left = append(buf.left, ch)
+	buf.cursorIndex++
+
+	return nil
+}
+
+func (buf *stackBuffer) Append(s string) error {
+	for _, c := range(s) {
+		err := buf.Insert(buf.Length(), c)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (buf *stackBuffer) Delete(index int) (rune, error) {
+	if index != buf.cursorIndex {
+		buf.MoveIndex(index)
+	}
+
+	// invariant after MoveIndex: deletion always deletes from right stack
+	if len(buf.right) == 0 {
+		return rune(0), errors.New("Index error")
+	}
+
+	ch := buf.right[len(buf.right)-1]
+	buf.right = buf.right[:len(buf.right)-1]
+	return ch, nil
+}
+
+// The operations benchmarked, common to both buffers.
+type benchmarkBuffer interface {
+	String() string
+	Insert(index int, ch rune) error
+	Append(s string) error
+	Delete(index int) (rune, error)
+	Length() int
+	MoveIndex(newIndex int)
+}
+
+// The buffers compared.
+var benchmarkBuffers = []struct {
+	name string
+	new func() benchmarkBuffer
+}{
+	{"stackBuffer", func() benchmarkBuffer { return &stackBuffer{} }},
+	{"GapBuffer", func() benchmarkBuffer { return NewGapBuffer() }},
+	{"PieceTable", func() benchmarkBuffer { return NewPieceTable() }},
+	{"Rope", func() benchmarkBuffer { return NewRope() }},
+}
+
+// Runs `benchmark` on each buffer, filled with `size` runes of text.
+func forEachBenchmarkBuffer(b *testing.B, size int, benchmark func(b *testing.B, buf benchmarkBuffer)) {
+	line := strings.Repeat("the quick brown fox ", 4) + "\n"
+	text := strings.Repeat(line, size / len(line))
+	for _, buffer := range(benchmarkBuffers) {
+		b.Run(fmt.Sprintf("%v/size=%d", buffer.name, size), func(b *testing.B) {
+			buf := buffer.new()
+			buf.Append(text)
+			b.ResetTimer()
+			benchmark(b, buf)
+		})
+	}
+}
+
+// Loading a file.
+func BenchmarkBufferAppend(b *testing.B) {
+	text := strings.Repeat("the quick brown fox\n", 50000)
+	for _, buffer := range(benchmarkBuffers) {
+		b.Run(buffer.name, func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				buffer.new().Append(text)
+			}
+		})
+	}
+}
+
+// Typing and backspacing in one place, after moving there from the end.
+func BenchmarkBufferTyping(b *testing.B) {
+	forEachBenchmarkBuffer(b, 1000000, func(b *testing.B, buf benchmarkBuffer) {
+		index := buf.Length() / 2
+		for i := 0; i < b.N; i++ {
+			if i % 2 == 0 {
+				buf.Insert(index, 'x')
+			} else {
+				buf.Delete(index)
+			}
+		}
+	})
+}
+
+// Editing at random places, e.g. jumping between search results, in a large and a huge file.
+func BenchmarkBufferRandomEdits(b *testing.B) {
+	for _, size := range([]int{1000000, 20000000}) {
+		forEachBenchmarkBuffer(b, size, func(b *testing.B, buf benchmarkBuffer) {
+			rng := rand.New(rand.NewSource(1))
+			for i := 0; i < b.N; i++ {
+				buf.Insert(rng.Intn(buf.Length() + 1), 'x')
+				buf.Delete(rng.Intn(buf.Length()))
+			}
+		})
+	}
+}
+
+// Getting the whole text, e.g. to save or search it.
+func BenchmarkBufferString(b *testing.B) {
+	forEachBenchmarkBuffer(b, 1000000, func(b *testing.B, buf benchmarkBuffer) {
+		buf.MoveIndex(buf.Length() / 2)
+		for i := 0; i < b.N; i++ {
+			_ = buf.String()
+		}
+	})
+}
@@ -0,0 +1,433 @@
+// Checks that an implementation of textbuffer.TextBuffer meets the contract of the interface, by table tests, random edits compared against a naive model, and a fuzz target.
+// An implementation runs it from its tests with Run and Fuzz, giving a function that makes an empty buffer.
+package conformance
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"strings"
+	"testing"
+	"github.com/Rye123/notepad--/textbuffer"
+)
+
+// Model: A naive TextBuffer of a slice of runes, which the contract is checked against.
+type Model struct {
+	runes []rune
+	index int
+}
+
+func NewModel() *Model {
+	return &Model{make([]rune, 0), 0}
+}
+
+func (model *Model) String() string { return string(model.runes) }
+func (model *Model) StringBeforeIndex() string { return string(model.runes[:model.index]) }
+func (model *Model) StringAfterInclIndex() string { return string(model.runes[model.index:]) }
+func (model *Model) Length() int { return len(model.runes) }
+func (model *Model) GetIndex() int { return model.index }
+func (model *Model) Clear() { model.runes, model.index = make([]rune, 0), 0 }
+
+func (model *Model) MoveIndex(newIndex int) {
+	model.index = clamp(newIndex, 0, len(model.runes))
+}
+
+func (model *Model) Insert(index int, ch rune) error {
+	if index < 0 || index > len(model.runes) {
+		return errors.New("Index error")
+	}
+	model.runes = append(model.runes[:index], append([]rune{ch}, model.runes[index:]...)...)
+	model.index = index + 1
+	return nil
+}
+
+func (model *Model) Append(s string) error {
+	model.runes = append(model.runes, []rune(s)...)
+	model.index = len(model.runes)
+	return nil
+}
+
+func (model *Model) Delete(index int) (rune, error) {
+	if index < 0 || index >= len(model.runes) {
+		return rune(0), errors.New("Index error")
+	}
+	ch := model.runes[index]
+	model.runes = append(model.runes[:index], model.runes[index + 1:]...)
+	model.index = index
+	return ch, nil
+}
+
+func (model *Model) InsertString(index int, s string) error {
+	if index < 0 || index > len(model.runes) {
+		return errors.New("Index error")
+	}
+	runes := []rune(s)
+	model.runes = append(model.runes[:index], append(runes, model.runes[index:]...)...)
+	model.index = index + len(runes)
+	return nil
+}
+
+func (model *Model) DeleteRange(start, end int) string {
+	start, end = clamp(start, 0, len(model.runes)), clamp(end, 0, len(model.runes))
+	model.index = start
+	if end <= start {
+		return ""
+	}
+	deleted := string(model.runes[start:end])
+	model.runes = append(model.runes[:start], model.runes[end:]...)
+	return deleted
+}
+
+func (model *Model) Slice(start, end int) string {
+	start, end = clamp(start, 0, len(model.runes)), clamp(end, 0, len(model.runes))
+	if end <= start {
+		return ""
+	}
+	return string(model.runes[start:end])
+}
+
+func (model *Model) Replace(start, end int, s string) (string, error) {
+	if start < 0 || start > end || end > len(model.runes) {
+		return "", errors.New("Index error")
+	}
+	deleted := model.DeleteRange(start, end)
+	return deleted, model.InsertString(start, s)
+}
+
+func (model *Model) LineCount() int {
+	return strings.Count(model.String(), "\n") + 1
+}
+
+func (model *Model) LineStart(line int) int {
+	line = clamp(line, 0, model.LineCount() - 1)
+	for index, ch := range(model.runes) {
+		if line == 0 {
+			return index
+		}
+		if ch == '\n' {
+			line--
+		}
+	}
+	return len(model.runes)
+}
+
+func (model *Model) LineCol(index int) (int, int) {
+	index = clamp(index, 0, len(model.runes))
+	line := strings.Count(string(model.runes[:index]), "\n")
+	return line, index - model.LineStart(line)
+}
+
+func (model *Model) Line(line int) string {
+	return strings.Split(model.String(), "\n")[clamp(line, 0, model.LineCount() - 1)]
+}
+
+func clamp(value, low, high int) int {
+	if value < low {
+		return low
+	}
+	if value > high {
+		return high
+	}
+	return value
+}
+
+// Runs every conformance test on buffers made by `newBuffer`, which must return a new, empty buffer each time.
+func Run(t *testing.T, newBuffer func() textbuffer.TextBuffer) {
+	t.Run("Edits", func(t *testing.T) { testEdits(t, newBuffer) })
+	t.Run("Lines", func(t *testing.T) { testLines(t, newBuffer) })
+	t.Run("Random", func(t *testing.T) {
+		// Long sequences of edits from fixed seeds, so that failures can be reproduced
+		for seed := int64(0); seed < 20; seed++ {
+			data := make([]byte, 2000)
+			rand.New(rand.NewSource(seed)).Read(data)
+			if !runOps(t, newBuffer, data) {
+				t.Fatalf("Failed with seed %d", seed)
+			}
+		}
+	})
+}
+
+// Runs the fuzz target on buffers made by `newBuffer`: the fuzzed bytes are decoded into a sequence of edits, which are made to a buffer and a Model and compared after each.
+func Fuzz(f *testing.F, newBuffer func() textbuffer.TextBuffer) {
+	f.Add([]byte{})
+	f.Add([]byte("\x00\x00a\x00\x01b\x02\x00\x00"))
+	f.Add([]byte("\x04\x00\x05\x05\x02\x07\x06\x01\x03\x03\x02\x01\x08\x00\x00"))
+	f.Add([]byte(strings.Repeat("\x05\x03\x07\x06\x01\x02\x02\x04", 8)))
+	f.Fuzz(func(t *testing.T, data []byte) {
+		runOps(t, newBuffer, data)
+	})
+}
+
+/* TABLE TESTS */
+
+// edit: An edit made to a buffer loaded with `initial`, and what it should leave.
+type edit struct {
+	name string
+	initial string
+	apply func(buf textbuffer.TextBuffer) (string, error) // Makes the edit, returning what was deleted, if anything
+	expected string // Contents of the buffer after the edit
+	index int // Index after the edit
+	deleted string
+	fails bool // If true, the edit should return an error and leave the buffer and index as they were after loading
+}
+
+func testEdits(t *testing.T, newBuffer func() textbuffer.TextBuffer) {
+	insert := func(index int, ch rune) func(buf textbuffer.TextBuffer) (string, error) {
+		return func(buf textbuffer.TextBuffer) (string, error) { return "", buf.Insert(index, ch) }
+	}
+	deleteAt := func(index int) func(buf textbuffer.TextBuffer) (string, error) {
+		return func(buf textbuffer.TextBuffer) (string, error) {
+			ch, err := buf.Delete(index)
+			if err != nil {
+				return "", err
+			}
+			return string(ch), nil
+		}
+	}
+	insertString := func(index int, s string) func(buf textbuffer.TextBuffer) (string, error) {
+		return func(buf textbuffer.TextBuffer) (string, error) { return "", buf.InsertString(index, s) }
+	}
+	deleteRange := func(start, end int) func(buf textbuffer.TextBuffer) (string, error) {
+		return func(buf textbuffer.TextBuffer) (string, error) { return buf.DeleteRange(start, end), nil }
+	}
+	replace := func(start, end int, s string) func(buf textbuffer.TextBuffer) (string, error) {
+		return func(buf textbuffer.TextBuffer) (string, error) { return buf.Replace(start, end, s) }
+	}
+	slice := func(start, end int) func(buf textbuffer.TextBuffer) (string, error) {
+		return func(buf textbuffer.TextBuffer) (string, error) { return buf.Slice(start, end), nil }
+	}
+	moveIndex := func(index int) func(buf textbuffer.TextBuffer) (string, error) {
+		return func(buf textbuffer.TextBuffer) (string, error) { buf.MoveIndex(index); return "", nil }
+	}
+
+	edits := []edit{
+		// Loading leaves the index at the end
+		{"append", "", func(buf textbuffer.TextBuffer) (string, error) { return "", buf.Append("déjà vu") }, "déjà vu", 7, "", false},
+		{"append empty", "ab", func(buf textbuffer.TextBuffer) (string, error) { return "", buf.Append("") }, "ab", 2, "", false},
+		{"clear", "abc", func(buf textbuffer.TextBuffer) (string, error) { buf.Clear(); return "", nil }, "", 0, "", false},
+
+		// Insert takes an index in [0, Length()], and leaves the index after the inserted rune
+		{"insert into empty", "", insert(0, 'a'), "a", 1, "", false},
+		{"insert at start", "bc", insert(0, 'a'), "abc", 1, "", false},
+		{"insert in middle", "ac", insert(1, 'b'), "abc", 2, "", false},
+		{"insert at end", "ab", insert(2, 'c'), "abc", 3, "", false},
+		{"insert line end", "ab", insert(1, '\n'), "a\nb", 2, "", false},
+		{"insert outside BMP", "ab", insert(1, '😀'), "a😀b", 2, "", false},
+		{"insert before start", "ab", insert(-1, 'x'), "ab", 2, "", true},
+		{"insert past end", "ab", insert(3, 'x'), "ab", 2, "", true},
+
+		// Delete takes an index in [0, Length()), and leaves the index where the rune was
+		{"delete first", "abc", deleteAt(0), "bc", 0, "a", false},
+		{"delete middle", "abc", deleteAt(1), "ac", 1, "b", false},
+		{"delete last", "abc", deleteAt(2), "ab", 2, "c", false},
+		{"delete multibyte", "aéb", deleteAt(1), "ab", 1, "é", false},
+		{"delete at end", "abc", deleteAt(3), "abc", 3, "", true},
+		{"delete before start", "abc", deleteAt(-1), "abc", 3, "", true},
+		{"delete from empty", "", deleteAt(0), "", 0, "", true},
+
+		// Ranges of runes
+		{"insert string", "ad", insertString(1, "bc"), "abcd", 3, "", false},
+		{"insert empty string", "ab", insertString(1, ""), "ab", 1, "", false},
+		{"insert string past end", "ab", insertString(3, "x"), "ab", 2, "", true},
+		{"delete range", "abcd", deleteRange(1, 3), "ad", 1, "bc", false},
+		{"delete empty range", "abcd", deleteRange(2, 2), "abcd", 2, "", false},
+		{"delete range clipped to buffer", "abcd", deleteRange(-2, 9), "", 0, "abcd", false},
+		{"delete backwards range", "abcd", deleteRange(3, 1), "abcd", 3, "", false},
+		{"replace", "abcd", replace(1, 3, "xyz"), "axyzd", 4, "bc", false},
+		{"replace with empty string", "abcd", replace(1, 3, ""), "ad", 1, "bc", false},
+		{"replace empty range", "abcd", replace(2, 2, "x"), "abxcd", 3, "", false},
+		{"replace whole buffer", "abcd", replace(0, 4, "é\n"), "é\n", 2, "abcd", false},
+		{"replace backwards range", "abcd", replace(3, 1, "x"), "abcd", 4, "", true},
+		{"replace past end", "abcd", replace(2, 5, "x"), "abcd", 4, "", true},
+		{"replace before start", "abcd", replace(-1, 2, "x"), "abcd", 4, "", true},
+
+		// Slice doesn't move the index, and is clipped to the buffer. Its result is checked in place of the deleted text
+		{"slice", "aé😀b", slice(1, 3), "aé😀b", 4, "é😀", false},
+		{"slice clipped to buffer", "abcd", slice(-3, 10), "abcd", 4, "abcd", false},
+		{"slice backwards", "abcd", slice(3, 1), "abcd", 4, "", false},
+
+		// The index is clamped to [0, Length()]
+		{"move index", "abc", moveIndex(1), "abc", 1, "", false},
+		{"move index before start", "abc", moveIndex(-5), "abc", 0, "", false},
+		{"move index past end", "abc", moveIndex(5), "abc", 3, "", false},
+	}
+
+	for _, e := range(edits) {
+		buf := newBuffer()
+		buf.Append(e.initial)
+		deleted, err := e.apply(buf)
+		if e.fails {
+			if err == nil {
+				t.Errorf("%v: expected an error", e.name)
+			}
+		} else if err != nil {
+			t.Errorf("%v: unexpected error: %v", e.name, err)
+		}
+		if buf.String() != e.expected || buf.GetIndex() != e.index || deleted != e.deleted {
+			t.Errorf("%v: expected %q with index %d and %q deleted, instead %q with index %d and %q deleted", e.name, e.expected, e.index, e.deleted, buf.String(), buf.GetIndex(), deleted)
+		}
+		model := NewModel()
+		model.Append(e.expected)
+		model.MoveIndex(e.index)
+		check(t, buf, model, e.name)
+	}
+}
+
+func testLines(t *testing.T, newBuffer func() textbuffer.TextBuffer) {
+	buf := newBuffer()
+	check(t, buf, NewModel(), "new buffer")
+	for _, text := range([]string{"", "\n", "one", "one\n", "\none", "one\ntwo\n\nfour", "\n\n\n", "é\n😀\r\n"}) {
+		buf.Clear()
+		buf.Append(text)
+		model := NewModel()
+		model.Append(text)
+		check(t, buf, model, fmt.Sprintf("loading %q", text))
+	}
+}
+
+/* RANDOM EDITS */
+
+// Characters that random edits insert: line ends, and runes of one to four bytes in UTF-8.
+var alphabet = []rune{'a', 'b', ' ', '\n', '\n', '\r', '\t', 'é', '中', '😀'}
+
+// Decodes `data` into a sequence of edits, makes each to a buffer and a Model, and checks that they agree after each. Returns false if they didn't.
+// Indices are decoded to be from -1 to Length() + 1, so that edits just outside the buffer are made too.
+func runOps(t *testing.T, newBuffer func() textbuffer.TextBuffer, data []byte) bool {
+	t.Helper()
+	buf, model := newBuffer(), NewModel()
+	next := func() int {
+		if len(data) == 0 {
+			return 0
+		}
+		b := data[0]
+		data = data[1:]
+		return int(b)
+	}
+	randomString := func() string {
+		runes := make([]rune, next() % 12)
+		for i := range(runes) {
+			runes[i] = alphabet[next() % len(alphabet)]
+		}
+		return string(runes)
+	}
+
+	for len(data) > 0 {
+		op, index := next() % 11, next() % (model.Length() + 3) - 1
+		var description string
+		switch op {
+		case 0, 1:
+			ch := alphabet[next() % len(alphabet)]
+			description = fmt.Sprintf("Insert(%d, %q)", index, ch)
+			err, expectedErr := buf.Insert(index, ch), model.Insert(index, ch)
+			if (err == nil) != (expectedErr == nil) {
+				t.Errorf("%v: expected error %v, instead %v", description, expectedErr, err)
+				return false
+			}
+		case 2, 3:
+			description = fmt.Sprintf("Delete(%d)", index)
+			ch, err := buf.Delete(index)
+			expectedCh, expectedErr := model.Delete(index)
+			if (err == nil) != (expectedErr == nil) || err == nil && ch != expectedCh {
+				t.Errorf("%v: expected %q and error %v, instead %q and %v", description, expectedCh, expectedErr, ch, err)
+				return false
+			}
+		case 4:
+			description = fmt.Sprintf("MoveIndex(%d)", index)
+			buf.MoveIndex(index)
+			model.MoveIndex(index)
+		case 5:
+			s := randomString()
+			description = fmt.Sprintf("InsertString(%d, %q)", index, s)
+			err, expectedErr := buf.InsertString(index, s), model.InsertString(index, s)
+			if (err == nil) != (expectedErr == nil) {
+				t.Errorf("%v: expected error %v, instead %v", description, expectedErr, err)
+				return false
+			}
+		case 6:
+			end := index + next() % 16 - 2
+			description = fmt.Sprintf("DeleteRange(%d, %d)", index, end)
+			deleted, expected := buf.DeleteRange(index, end), model.DeleteRange(index, end)
+			if deleted != expected {
+				t.Errorf("%v: expected %q deleted, instead %q", description, expected, deleted)
+				return false
+			}
+		case 7:
+			s := randomString()
+			description = fmt.Sprintf("Append(%q)", s)
+			buf.Append(s)
+			model.Append(s)
+		case 8:
+			description = "Clear()"
+			buf.Clear()
+			model.Clear()
+		case 9:
+			end, s := index + next() % 16 - 2, randomString()
+			description = fmt.Sprintf("Replace(%d, %d, %q)", index, end, s)
+			deleted, err := buf.Replace(index, end, s)
+			expected, expectedErr := model.Replace(index, end, s)
+			if (err == nil) != (expectedErr == nil) || err == nil && deleted != expected {
+				t.Errorf("%v: expected %q replaced and error %v, instead %q and %v", description, expected, expectedErr, deleted, err)
+				return false
+			}
+		case 10:
+			end := index + next() % 16 - 2
+			description = fmt.Sprintf("Slice(%d, %d)", index, end)
+			if s, expected := buf.Slice(index, end), model.Slice(index, end); s != expected {
+				t.Errorf("%v: expected %q, instead %q", description, expected, s)
+				return false
+			}
+		}
+		if !check(t, buf, model, description) {
+			return false
+		}
+	}
+	return true
+}
+
+// Checks that every query of `buf` agrees with `model`, after the edit described by `after`. Returns false if they didn't.
+func check(t *testing.T, buf textbuffer.TextBuffer, model *Model, after string) bool {
+	t.Helper()
+	fail := func(format string, args ...any) bool {
+		t.Errorf("After %v, with model %q and index %d: %v", after, model.String(), model.GetIndex(), fmt.Sprintf(format, args...))
+		return false
+	}
+
+	if buf.String() != model.String() || buf.Length() != model.Length() {
+		return fail("expected the buffer to have %d runes, instead %q with %d", model.Length(), buf.String(), buf.Length())
+	}
+	if buf.GetIndex() != model.GetIndex() || buf.StringBeforeIndex() != model.StringBeforeIndex() || buf.StringAfterInclIndex() != model.StringAfterInclIndex() {
+		return fail("expected the index at %d, instead %q|%q at %d", model.GetIndex(), buf.StringBeforeIndex(), buf.StringAfterInclIndex(), buf.GetIndex())
+	}
+
+	// Lines, including out of range lines, which are clamped
+	lines := strings.Split(model.String(), "\n")
+	if buf.LineCount() != len(lines) {
+		return fail("expected %d lines, instead %d", len(lines), buf.LineCount())
+	}
+	for line := -1; line <= len(lines); line++ {
+		expectedLine := clamp(line, 0, len(lines) - 1)
+		if buf.LineStart(line) != model.LineStart(expectedLine) || buf.Line(line) != lines[expectedLine] {
+			return fail("expected line %d to be %q at %d, instead %q at %d", line, lines[expectedLine], model.LineStart(expectedLine), buf.Line(line), buf.LineStart(line))
+		}
+	}
+
+	// Line and column of every index, counting through the lines once, and of indices outside the buffer
+	index, line, col := 0, 0, 0
+	for _, ch := range(model.runes) {
+		if l, c := buf.LineCol(index); l != line || c != col {
+			return fail("expected index %d at %d:%d, instead %d:%d", index, line, col, l, c)
+		}
+		index++
+		col++
+		if ch == '\n' {
+			line, col = line + 1, 0
+		}
+	}
+	for _, i := range([]int{index, index + 1, -1}) {
+		expectedLine, expectedCol := model.LineCol(i)
+		if l, c := buf.LineCol(i); l != expectedLine || c != expectedCol {
+			return fail("expected index %d at %d:%d, instead %d:%d", i, expectedLine, expectedCol, l, c)
+		}
+	}
+	return true
+}
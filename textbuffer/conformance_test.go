@@ -0,0 +1,46 @@
+package textbuffer_test
+
+import (
+	"testing"
+	"github.com/Rye123/notepad--/textbuffer"
+	"github.com/Rye123/notepad--/textbuffer/conformance"
+)
+
+func newGapBuffer() textbuffer.TextBuffer { return textbuffer.NewGapBuffer() }
+func newPieceTable() textbuffer.TextBuffer { return textbuffer.NewPieceTable() }
+func newRope() textbuffer.TextBuffer { return textbuffer.NewRope() }
+func newObserved() textbuffer.TextBuffer { return textbuffer.NewObserved(textbuffer.NewGapBuffer()) }
+
+// Emptied snapshots, which still share the sources or nodes of the buffer they were taken from, edited afterwards
+func newPieceTableSnapshot() textbuffer.TextBuffer {
+	table := textbuffer.NewPieceTable()
+	table.Append("the original\ntext")
+	snapshot := table.Snapshot()
+	snapshot.DeleteRange(0, snapshot.Length())
+	table.InsertString(4, "edited\n")
+	return snapshot
+}
+
+func newRopeSnapshot() textbuffer.TextBuffer {
+	rope := textbuffer.NewRope()
+	rope.Append("the original\ntext")
+	snapshot := rope.Snapshot()
+	snapshot.DeleteRange(0, snapshot.Length())
+	rope.InsertString(4, "edited\n")
+	return snapshot
+}
+
+func TestGapBufferConformance(t *testing.T) { conformance.Run(t, newGapBuffer) }
+func TestPieceTableConformance(t *testing.T) { conformance.Run(t, newPieceTable) }
+func TestRopeConformance(t *testing.T) { conformance.Run(t, newRope) }
+func TestObservedConformance(t *testing.T) { conformance.Run(t, newObserved) }
+func TestPieceTableSnapshotConformance(t *testing.T) { conformance.Run(t, newPieceTableSnapshot) }
+func TestRopeSnapshotConformance(t *testing.T) { conformance.Run(t, newRopeSnapshot) }
+func TestModelConformance(t *testing.T) {
+	conformance.Run(t, func() textbuffer.TextBuffer { return conformance.NewModel() })
+}
+
+func FuzzGapBuffer(f *testing.F) { conformance.Fuzz(f, newGapBuffer) }
+func FuzzPieceTable(f *testing.F) { conformance.Fuzz(f, newPieceTable) }
+func FuzzRope(f *testing.F) { conformance.Fuzz(f, newRope) }
+func FuzzObserved(f *testing.F) { conformance.Fuzz(f, newObserved) }
@@ -0,0 +1,263 @@
+package textbuffer
+
+import (
+	"errors"
+	"unicode"
+	"unicode/utf8"
+)
+
+// An edit to a TextBuffer: `Deleted` was removed at `Index`, then `Inserted` was inserted at `Index`.
+type Edit struct {
+	Index int
+	Deleted string
+	Inserted string
+}
+
+// Applies the edit to `buf`.
+func (edit Edit) apply(buf TextBuffer) error {
+	_, err := buf.Replace(edit.Index, edit.Index + utf8.RuneCountInString(edit.Deleted), edit.Inserted)
+	return err
+}
+
+// Returns the edit that reverts this edit.
+func (edit Edit) inverse() Edit {
+	return Edit{edit.Index, edit.Inserted, edit.Deleted}
+}
+
+// A group of edits that is undone and redone as one step.
+type editGroup struct {
+	edits []Edit
+	cursorBefore int // Cursor index before the group was applied
+	cursorAfter int // Cursor index after the group was applied
+	closed bool // True if no more edits may be coalesced into this group
+}
+
+// History: Undo/redo history of the edits made to a TextBuffer.
+// All edits to the buffer should be made through the history, so that they can be undone. Each edit is recorded before it is applied, so that observers of the buffer see the history as of the edit. Consecutive typing or deletion is coalesced into word-sized groups.
+type History struct {
+	buf TextBuffer
+	undoStack []*editGroup
+	redoStack []*editGroup
+	savePoint int // Size of undoStack when the buffer was last saved, or -1 if that state can no longer be reached
+	transaction *editGroup // Group being built between Begin and End, if any
+}
+
+func NewHistory(buf TextBuffer) *History {
+	return &History{buf, make([]*editGroup, 0), make([]*editGroup, 0), 0, nil}
+}
+
+// Inserts `s` at `index`. `cursorBefore` is the cursor index to restore when this is undone.
+func (history *History) Insert(index int, s string, cursorBefore int) error {
+	if len(s) == 0 {
+		return nil
+	}
+	if index < 0 || index > history.buf.Length() {
+		return errors.New("Index error")
+	}
+	edit := Edit{index, "", s}
+	history.record(edit, cursorBefore, index + utf8.RuneCountInString(s))
+	return edit.apply(history.buf)
+}
+
+// Deletes and returns the text in [start, end). `cursorBefore` is the cursor index to restore when this is undone.
+func (history *History) Delete(start, end int, cursorBefore int) string {
+	if start < 0 {
+		start = 0
+	}
+	if end > history.buf.Length() {
+		end = history.buf.Length()
+	}
+	if start >= end {
+		return ""
+	}
+	deleted := history.buf.Slice(start, end)
+	history.record(Edit{start, deleted, ""}, cursorBefore, start)
+	history.buf.DeleteRange(start, end)
+	return deleted
+}
+
+// Replaces the text in [start, end) with `s` as a single edit, and returns the replaced text. `cursorBefore` is the cursor index to restore when this is undone.
+func (history *History) Replace(start, end int, s string, cursorBefore int) (string, error) {
+	if start < 0 {
+		start = 0
+	}
+	if end > history.buf.Length() {
+		end = history.buf.Length()
+	}
+	if start > end {
+		end = start
+	}
+	if start > history.buf.Length() {
+		return "", errors.New("Index error")
+	}
+	deleted := history.buf.Slice(start, end)
+	if deleted == s {
+		return deleted, nil
+	}
+	edit := Edit{start, deleted, s}
+	history.record(edit, cursorBefore, start + utf8.RuneCountInString(s))
+	return deleted, edit.apply(history.buf)
+}
+
+// Starts a transaction: every edit until End is undone as a single step.
+func (history *History) Begin() {
+	history.Break()
+	if history.transaction == nil {
+		history.transaction = &editGroup{make([]Edit, 0), -1, -1, false}
+	}
+}
+
+// Ends the current transaction.
+func (history *History) End() {
+	group := history.transaction
+	history.transaction = nil
+	if group == nil || len(group.edits) == 0 {
+		return
+	}
+	group.closed = true
+	history.push(group)
+}
+
+// Stops the next edit from being coalesced with the previous one, e.g. after the cursor has been moved.
+func (history *History) Break() {
+	if len(history.undoStack) > 0 {
+		history.undoStack[len(history.undoStack) - 1].closed = true
+	}
+}
+
+// Reverts the last group of edits. Returns the cursor index before those edits, and false if there was nothing to undo.
+func (history *History) Undo() (int, bool) {
+	history.End()
+	if len(history.undoStack) == 0 {
+		return 0, false
+	}
+	group := history.undoStack[len(history.undoStack) - 1]
+	history.undoStack = history.undoStack[:len(history.undoStack) - 1]
+
+	for i := len(group.edits) - 1; i >= 0; i-- {
+		group.edits[i].inverse().apply(history.buf)
+	}
+	group.closed = true
+	history.redoStack = append(history.redoStack, group)
+	history.Break()
+	return group.cursorBefore, true
+}
+
+// Re-applies the last undone group of edits. Returns the cursor index after those edits, and false if there was nothing to redo.
+func (history *History) Redo() (int, bool) {
+	history.End()
+	if len(history.redoStack) == 0 {
+		return 0, false
+	}
+	group := history.redoStack[len(history.redoStack) - 1]
+	history.redoStack = history.redoStack[:len(history.redoStack) - 1]
+	history.undoStack = append(history.undoStack, group)
+
+	for _, edit := range(group.edits) {
+		edit.apply(history.buf)
+	}
+	history.Break()
+	return group.cursorAfter, true
+}
+
+func (history *History) CanUndo() bool {
+	return len(history.undoStack) > 0 || (history.transaction != nil && len(history.transaction.edits) > 0)
+}
+
+func (history *History) CanRedo() bool {
+	return len(history.redoStack) > 0
+}
+
+// Marks the current state of the buffer as saved.
+func (history *History) MarkSaved() {
+	history.End()
+	history.Break()
+	history.savePoint = len(history.undoStack)
+}
+
+// Marks the buffer as differing from its saved state, even if all edits are undone. Used for changes that are not edits to the buffer, e.g. changing the line end mode.
+func (history *History) MarkModified() {
+	history.savePoint = -1
+}
+
+// Returns true if the buffer is in the state it was in when last saved.
+func (history *History) IsSaved() bool {
+	return history.transaction == nil && history.savePoint == len(history.undoStack)
+}
+
+// Discards all history, and marks the current state of the buffer as saved.
+func (history *History) Clear() {
+	history.undoStack = make([]*editGroup, 0)
+	history.redoStack = make([]*editGroup, 0)
+	history.transaction = nil
+	history.savePoint = 0
+}
+
+// Records an edit that is about to be applied to the buffer, coalescing it with the previous group where possible.
+func (history *History) record(edit Edit, cursorBefore, cursorAfter int) {
+	if history.transaction != nil {
+		group := history.transaction
+		if len(group.edits) == 0 {
+			group.cursorBefore = cursorBefore
+		}
+		group.edits = append(group.edits, edit)
+		group.cursorAfter = cursorAfter
+		return
+	}
+
+	if len(history.undoStack) > 0 {
+		last := history.undoStack[len(history.undoStack) - 1]
+		if !last.closed && coalesces(last.edits[len(last.edits) - 1], edit) {
+			last.edits = append(last.edits, edit)
+			last.cursorAfter = cursorAfter
+			history.redoStack = history.redoStack[:0]
+			return
+		}
+	}
+
+	history.push(&editGroup{[]Edit{edit}, cursorBefore, cursorAfter, false})
+}
+
+// Pushes a new group onto the undo stack, discarding the redo stack.
+func (history *History) push(group *editGroup) {
+	// The saved state can't be reached anymore if it was undone
+	if history.savePoint > len(history.undoStack) {
+		history.savePoint = -1
+	}
+	history.Break()
+	history.undoStack = append(history.undoStack, group)
+	history.redoStack = history.redoStack[:0]
+}
+
+// Returns true if `next` continues the typing (or deletion) of `prev` within the same word.
+func coalesces(prev, next Edit) bool {
+	// Only single characters
+	var prevText, nextText string
+	switch {
+	case prev.Deleted == "" && next.Deleted == "":
+		prevText, nextText = prev.Inserted, next.Inserted
+		if next.Index != prev.Index + utf8.RuneCountInString(prev.Inserted) {
+			return false
+		}
+	case prev.Inserted == "" && next.Inserted == "":
+		prevText, nextText = prev.Deleted, next.Deleted
+		isBackspace := next.Index + utf8.RuneCountInString(next.Deleted) == prev.Index
+		isDelete := next.Index == prev.Index
+		if !isBackspace && !isDelete {
+			return false
+		}
+	default:
+		return false
+	}
+	if utf8.RuneCountInString(prevText) != 1 || utf8.RuneCountInString(nextText) != 1 {
+		return false
+	}
+
+	// A word ends where whitespace is followed by a non-whitespace character. Newlines always start a new group.
+	prevCh, _ := utf8.DecodeRuneInString(prevText)
+	nextCh, _ := utf8.DecodeRuneInString(nextText)
+	if prevCh == '\n' || nextCh == '\n' {
+		return false
+	}
+	return !(unicode.IsSpace(prevCh) && !unicode.IsSpace(nextCh))
+}
@@ -0,0 +1,197 @@
+package textbuffer
+
+import (
+	"testing"
+)
+
+// Runs `test` against every TextBuffer implementation.
+func forEachBuffer(t *testing.T, test func(t *testing.T, buf TextBuffer)) {
+	t.Run("GapBuffer", func(t *testing.T) { test(t, NewGapBuffer()) })
+	t.Run("PieceTable", func(t *testing.T) { test(t, NewPieceTable()) })
+	t.Run("Rope", func(t *testing.T) { test(t, NewRope()) })
+}
+
+// Types `s` at `index` one character at a time, as the textbox does.
+func typeInto(history *History, index int, s string) int {
+	for _, ch := range(s) {
+		history.Insert(index, string(ch), index)
+		index++
+	}
+	return index
+}
+
+func expectString(t *testing.T, buf TextBuffer, expected string) {
+	t.Helper()
+	if buf.String() != expected {
+		t.Fatalf("Expected \"" + expected + "\", instead buf.String(): \"" + buf.String() + "\"")
+	}
+}
+
+func TestHistoryUndoRedo(t *testing.T) {
+	forEachBuffer(t, func(t *testing.T, buf TextBuffer) {
+		history := NewHistory(buf)
+		history.Insert(0, "Hello", 0)
+		history.Insert(5, " World", 5)
+		history.Delete(0, 1, 1)
+		expectString(t, buf, "ello World")
+
+		cursor, ok := history.Undo()
+		if !ok || cursor != 1 {
+			t.Fatalf("Expected undo to restore cursor 1, instead cursor: %d", cursor)
+		}
+		expectString(t, buf, "Hello World")
+		history.Undo()
+		expectString(t, buf, "Hello")
+		history.Undo()
+		expectString(t, buf, "")
+		if _, ok := history.Undo(); ok {
+			t.Fatalf("Expected nothing to undo")
+		}
+
+		cursor, ok = history.Redo()
+		if !ok || cursor != 5 {
+			t.Fatalf("Expected redo to restore cursor 5, instead cursor: %d", cursor)
+		}
+		expectString(t, buf, "Hello")
+
+		// New edits discard the redo stack
+		history.Insert(5, "!", 5)
+		if history.CanRedo() {
+			t.Fatalf("Expected redo stack to be cleared after an edit")
+		}
+		expectString(t, buf, "Hello!")
+	})
+}
+
+func TestHistoryCoalescing(t *testing.T) {
+	forEachBuffer(t, func(t *testing.T, buf TextBuffer) {
+		history := NewHistory(buf)
+		typeInto(history, 0, "the quick\nfox")
+		expectString(t, buf, "the quick\nfox")
+
+		// Groups: "the ", "quick", "\n", "fox"
+		expected := []string{"the quick\n", "the quick", "the ", ""}
+		for _, str := range(expected) {
+			history.Undo()
+			expectString(t, buf, str)
+		}
+
+		// Backspacing is coalesced too. Groups: "two ", "e"
+		index := typeInto(history, 0, "one two")
+		history.Break()
+		for i := 0; i < 5; i++ {
+			history.Delete(index - 1, index, index)
+			index--
+		}
+		expectString(t, buf, "on")
+		history.Undo()
+		expectString(t, buf, "one")
+		history.Undo()
+		expectString(t, buf, "one two")
+
+		// Typing isn't coalesced after a break, or at a different position
+		typeInto(history, 7, "ab")
+		history.Break()
+		typeInto(history, 9, "cd")
+		typeInto(history, 0, "e")
+		history.Undo()
+		expectString(t, buf, "one twoabcd")
+		history.Undo()
+		expectString(t, buf, "one twoab")
+	})
+}
+
+func TestHistoryTransaction(t *testing.T) {
+	forEachBuffer(t, func(t *testing.T, buf TextBuffer) {
+		history := NewHistory(buf)
+		typeInto(history, 0, "a b c")
+
+		history.Begin()
+		history.Delete(1, 2, 5)
+		history.Insert(1, "--", 5)
+		history.Delete(4, 5, 5)
+		history.Insert(4, "--", 5)
+		history.End()
+		expectString(t, buf, "a--b--c")
+
+		cursor, _ := history.Undo()
+		expectString(t, buf, "a b c")
+		if cursor != 5 {
+			t.Fatalf("Expected cursor 5, instead cursor: %d", cursor)
+		}
+		cursor, _ = history.Redo()
+		expectString(t, buf, "a--b--c")
+		if cursor != 6 {
+			t.Fatalf("Expected cursor 6, instead cursor: %d", cursor)
+		}
+	})
+}
+
+func TestHistoryReplace(t *testing.T) {
+	forEachBuffer(t, func(t *testing.T, buf TextBuffer) {
+		history := NewHistory(buf)
+		typeInto(history, 0, "one two één two")
+
+		replaced, err := history.Replace(4, 13, "2 ëén 2", 16)
+		if err != nil || replaced != "two één t" {
+			t.Fatalf("Unexpected replaced text %q, %v", replaced, err)
+		}
+		expectString(t, buf, "one 2 ëén 2wo")
+
+		// Replacing text with itself isn't an edit
+		history.Replace(0, 3, "one", 0)
+		cursor, _ := history.Undo()
+		expectString(t, buf, "one two één two")
+		if cursor != 16 {
+			t.Fatalf("Expected cursor 16, instead cursor: %d", cursor)
+		}
+		cursor, _ = history.Redo()
+		expectString(t, buf, "one 2 ëén 2wo")
+		if cursor != 11 {
+			t.Fatalf("Expected cursor 11, instead cursor: %d", cursor)
+		}
+	})
+}
+
+func TestHistorySavePoint(t *testing.T) {
+	forEachBuffer(t, func(t *testing.T, buf TextBuffer) {
+		history := NewHistory(buf)
+		if !history.IsSaved() {
+			t.Fatalf("Expected new history to be saved")
+		}
+
+		index := typeInto(history, 0, "abc")
+		history.MarkSaved()
+		if !history.IsSaved() {
+			t.Fatalf("Expected history to be saved after MarkSaved")
+		}
+
+		// Typing after saving is not coalesced into the saved group
+		typeInto(history, index, "d")
+		if history.IsSaved() {
+			t.Fatalf("Expected history to be modified after typing")
+		}
+		history.Undo()
+		if !history.IsSaved() {
+			t.Fatalf("Expected history to be saved after undoing back to the save point")
+		}
+		expectString(t, buf, "abc")
+
+		history.Undo()
+		if history.IsSaved() {
+			t.Fatalf("Expected history to be modified after undoing past the save point")
+		}
+		history.Redo()
+		if !history.IsSaved() {
+			t.Fatalf("Expected history to be saved after redoing to the save point")
+		}
+
+		// Once the saved state is undone and replaced, it can't be reached again
+		history.Undo()
+		typeInto(history, 0, "x")
+		history.Undo()
+		if history.IsSaved() {
+			t.Fatalf("Expected saved state to be unreachable")
+		}
+	})
+}
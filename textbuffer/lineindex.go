@@ -0,0 +1,88 @@
+package textbuffer
+
+import (
+	"sort"
+)
+
+// lineIndex: The indices of the line ends ('\n') in a buffer, kept up to date as it is edited, for finding lines in O(log n).
+// Like a gap buffer, the line ends are split at the last edit: those before it are stored as indices, and those after it as distances from the end of the buffer.
+// Edits only shift the line ends after them, so storing those relative to the end means that a run of edits in one place doesn't have to update any others.
+// [0 1 2 3 ...] split [... 3 2 1 0]
+type lineIndex struct {
+	before []int // Indices of the line ends before the split, in order
+	after []int // Distances from the end of the buffer of the line ends at or after the split, in order, so the nearest to the split is last
+	split int // Index of the split
+	length int // Length of the buffer
+}
+
+// Moves the split to `index`, moving the line ends in between to the other side.
+func (lines *lineIndex) moveSplit(index int) {
+	for len(lines.after) > 0 && lines.length - lines.after[len(lines.after) - 1] < index {
+		lines.before = append(lines.before, lines.length - lines.after[len(lines.after) - 1])
+		lines.after = lines.after[:len(lines.after) - 1]
+	}
+	for len(lines.before) > 0 && lines.before[len(lines.before) - 1] >= index {
+		lines.after = append(lines.after, lines.length - lines.before[len(lines.before) - 1])
+		lines.before = lines.before[:len(lines.before) - 1]
+	}
+	lines.split = index
+}
+
+// Records that `s` was inserted at `index`.
+func (lines *lineIndex) insert(index int, s []rune) {
+	lines.moveSplit(index)
+	for i, ch := range(s) {
+		if ch == '\n' {
+			lines.before = append(lines.before, index + i)
+		}
+	}
+	lines.length += len(s)
+	lines.split = index + len(s)
+}
+
+// Records that the runes in [start, end) were deleted.
+func (lines *lineIndex) delete(start, end int) {
+	lines.moveSplit(start)
+	for len(lines.after) > 0 && lines.length - lines.after[len(lines.after) - 1] < end {
+		lines.after = lines.after[:len(lines.after) - 1]
+	}
+	lines.length -= end - start
+}
+
+func (lines *lineIndex) clear() {
+	lines.before, lines.after = lines.before[:0], lines.after[:0]
+	lines.split, lines.length = 0, 0
+}
+
+// Returns the number of lines, i.e. one more than the number of line ends.
+func (lines *lineIndex) count() int {
+	return len(lines.before) + len(lines.after) + 1
+}
+
+// Returns the index of the end of line `line`: its line end, or the end of the buffer for the last line. `line` must be in [0, count()).
+func (lines *lineIndex) lineEnd(line int) int {
+	if line < len(lines.before) {
+		return lines.before[line]
+	}
+	if i := line - len(lines.before); i < len(lines.after) {
+		return lines.length - lines.after[len(lines.after) - 1 - i]
+	}
+	return lines.length
+}
+
+// Returns the index of the first rune of line `line`. `line` must be in [0, count()).
+func (lines *lineIndex) lineStart(line int) int {
+	if line == 0 {
+		return 0
+	}
+	return lines.lineEnd(line - 1) + 1
+}
+
+// Returns the line containing `index`, i.e. the number of line ends before it.
+func (lines *lineIndex) lineOf(index int) int {
+	if index <= lines.split {
+		return sort.SearchInts(lines.before, index)
+	}
+	// Line ends at or after the split are before `index` if they are further than length - index from the end
+	return len(lines.before) + len(lines.after) - sort.SearchInts(lines.after, lines.length - index + 1)
+}
@@ -0,0 +1,144 @@
+package textbuffer
+
+import (
+	"unicode/utf8"
+)
+
+// A change made to an Observed buffer: the edit that was applied, and the version of the buffer after it.
+type Change struct {
+	Edit
+	Version int
+}
+
+// Returns the index just after the inserted text, i.e. the end of the changed range in the buffer after the change.
+func (change Change) InsertedEnd() int {
+	return change.Index + utf8.RuneCountInString(change.Inserted)
+}
+
+// Returns the index just after the deleted text, i.e. the end of the changed range in the buffer before the change.
+func (change Change) DeletedEnd() int {
+	return change.Index + utf8.RuneCountInString(change.Deleted)
+}
+
+// Observed: A TextBuffer that notifies its observers of every change made to the buffer it wraps, so that they can update incrementally instead of re-reading the buffer.
+// Each change increments the version of the buffer. Edits that fail or change nothing aren't changes.
+type Observed struct {
+	buf TextBuffer
+	version int
+	observers []observer
+	nextID int
+}
+
+type observer struct {
+	id int
+	notify func(Change)
+}
+
+func NewObserved(buf TextBuffer) *Observed {
+	return &Observed{
+		buf,
+		0,
+		make([]observer, 0),
+		0,
+	}
+}
+
+// Calls `notify` after every change to the buffer, until the returned function is called. Observers are notified in the order they subscribed.
+func (obs *Observed) Subscribe(notify func(Change)) (unsubscribe func()) {
+	id := obs.nextID
+	obs.nextID++
+	obs.observers = append(obs.observers, observer{id, notify})
+	return func() {
+		for i, o := range(obs.observers) {
+			if o.id == id {
+				obs.observers = append(obs.observers[:i:i], obs.observers[i+1:]...)
+				return
+			}
+		}
+	}
+}
+
+// Returns the version of the buffer, the number of changes made to it so far.
+func (obs *Observed) Version() int {
+	return obs.version
+}
+
+// Notifies the observers of `edit`, if it changed the buffer.
+func (obs *Observed) notify(edit Edit) {
+	if edit.Deleted == edit.Inserted {
+		return
+	}
+	obs.version++
+	change := Change{edit, obs.version}
+	for _, o := range(obs.observers) {
+		o.notify(change)
+	}
+}
+
+func (obs *Observed) String() string { return obs.buf.String() }
+func (obs *Observed) StringBeforeIndex() string { return obs.buf.StringBeforeIndex() }
+func (obs *Observed) StringAfterInclIndex() string { return obs.buf.StringAfterInclIndex() }
+func (obs *Observed) Length() int { return obs.buf.Length() }
+func (obs *Observed) GetIndex() int { return obs.buf.GetIndex() }
+func (obs *Observed) MoveIndex(newIndex int) { obs.buf.MoveIndex(newIndex) }
+func (obs *Observed) Slice(start, end int) string { return obs.buf.Slice(start, end) }
+func (obs *Observed) LineCount() int { return obs.buf.LineCount() }
+func (obs *Observed) LineStart(line int) int { return obs.buf.LineStart(line) }
+func (obs *Observed) LineCol(index int) (int, int) { return obs.buf.LineCol(index) }
+func (obs *Observed) Line(line int) string { return obs.buf.Line(line) }
+
+func (obs *Observed) Insert(index int, ch rune) error {
+	if err := obs.buf.Insert(index, ch); err != nil {
+		return err
+	}
+	obs.notify(Edit{index, "", string(ch)})
+	return nil
+}
+
+func (obs *Observed) Append(s string) error {
+	index := obs.buf.Length()
+	if err := obs.buf.Append(s); err != nil {
+		return err
+	}
+	obs.notify(Edit{index, "", s})
+	return nil
+}
+
+func (obs *Observed) Delete(index int) (rune, error) {
+	ch, err := obs.buf.Delete(index)
+	if err != nil {
+		return ch, err
+	}
+	obs.notify(Edit{index, string(ch), ""})
+	return ch, nil
+}
+
+func (obs *Observed) Clear() {
+	deleted := obs.buf.String()
+	obs.buf.Clear()
+	obs.notify(Edit{0, deleted, ""})
+}
+
+func (obs *Observed) InsertString(index int, s string) error {
+	if err := obs.buf.InsertString(index, s); err != nil {
+		return err
+	}
+	obs.notify(Edit{index, "", s})
+	return nil
+}
+
+func (obs *Observed) DeleteRange(start, end int) string {
+	start, _ = clampRange(start, end, obs.buf.Length())
+	deleted := obs.buf.DeleteRange(start, end)
+	obs.notify(Edit{start, deleted, ""})
+	return deleted
+}
+
+func (obs *Observed) Replace(start, end int, s string) (string, error) {
+	deleted, err := obs.buf.Replace(start, end, s)
+	if err != nil {
+		return deleted, err
+	}
+	obs.notify(Edit{start, deleted, s})
+	return deleted, nil
+}
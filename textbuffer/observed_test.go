@@ -0,0 +1,71 @@
+package textbuffer
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestObservedChanges(t *testing.T) {
+	obs := NewObserved(NewGapBuffer())
+	changes := make([]Change, 0)
+	unsubscribe := obs.Subscribe(func(change Change) { changes = append(changes, change) })
+
+	obs.Append("héllo")
+	obs.Insert(5, '!')
+	obs.InsertString(0, "ab\n")
+	obs.Delete(3)
+	obs.DeleteRange(4, 100)
+	obs.Replace(0, 2, "xy")
+	obs.Clear()
+
+	// Failed edits and edits that change nothing aren't changes
+	obs.Insert(5, 'x')
+	obs.Delete(0)
+	obs.DeleteRange(0, 0)
+	obs.Replace(0, 0, "")
+	obs.Clear()
+
+	expected := []Change{
+		{Edit{0, "", "héllo"}, 1},
+		{Edit{5, "", "!"}, 2},
+		{Edit{0, "", "ab\n"}, 3},
+		{Edit{3, "h", ""}, 4},
+		{Edit{4, "llo!", ""}, 5},
+		{Edit{0, "ab", "xy"}, 6},
+		{Edit{0, "xy\né", ""}, 7},
+	}
+	if !reflect.DeepEqual(changes, expected) {
+		t.Fatalf("Expected changes %+v, instead %+v", expected, changes)
+	}
+	if obs.Version() != 7 {
+		t.Fatalf("Expected version 7, instead obs.Version(): %d", obs.Version())
+	}
+
+	unsubscribe()
+	obs.Append("more")
+	if len(changes) != len(expected) || obs.Version() != 8 {
+		t.Fatalf("Expected no changes after unsubscribing, instead %d changes at version %d", len(changes), obs.Version())
+	}
+}
+
+func TestObservedHistory(t *testing.T) {
+	// Observers see the history as of each change, so that they can tell whether the buffer is saved
+	obs := NewObserved(NewRope())
+	history := NewHistory(obs)
+	saved := make([]bool, 0)
+	obs.Subscribe(func(Change) { saved = append(saved, history.IsSaved()) })
+
+	typeInto(history, 0, "ab")
+	history.MarkSaved()
+	history.Delete(1, 2, 2)
+	history.Undo()
+	history.Undo()
+	history.Redo()
+	history.Replace(0, 2, "cd", 0)
+
+	// Undoing and redoing the typed group are two changes each
+	expected := []bool{false, false, false, true, false, false, true, true, false}
+	if !reflect.DeepEqual(saved, expected) {
+		t.Fatalf("Expected saved states %v, instead %v", expected, saved)
+	}
+}
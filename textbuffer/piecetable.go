@@ -0,0 +1,445 @@
+package textbuffer
+
+import (
+	"errors"
+	"strings"
+	"unicode/utf8"
+)
+
+// Most bytes of text in a piece, so that finding an index or a line end within a piece only scans a bounded amount of text.
+const PIECE_SIZE = 4096
+
+// A TextBuffer made of pieces of the text it was loaded with and of an add buffer that inserted text is appended to.
+// The pieces are kept in order in a balanced tree, so that an edit only splits and joins the pieces along one path of the tree.
+// Loading keeps the text as it is, only counting the runes and line ends of each piece, and since neither the nodes of the tree nor the text they refer to are ever changed, a snapshot is a copy of the root.
+type PieceTable struct {
+	root *pieceNode
+	index int
+	add strings.Builder // The add buffer. Text written to it is never changed, so pieces can refer to it while it grows
+	runStart int // Start in the add buffer of the text typed at the same place so far, or -1 if the last edit wasn't typing
+	runEnd int // Index just after the text typed so far
+}
+
+// piece: Part of the text of the table, referring to the text it was loaded with, the add buffer, or a long inserted string, which are shared and never changed.
+type piece struct {
+	text string // At most PIECE_SIZE bytes
+	length int // Number of runes in the text
+	lineEnds int // Number of line ends in the text
+}
+
+// pieceNode: A node of an AVL tree of pieces, in order. Nodes are never changed once made: an edit makes new nodes along the path it changes.
+type pieceNode struct {
+	piece piece
+	left *pieceNode
+	right *pieceNode
+	height int
+	length int // Total length of the pieces in the subtree
+	lineEnds int // Total number of line ends in the subtree
+}
+
+func NewPieceTable() *PieceTable {
+	return &PieceTable{
+		nil,
+		0,
+		strings.Builder{},
+		-1,
+		0,
+	}
+}
+
+// Returns a copy of the table that later edits to either don't affect, in constant time. It is safe to read the copy in another goroutine while editing the original.
+func (table *PieceTable) Snapshot() *PieceTable {
+	// The copy starts an add buffer of its own, so that it never writes where the original does
+	snapshot := NewPieceTable()
+	snapshot.root, snapshot.index = table.root, table.index
+	return snapshot
+}
+
+func (table *PieceTable) GetIndex() int {
+	return table.index
+}
+
+// Moves index to `newIndex`. If `newIndex` is beyond the bounds of the buffer, it stays at the closest bound.
+func (table *PieceTable) MoveIndex(newIndex int) {
+	table.index = table.clampIndex(newIndex)
+}
+
+func (table *PieceTable) clampIndex(index int) int {
+	if index < 0 {
+		return 0
+	}
+	if index > table.Length() {
+		return table.Length()
+	}
+	return index
+}
+
+func (table *PieceTable) String() string {
+	return table.Slice(0, table.Length())
+}
+
+func (table *PieceTable) StringBeforeIndex() string {
+	return table.Slice(0, table.index)
+}
+
+func (table *PieceTable) StringAfterInclIndex() string {
+	return table.Slice(table.index, table.Length())
+}
+
+func (table *PieceTable) Insert(index int, ch rune) error {
+	return table.InsertString(index, string(ch))
+}
+
+func (table *PieceTable) Append(s string) error {
+	return table.InsertString(table.Length(), s)
+}
+
+// Inserts `s` at `index`. Error raised if index is not in the range [0, Length()] (inclusive)
+// Short text is appended to the add buffer, while longer text, e.g. a loaded file, is split into pieces that refer to `s` itself.
+func (table *PieceTable) InsertString(index int, s string) error {
+	if index < 0 || index > table.Length() {
+		return errors.New("Index error")
+	}
+	table.index = index
+	if s == "" {
+		return nil
+	}
+	left, right := splitPieces(table.root, index)
+	inserted := newPiece(s)
+	table.index += inserted.length
+	if len(s) > PIECE_SIZE {
+		table.root = concatPieces(concatPieces(left, buildPieces(splitText(s))), right)
+		table.runStart = -1
+		return nil
+	}
+
+	// Typing extends the piece it typed so far, rather than adding a piece per keystroke
+	start := table.add.Len()
+	table.add.WriteString(s)
+	added := table.add.String()
+	if table.runStart >= 0 && index == table.runEnd && len(added) - table.runStart <= PIECE_SIZE {
+		rest, typed := splitLastPiece(left)
+		left, start = rest, table.runStart
+		inserted = piece{added[start:], typed.length + inserted.length, typed.lineEnds + inserted.lineEnds}
+	} else {
+		inserted.text = added[start:]
+	}
+	table.root = joinPieces(left, inserted, right)
+	table.runStart, table.runEnd = start, table.index
+	return nil
+}
+
+func (table *PieceTable) Delete(index int) (rune, error) {
+	if index < 0 || index >= table.Length() {
+		return rune(0), errors.New("Index error")
+	}
+	ch, _ := utf8.DecodeRuneInString(table.DeleteRange(index, index + 1))
+	return ch, nil
+}
+
+// Deletes and returns the runes in [start, end), by dropping the pieces in between. The range is clipped to the buffer.
+func (table *PieceTable) DeleteRange(start, end int) string {
+	start, end = table.clampIndex(start), table.clampIndex(end)
+	table.index = start
+	if end <= start {
+		return ""
+	}
+	left, rest := splitPieces(table.root, start)
+	deleted, right := splitPieces(rest, end - start)
+	table.root = concatPieces(left, right)
+	table.runStart = -1
+	return string(appendPieces(make([]byte, 0, end - start), deleted, 0, end - start))
+}
+
+func (table *PieceTable) Replace(start, end int, s string) (string, error) {
+	if start < 0 || start > end || end > table.Length() {
+		return "", errors.New("Index error")
+	}
+	deleted := table.DeleteRange(start, end)
+	return deleted, table.InsertString(start, s)
+}
+
+func (table *PieceTable) Clear() {
+	table.root = nil
+	table.index = 0
+	table.add = strings.Builder{}
+	table.runStart = -1
+}
+
+func (table *PieceTable) Length() int {
+	return nodeLength(table.root)
+}
+
+func (table *PieceTable) LineCount() int {
+	return nodeLineEnds(table.root) + 1
+}
+
+func (table *PieceTable) LineStart(line int) int {
+	line = table.clampLine(line)
+	if line == 0 {
+		return 0
+	}
+	return table.lineEnd(line - 1) + 1
+}
+
+func (table *PieceTable) LineCol(index int) (line int, col int) {
+	index = table.clampIndex(index)
+	line = table.lineEndsBefore(index)
+	return line, index - table.LineStart(line)
+}
+
+func (table *PieceTable) Line(line int) string {
+	line = table.clampLine(line)
+	end := table.Length()
+	if line < table.LineCount() - 1 {
+		end = table.lineEnd(line)
+	}
+	return table.Slice(table.LineStart(line), end)
+}
+
+func (table *PieceTable) clampLine(line int) int {
+	if line < 0 {
+		return 0
+	}
+	if line >= table.LineCount() {
+		return table.LineCount() - 1
+	}
+	return line
+}
+
+// Returns the index of line end number `n`, counted from 0, which must exist.
+func (table *PieceTable) lineEnd(n int) int {
+	node, offset := table.root, 0
+	for {
+		if n < nodeLineEnds(node.left) {
+			node = node.left
+			continue
+		}
+		n -= nodeLineEnds(node.left)
+		offset += nodeLength(node.left)
+		if n < node.piece.lineEnds {
+			return offset + node.piece.lineEnd(n)
+		}
+		n -= node.piece.lineEnds
+		offset += node.piece.length
+		node = node.right
+	}
+}
+
+// Returns the number of line ends before `index`.
+func (table *PieceTable) lineEndsBefore(index int) int {
+	node, count := table.root, 0
+	for node != nil {
+		if index <= nodeLength(node.left) {
+			node = node.left
+			continue
+		}
+		count += nodeLineEnds(node.left)
+		index -= nodeLength(node.left)
+		if index <= node.piece.length {
+			return count + strings.Count(node.piece.text[:node.piece.byteOffset(index)], "\n")
+		}
+		count += node.piece.lineEnds
+		index -= node.piece.length
+		node = node.right
+	}
+	return count
+}
+
+// Returns the runes in [start, end), collected from the pieces that overlap it.
+func (table *PieceTable) Slice(start, end int) string {
+	start, end = clampRange(start, end, table.Length())
+	return string(appendPieces(make([]byte, 0, end - start), table.root, start, end))
+}
+
+/* PIECES */
+
+func newPiece(text string) piece {
+	return piece{text, utf8.RuneCountInString(text), strings.Count(text, "\n")}
+}
+
+// Returns the pieces of `text`, split at rune boundaries into pieces of at most PIECE_SIZE bytes.
+func splitText(text string) []piece {
+	pieces := make([]piece, 0, len(text) / PIECE_SIZE + 1)
+	for len(text) > PIECE_SIZE {
+		end := PIECE_SIZE
+		for !utf8.RuneStart(text[end]) {
+			end--
+		}
+		pieces = append(pieces, newPiece(text[:end]))
+		text = text[end:]
+	}
+	return append(pieces, newPiece(text))
+}
+
+// Returns the offset in bytes of the rune at `index` in the piece.
+func (p piece) byteOffset(index int) int {
+	if len(p.text) == p.length {
+		return index
+	}
+	offset := 0
+	for i := 0; i < index; i++ {
+		_, size := utf8.DecodeRuneInString(p.text[offset:])
+		offset += size
+	}
+	return offset
+}
+
+// Returns the index in the piece of line end number `n`, counted from 0, which must exist.
+func (p piece) lineEnd(n int) int {
+	offset := 0
+	for i := 0; i < n; i++ {
+		offset += strings.IndexByte(p.text[offset:], '\n') + 1
+	}
+	offset += strings.IndexByte(p.text[offset:], '\n')
+	if len(p.text) == p.length {
+		return offset
+	}
+	return utf8.RuneCountInString(p.text[:offset])
+}
+
+// Returns the piece split into the runes before `index`, and the runes from it.
+func (p piece) split(index int) (piece, piece) {
+	offset := p.byteOffset(index)
+	before := piece{p.text[:offset], index, strings.Count(p.text[:offset], "\n")}
+	return before, piece{p.text[offset:], p.length - index, p.lineEnds - before.lineEnds}
+}
+
+/* TREE OPERATIONS */
+// Each returns a new tree, sharing the nodes it doesn't change with the trees it was given.
+
+func nodeHeight(node *pieceNode) int {
+	if node == nil {
+		return 0
+	}
+	return node.height
+}
+
+func nodeLength(node *pieceNode) int {
+	if node == nil {
+		return 0
+	}
+	return node.length
+}
+
+func nodeLineEnds(node *pieceNode) int {
+	if node == nil {
+		return 0
+	}
+	return node.lineEnds
+}
+
+func newPieceNode(p piece, left, right *pieceNode) *pieceNode {
+	height := nodeHeight(left)
+	if nodeHeight(right) > height {
+		height = nodeHeight(right)
+	}
+	return &pieceNode{
+		p,
+		left,
+		right,
+		height + 1,
+		nodeLength(left) + p.length + nodeLength(right),
+		nodeLineEnds(left) + p.lineEnds + nodeLineEnds(right),
+	}
+}
+
+// Returns a node of `p` between `left` and `right`, rotated to be balanced if their heights differ by 2.
+func balancePieces(p piece, left, right *pieceNode) *pieceNode {
+	if nodeHeight(left) > nodeHeight(right) + 1 {
+		if nodeHeight(left.left) >= nodeHeight(left.right) {
+			return newPieceNode(left.piece, left.left, newPieceNode(p, left.right, right))
+		}
+		return newPieceNode(left.right.piece, newPieceNode(left.piece, left.left, left.right.left), newPieceNode(p, left.right.right, right))
+	}
+	if nodeHeight(right) > nodeHeight(left) + 1 {
+		if nodeHeight(right.right) >= nodeHeight(right.left) {
+			return newPieceNode(right.piece, newPieceNode(p, left, right.left), right.right)
+		}
+		return newPieceNode(right.left.piece, newPieceNode(p, left, right.left.left), newPieceNode(right.piece, right.left.right, right.right))
+	}
+	return newPieceNode(p, left, right)
+}
+
+// Returns a balanced tree of the pieces of `left`, then `p`, then the pieces of `right`.
+func joinPieces(left *pieceNode, p piece, right *pieceNode) *pieceNode {
+	if nodeHeight(left) > nodeHeight(right) + 1 {
+		return balancePieces(left.piece, left.left, joinPieces(left.right, p, right))
+	}
+	if nodeHeight(right) > nodeHeight(left) + 1 {
+		return balancePieces(right.piece, joinPieces(left, p, right.left), right.right)
+	}
+	return newPieceNode(p, left, right)
+}
+
+// Returns a balanced tree of `pieces`, in order.
+func buildPieces(pieces []piece) *pieceNode {
+	if len(pieces) == 0 {
+		return nil
+	}
+	middle := len(pieces) / 2
+	return newPieceNode(pieces[middle], buildPieces(pieces[:middle]), buildPieces(pieces[middle + 1:]))
+}
+
+// Returns a balanced tree of the pieces of `left`, then the pieces of `right`.
+func concatPieces(left, right *pieceNode) *pieceNode {
+	if left == nil {
+		return right
+	}
+	rest, last := splitLastPiece(left)
+	return joinPieces(rest, last, right)
+}
+
+// Returns the tree without its last piece, and that piece. `node` must not be nil.
+func splitLastPiece(node *pieceNode) (*pieceNode, piece) {
+	if node.right == nil {
+		return node.left, node.piece
+	}
+	rest, last := splitLastPiece(node.right)
+	return joinPieces(node.left, node.piece, rest), last
+}
+
+// Returns the trees of the text before and after `index`, splitting the piece that `index` is in.
+func splitPieces(node *pieceNode, index int) (*pieceNode, *pieceNode) {
+	if node == nil {
+		return nil, nil
+	}
+	leftLength := nodeLength(node.left)
+	if index <= leftLength {
+		left, right := splitPieces(node.left, index)
+		return left, joinPieces(right, node.piece, node.right)
+	}
+	if index >= leftLength + node.piece.length {
+		left, right := splitPieces(node.right, index - leftLength - node.piece.length)
+		return joinPieces(node.left, node.piece, left), right
+	}
+	before, after := node.piece.split(index - leftLength)
+	return joinPieces(node.left, before, nil), joinPieces(nil, after, node.right)
+}
+
+// Appends the runes [start, end) of the text of the tree to `text`.
+func appendPieces(text []byte, node *pieceNode, start, end int) []byte {
+	if node == nil || start >= end {
+		return text
+	}
+	leftLength := nodeLength(node.left)
+	if start < leftLength {
+		text = appendPieces(text, node.left, start, end)
+	}
+	pieceStart, pieceEnd := start - leftLength, end - leftLength
+	if pieceStart < 0 {
+		pieceStart = 0
+	}
+	if pieceEnd > node.piece.length {
+		pieceEnd = node.piece.length
+	}
+	if pieceStart < pieceEnd {
+		p := node.piece
+		text = append(text, p.text[p.byteOffset(pieceStart):p.byteOffset(pieceEnd)]...)
+	}
+	rightStart := leftLength + node.piece.length
+	if end > rightStart {
+		text = appendPieces(text, node.right, start - rightStart, end - rightStart)
+	}
+	return text
+}
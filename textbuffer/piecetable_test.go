@@ -0,0 +1,120 @@
+package textbuffer
+
+import (
+	"math/rand"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestPieceTableSnapshot(t *testing.T) {
+	table := NewPieceTable()
+	table.Append("one\ntwo")
+	snapshot := table.Snapshot()
+
+	// Edits to either leave the other as it was
+	table.InsertString(3, " and a half")
+	table.DeleteRange(0, 1)
+	expectString(t, table, "ne and a half\ntwo")
+	expectString(t, snapshot, "one\ntwo")
+	snapshot.Insert(7, '!')
+	expectString(t, snapshot, "one\ntwo!")
+	expectString(t, table, "ne and a half\ntwo")
+
+	table.Clear()
+	expectString(t, snapshot, "one\ntwo!")
+}
+
+func TestPieceTableSnapshotConcurrent(t *testing.T) {
+	// A snapshot is read in another goroutine while the table is edited, and must stay as it was
+	rng := rand.New(rand.NewSource(6))
+	table, buf := NewPieceTable(), NewGapBuffer()
+	table.Append(strings.Repeat("the original text\n", 1000))
+	buf.Append(table.String())
+	for i := 0; i < 50; i++ {
+		snapshot, expected := table.Snapshot(), buf.String()
+		done := make(chan string)
+		go func() {
+			done <- snapshot.String()
+		}()
+		for j := 0; j < 20; j++ {
+			randomEdit(rng, table, buf)
+		}
+		if s := <-done; s != expected {
+			t.Fatalf("Expected the snapshot to be unchanged while editing, instead %d runes differ in length", len(s) - len(expected))
+		}
+
+		// Typing into the snapshot doesn't affect the table, even though they share sources
+		snapshot.InsertString(snapshot.Length(), "snapshot")
+		expectString(t, table, buf.String())
+		expectString(t, snapshot, expected + "snapshot")
+	}
+}
+
+func TestPieceTableBalanced(t *testing.T) {
+	// Typing in one place extends one piece, and edits all over the buffer keep the tree balanced
+	table := NewPieceTable()
+	table.Append("the original text")
+	for i := 0; i < 1000; i++ {
+		table.Insert(table.GetIndex(), 'x')
+	}
+	if table.root.height > 3 {
+		t.Fatalf("Expected typing to extend one piece, instead the tree has height %d", table.root.height)
+	}
+
+	rng := rand.New(rand.NewSource(3))
+	for i := 0; i < 5000; i++ {
+		table.Insert(rng.Intn(table.Length() + 1), 'y')
+		if i % 3 == 0 {
+			table.Delete(rng.Intn(table.Length()))
+		}
+	}
+	checkPieceNode(t, table.root)
+}
+
+func TestPieceTableLargeText(t *testing.T) {
+	// Long text is split into pieces at rune boundaries, whether it is loaded or inserted
+	table, buf := NewPieceTable(), NewGapBuffer()
+	text := strings.Repeat("aé中😀\n", 3000)
+	table.Append(text)
+	table.InsertString(5000, text)
+	buf.Append(text)
+	buf.InsertString(5000, text)
+	expectString(t, table, buf.String())
+	checkPieceNode(t, table.root)
+	if nodeHeight(table.root) < 3 {
+		t.Fatalf("Expected the text to be split into pieces, instead the tree has height %d", nodeHeight(table.root))
+	}
+
+	rng := rand.New(rand.NewSource(7))
+	for i := 0; i < 200; i++ {
+		randomEdit(rng, table, buf)
+		index := rng.Intn(buf.Length() + 1)
+		line, col := buf.LineCol(index)
+		if l, c := table.LineCol(index); l != line || c != col || table.LineStart(line) != buf.LineStart(line) {
+			t.Fatalf("Expected index %d at %d:%d on a line starting at %d, instead %d:%d on a line starting at %d", index, line, col, buf.LineStart(line), l, c, table.LineStart(line))
+		}
+	}
+	expectString(t, table, buf.String())
+	checkPieceNode(t, table.root)
+}
+
+// Checks that the subtree at `node` is an AVL tree with the right totals.
+func checkPieceNode(t *testing.T, node *pieceNode) {
+	t.Helper()
+	if node == nil {
+		return
+	}
+	checkPieceNode(t, node.left)
+	checkPieceNode(t, node.right)
+	if balance := nodeHeight(node.left) - nodeHeight(node.right); balance < -1 || balance > 1 {
+		t.Fatalf("Expected a balanced tree, instead subtrees of heights %d and %d", nodeHeight(node.left), nodeHeight(node.right))
+	}
+	expected := newPieceNode(node.piece, node.left, node.right)
+	if node.height != expected.height || node.length != expected.length || node.lineEnds != expected.lineEnds {
+		t.Fatalf("Expected height %d, length %d and %d line ends, instead %d, %d and %d", expected.height, expected.length, expected.lineEnds, node.height, node.length, node.lineEnds)
+	}
+	if p := node.piece; p.length != utf8.RuneCountInString(p.text) || p.lineEnds != strings.Count(p.text, "\n") || len(p.text) > PIECE_SIZE {
+		t.Fatalf("Expected a piece of at most %d bytes with its runes and line ends counted, instead %d bytes counted as %d runes and %d line ends", PIECE_SIZE, len(p.text), p.length, p.lineEnds)
+	}
+}
@@ -0,0 +1,366 @@
+package textbuffer
+
+import (
+	"errors"
+)
+
+// Largest number of runes in a leaf of a rope. Edits copy at most a leaf, and line lookups scan at most a leaf.
+const ROPE_CHUNK_SIZE = 1024
+
+// A TextBuffer stored as a rope: a balanced tree whose leaves hold chunks of the text in order, and whose nodes cache the length and number of line ends below them.
+// Nodes and chunks are never changed once made, as an edit makes new nodes along the path it changes. So a snapshot is a copy of the root, which can be read by another goroutine while this one keeps editing.
+type Rope struct {
+	root *ropeNode
+	index int
+}
+
+// ropeNode: A leaf holding a chunk of the text, or a branch joining two subtrees. Nodes are AVL balanced by height.
+type ropeNode struct {
+	left *ropeNode // nil for leaves
+	right *ropeNode // nil for leaves
+	chunk []rune // Text of a leaf, of at most ROPE_CHUNK_SIZE runes
+	height int // 0 for leaves
+	length int // Number of runes in the subtree
+	lineEnds int // Number of line ends in the subtree
+}
+
+func NewRope() *Rope {
+	return &Rope{
+		nil,
+		0,
+	}
+}
+
+// Returns a copy of the rope that later edits to either don't affect, in constant time. It is safe to read the copy in another goroutine while editing the original.
+func (rope *Rope) Snapshot() *Rope {
+	snapshot := *rope
+	return &snapshot
+}
+
+func (rope *Rope) GetIndex() int {
+	return rope.index
+}
+
+// Moves index to `newIndex`. If `newIndex` is beyond the bounds of the buffer, it stays at the closest bound.
+func (rope *Rope) MoveIndex(newIndex int) {
+	rope.index = rope.clampIndex(newIndex)
+}
+
+func (rope *Rope) clampIndex(index int) int {
+	if index < 0 {
+		return 0
+	}
+	if index > rope.Length() {
+		return rope.Length()
+	}
+	return index
+}
+
+func (rope *Rope) String() string {
+	return rope.Slice(0, rope.Length())
+}
+
+func (rope *Rope) StringBeforeIndex() string {
+	return rope.Slice(0, rope.index)
+}
+
+func (rope *Rope) StringAfterInclIndex() string {
+	return rope.Slice(rope.index, rope.Length())
+}
+
+func (rope *Rope) Insert(index int, ch rune) error {
+	return rope.InsertString(index, string(ch))
+}
+
+func (rope *Rope) Append(s string) error {
+	return rope.InsertString(rope.Length(), s)
+}
+
+// Inserts `s` at `index`, in O(log n) plus the length of `s`. Error raised if index is not in the range [0, Length()] (inclusive)
+func (rope *Rope) InsertString(index int, s string) error {
+	if index < 0 || index > rope.Length() {
+		return errors.New("Index error")
+	}
+	runes := []rune(s)
+	left, right := splitRope(rope.root, index)
+	rope.root = concatRope(concatRope(left, buildRope(runes)), right)
+	rope.index = index + len(runes)
+	return nil
+}
+
+func (rope *Rope) Delete(index int) (rune, error) {
+	if index < 0 || index >= rope.Length() {
+		return rune(0), errors.New("Index error")
+	}
+	return []rune(rope.DeleteRange(index, index + 1))[0], nil
+}
+
+// Deletes and returns the runes in [start, end), in O(log n) plus the length of the range. The range is clipped to the buffer.
+func (rope *Rope) DeleteRange(start, end int) string {
+	start, end = rope.clampIndex(start), rope.clampIndex(end)
+	rope.index = start
+	if end <= start {
+		return ""
+	}
+	deleted := rope.Slice(start, end)
+	left, rest := splitRope(rope.root, start)
+	_, right := splitRope(rest, end - start)
+	rope.root = concatRope(left, right)
+	return deleted
+}
+
+func (rope *Rope) Replace(start, end int, s string) (string, error) {
+	if start < 0 || start > end || end > rope.Length() {
+		return "", errors.New("Index error")
+	}
+	deleted := rope.DeleteRange(start, end)
+	return deleted, rope.InsertString(start, s)
+}
+
+func (rope *Rope) Clear() {
+	rope.root = nil
+	rope.index = 0
+}
+
+func (rope *Rope) Length() int {
+	return ropeLength(rope.root)
+}
+
+func (rope *Rope) LineCount() int {
+	return ropeLineEnds(rope.root) + 1
+}
+
+func (rope *Rope) LineStart(line int) int {
+	line = rope.clampLine(line)
+	if line == 0 {
+		return 0
+	}
+	return rope.lineEnd(line - 1) + 1
+}
+
+func (rope *Rope) LineCol(index int) (line int, col int) {
+	index = rope.clampIndex(index)
+	line = rope.lineEndsBefore(index)
+	return line, index - rope.LineStart(line)
+}
+
+func (rope *Rope) Line(line int) string {
+	line = rope.clampLine(line)
+	end := rope.Length()
+	if line < rope.LineCount() - 1 {
+		end = rope.lineEnd(line)
+	}
+	return rope.Slice(rope.LineStart(line), end)
+}
+
+func (rope *Rope) clampLine(line int) int {
+	if line < 0 {
+		return 0
+	}
+	if line >= rope.LineCount() {
+		return rope.LineCount() - 1
+	}
+	return line
+}
+
+// Returns the index of line end number `n`, counted from 0, which must exist.
+func (rope *Rope) lineEnd(n int) int {
+	node, offset := rope.root, 0
+	for node.chunk == nil {
+		if n < node.left.lineEnds {
+			node = node.left
+		} else {
+			n -= node.left.lineEnds
+			offset += node.left.length
+			node = node.right
+		}
+	}
+	for i, ch := range(node.chunk) {
+		if ch == '\n' {
+			if n == 0 {
+				return offset + i
+			}
+			n--
+		}
+	}
+	panic("textbuffer: line end counts of rope are inconsistent")
+}
+
+// Returns the number of line ends before `index`.
+func (rope *Rope) lineEndsBefore(index int) int {
+	node, count := rope.root, 0
+	if node == nil {
+		return 0
+	}
+	for node.chunk == nil {
+		if index <= node.left.length {
+			node = node.left
+		} else {
+			count += node.left.lineEnds
+			index -= node.left.length
+			node = node.right
+		}
+	}
+	return count + countLineEnds(node.chunk[:index])
+}
+
+// Returns the runes in [start, end), in O(log n) plus the length of the range.
+func (rope *Rope) Slice(start, end int) string {
+	start, end = clampRange(start, end, rope.Length())
+	return string(appendRope(make([]rune, 0, end - start), rope.root, start, end))
+}
+
+/* TREE OPERATIONS */
+// Each returns a new tree, sharing the nodes and chunks it doesn't change with the trees it was given.
+
+func ropeLength(node *ropeNode) int {
+	if node == nil {
+		return 0
+	}
+	return node.length
+}
+
+func ropeLineEnds(node *ropeNode) int {
+	if node == nil {
+		return 0
+	}
+	return node.lineEnds
+}
+
+func countLineEnds(runes []rune) int {
+	count := 0
+	for _, ch := range(runes) {
+		if ch == '\n' {
+			count++
+		}
+	}
+	return count
+}
+
+// Returns a leaf of `chunk`, which must not be changed afterwards.
+func newRopeLeaf(chunk []rune) *ropeNode {
+	return &ropeNode{nil, nil, chunk, 0, len(chunk), countLineEnds(chunk)}
+}
+
+func newRopeBranch(left, right *ropeNode) *ropeNode {
+	height := left.height
+	if right.height > height {
+		height = right.height
+	}
+	return &ropeNode{left, right, nil, height + 1, left.length + right.length, left.lineEnds + right.lineEnds}
+}
+
+// Returns a balanced tree of the chunks of `runes`, which must not be changed afterwards.
+func buildRope(runes []rune) *ropeNode {
+	if len(runes) == 0 {
+		return nil
+	}
+	if len(runes) <= ROPE_CHUNK_SIZE {
+		return newRopeLeaf(runes[:len(runes):len(runes)])
+	}
+	// Split on a multiple of the chunk size, so that only the last chunk is short
+	chunks := (len(runes) + ROPE_CHUNK_SIZE - 1) / ROPE_CHUNK_SIZE
+	mid := chunks / 2 * ROPE_CHUNK_SIZE
+	return newRopeBranch(buildRope(runes[:mid:mid]), buildRope(runes[mid:]))
+}
+
+// Returns a branch of `left` and `right`, rotated to be balanced if their heights differ by 2.
+func balanceRope(left, right *ropeNode) *ropeNode {
+	if left.height > right.height + 1 {
+		if left.left.height >= left.right.height {
+			return newRopeBranch(left.left, newRopeBranch(left.right, right))
+		}
+		return newRopeBranch(newRopeBranch(left.left, left.right.left), newRopeBranch(left.right.right, right))
+	}
+	if right.height > left.height + 1 {
+		if right.right.height >= right.left.height {
+			return newRopeBranch(newRopeBranch(left, right.left), right.right)
+		}
+		return newRopeBranch(newRopeBranch(left, right.left.left), newRopeBranch(right.left.right, right.right))
+	}
+	return newRopeBranch(left, right)
+}
+
+// Returns a balanced tree of the text of `left` followed by that of `right`.
+// The leaves meeting at the join are merged if they fit in a chunk, so that typing a rune at a time doesn't make a leaf per rune.
+func concatRope(left, right *ropeNode) *ropeNode {
+	if left == nil {
+		return right
+	}
+	if right == nil {
+		return left
+	}
+	last, first := left, right
+	for last.chunk == nil {
+		last = last.right
+	}
+	for first.chunk == nil {
+		first = first.left
+	}
+	if last.length + first.length > ROPE_CHUNK_SIZE {
+		return joinRope(left, right)
+	}
+
+	left, _ = splitRope(left, left.length - last.length)
+	_, right = splitRope(right, first.length)
+	chunk := make([]rune, 0, last.length + first.length)
+	chunk = append(append(chunk, last.chunk...), first.chunk...)
+	return joinRope(joinRope(left, newRopeLeaf(chunk)), right)
+}
+
+// Returns a balanced tree of the text of `left` followed by that of `right`, keeping their leaves as they are.
+func joinRope(left, right *ropeNode) *ropeNode {
+	if left == nil {
+		return right
+	}
+	if right == nil {
+		return left
+	}
+	if left.height > right.height + 1 {
+		return balanceRope(left.left, joinRope(left.right, right))
+	}
+	if right.height > left.height + 1 {
+		return balanceRope(joinRope(left, right.left), right.right)
+	}
+	return newRopeBranch(left, right)
+}
+
+// Returns the trees of the text before and after `index`, splitting the leaf that `index` is in.
+func splitRope(node *ropeNode, index int) (*ropeNode, *ropeNode) {
+	if node == nil {
+		return nil, nil
+	}
+	if index <= 0 {
+		return nil, node
+	}
+	if index >= node.length {
+		return node, nil
+	}
+	if node.chunk != nil {
+		return newRopeLeaf(node.chunk[:index:index]), newRopeLeaf(node.chunk[index:])
+	}
+	if index <= node.left.length {
+		left, right := splitRope(node.left, index)
+		return left, joinRope(right, node.right)
+	}
+	left, right := splitRope(node.right, index - node.left.length)
+	return joinRope(node.left, left), right
+}
+
+// Appends the runes [start, end) of the text of the tree to `runes`.
+func appendRope(runes []rune, node *ropeNode, start, end int) []rune {
+	if node == nil || start >= end || end <= 0 || start >= node.length {
+		return runes
+	}
+	if node.chunk != nil {
+		if start < 0 {
+			start = 0
+		}
+		if end > node.length {
+			end = node.length
+		}
+		return append(runes, node.chunk[start:end]...)
+	}
+	runes = appendRope(runes, node.left, start, end)
+	return appendRope(runes, node.right, start - node.left.length, end - node.left.length)
+}
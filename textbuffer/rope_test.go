@@ -0,0 +1,135 @@
+package textbuffer
+
+import (
+	"math/rand"
+	"strings"
+	"testing"
+)
+
+// Applies the same random edit to `edited` and `buf`, as the textbox would: typing, deleting, pasting, cutting, or loading a file.
+func randomEdit(rng *rand.Rand, edited TextBuffer, buf *GapBuffer) {
+	index := rng.Intn(buf.Length() + 1)
+	switch n := rng.Intn(20); {
+	case n < 8:
+		ch := []rune("ab \né")[rng.Intn(5)]
+		edited.Insert(index, ch)
+		buf.Insert(index, ch)
+	case n < 14:
+		edited.Delete(index)
+		buf.Delete(index)
+	case n < 17:
+		s := strings.Repeat("line of text\n", rng.Intn(200))
+		edited.InsertString(index, s)
+		buf.InsertString(index, s)
+	case n < 19:
+		end := index + rng.Intn(3000)
+		edited.DeleteRange(index, end)
+		buf.DeleteRange(index, end)
+	default:
+		s := strings.Repeat("a much longer line of text, as in a file\n", rng.Intn(500))
+		edited.Clear()
+		edited.Append(s)
+		buf.Clear()
+		buf.Append(s)
+	}
+}
+
+func TestRopeRandom(t *testing.T) {
+	// Every query of the rope is checked against a GapBuffer after each edit
+	rng := rand.New(rand.NewSource(4))
+	rope, buf := NewRope(), NewGapBuffer()
+	for i := 0; i < 3000; i++ {
+		randomEdit(rng, rope, buf)
+		checkRopeNode(t, rope.root)
+
+		if rope.Length() != buf.Length() || rope.GetIndex() != buf.GetIndex() || rope.LineCount() != buf.LineCount() {
+			t.Fatalf("Expected length %d, index %d and %d lines, instead %d, %d and %d", buf.Length(), buf.GetIndex(), buf.LineCount(), rope.Length(), rope.GetIndex(), rope.LineCount())
+		}
+		if rope.StringBeforeIndex() != buf.StringBeforeIndex() || rope.StringAfterInclIndex() != buf.StringAfterInclIndex() {
+			t.Fatalf("Expected %q|%q, instead %q|%q", buf.StringBeforeIndex(), buf.StringAfterInclIndex(), rope.StringBeforeIndex(), rope.StringAfterInclIndex())
+		}
+		for j := 0; j < 5; j++ {
+			index := rng.Intn(buf.Length() + 1)
+			line, col := buf.LineCol(index)
+			if l, c := rope.LineCol(index); l != line || c != col {
+				t.Fatalf("Expected index %d at %d:%d, instead %d:%d", index, line, col, l, c)
+			}
+			if rope.LineStart(line) != buf.LineStart(line) || rope.Line(line) != buf.Line(line) {
+				t.Fatalf("Expected line %d to be %q at %d, instead %q at %d", line, buf.Line(line), buf.LineStart(line), rope.Line(line), rope.LineStart(line))
+			}
+			end := index + rng.Intn(buf.Length() - index + 1)
+			if rope.Slice(index, end) != buf.Slice(index, end) {
+				t.Fatalf("Expected [%d, %d) to be %q, instead %q", index, end, buf.Slice(index, end), rope.Slice(index, end))
+			}
+		}
+	}
+}
+
+func TestRopeSnapshot(t *testing.T) {
+	// A snapshot is read in another goroutine while the rope is edited, and must stay as it was
+	rng := rand.New(rand.NewSource(5))
+	rope, buf := NewRope(), NewGapBuffer()
+	rope.Append(strings.Repeat("the original text\n", 1000))
+	buf.Append(rope.String())
+	for i := 0; i < 50; i++ {
+		snapshot, expected := rope.Snapshot(), buf.String()
+		done := make(chan string)
+		go func() {
+			done <- snapshot.String()
+		}()
+		for j := 0; j < 20; j++ {
+			randomEdit(rng, rope, buf)
+		}
+		if s := <-done; s != expected {
+			t.Fatalf("Expected the snapshot to be unchanged while editing, instead %d runes differ in length", len(s) - len(expected))
+		}
+		if snapshot.String() != expected {
+			t.Fatalf("Expected the snapshot to be unchanged after editing")
+		}
+	}
+}
+
+func TestRopeChunks(t *testing.T) {
+	// Typing a rune at a time fills chunks rather than making a leaf per rune
+	rope := NewRope()
+	for i := 0; i < 10 * ROPE_CHUNK_SIZE; i++ {
+		rope.Insert(rope.GetIndex(), 'x')
+	}
+	leaves := 0
+	var count func(node *ropeNode)
+	count = func(node *ropeNode) {
+		if node.chunk != nil {
+			leaves++
+			return
+		}
+		count(node.left)
+		count(node.right)
+	}
+	count(rope.root)
+	if leaves > 20 {
+		t.Fatalf("Expected at most 20 leaves, instead %d", leaves)
+	}
+}
+
+// Checks that the subtree at `node` is an AVL tree with the right totals and chunk sizes.
+func checkRopeNode(t *testing.T, node *ropeNode) {
+	t.Helper()
+	if node == nil {
+		return
+	}
+	if node.chunk != nil {
+		if node.length != len(node.chunk) || node.length == 0 || node.length > ROPE_CHUNK_SIZE || node.lineEnds != countLineEnds(node.chunk) {
+			t.Fatalf("Unexpected leaf: %d runes, %d line ends, of %q", node.length, node.lineEnds, string(node.chunk))
+		}
+		return
+	}
+	checkRopeNode(t, node.left)
+	checkRopeNode(t, node.right)
+	if balance := node.left.height - node.right.height; balance < -1 || balance > 1 {
+		t.Fatalf("Expected a balanced tree, instead subtrees of heights %d and %d", node.left.height, node.right.height)
+	}
+	expected := newRopeBranch(node.left, node.right)
+	if node.height != expected.height || node.length != expected.length || node.lineEnds != expected.lineEnds {
+		t.Fatalf("Expected height %d, %d runes and %d line ends, instead %d, %d and %d", expected.height, expected.length, expected.lineEnds, node.height, node.length, node.lineEnds)
+	}
+}
@@ -2,40 +2,76 @@ package textbuffer
 
 import (
 	"errors"
+	"unicode/utf8"
 )
 
+// TextBuffer: A sequence of runes, with an index that edits move to, so that edits near each other can be cheaper.
+// Indices are of runes, counted from 0. An edit that returns an error leaves the buffer and the index unchanged.
+// The conformance package checks that an implementation meets this contract.
 type TextBuffer interface {
 	String() string // Returns contents of the textbuffer as a string
 	StringBeforeIndex() string // Returns contents of the textbuffer before `index`
 	StringAfterInclIndex() string // Returns contents of the textbuffer after and including `index`
-	Insert(index int, ch rune) error // Inserts `ch` into the string at `index`. Error raised if index is not in the range [0, Textbuffer.Length()] (inclusive)
-	Append(s string) error // Appends a string `s` into the end of the buffer.
-	Delete(index int) rune // Deletes and returns the character at `index`.
-	Clear() // Clears the buffer.
+	Insert(index int, ch rune) error // Inserts `ch` into the string at `index`, moving index to just after it. Error raised if index is not in the range [0, Textbuffer.Length()] (inclusive)
+	Append(s string) error // Appends a string `s` into the end of the buffer, moving index to the end.
+	Delete(index int) (rune, error) // Deletes and returns the character at `index`, moving index to `index`. Error raised if index is not in the range [0, Textbuffer.Length()) (exclusive)
+	Clear() // Clears the buffer, moving index to 0.
 	Length() int // Returns the size of the buffer
 	GetIndex() int // Returns the current index
-	MoveIndex(newIndex int) // Moves index to a new index
+	MoveIndex(newIndex int) // Moves index to a new index, clamped to [0, Length()]
+	InsertString(index int, s string) error // Inserts `s` at `index`, moving index to just after it. Error raised if index is not in the range [0, Textbuffer.Length()] (inclusive)
+	DeleteRange(start, end int) string // Deletes and returns the characters in [start, end), moving index to `start`. The range is clipped to [0, Length()]
+	Slice(start, end int) string // Returns the characters in [start, end), without moving index. The range is clipped to [0, Length()]
+	Replace(start, end int, s string) (string, error) // Replaces the characters in [start, end) with `s` and returns them, moving index to just after `s`. Error raised if the range is not within [0, Length()]
+	LineCount() int // Returns the number of lines, i.e. one more than the number of line ends ('\n')
+	LineStart(line int) int // Returns the index of the first character of line `line`, counted from 0. `line` is clamped to [0, LineCount())
+	LineCol(index int) (line int, col int) // Returns the line and column of `index`, both counted from 0. `index` is clamped to [0, Length()]
+	Line(line int) string // Returns line `line` without its line end. `line` is clamped to [0, LineCount())
+}
+
+// Kinds of TextBuffer, as chosen at startup
+const GAP_BUFFER = "gap"
+const PIECE_TABLE = "piece"
+const ROPE = "rope"
+
+var Kinds = []string{GAP_BUFFER, PIECE_TABLE, ROPE}
+
+// Returns a new, empty TextBuffer of kind `kind`, one of Kinds. Any other kind gives a GapBuffer.
+func New(kind string) TextBuffer {
+	switch kind {
+	case PIECE_TABLE:
+		return NewPieceTable()
+	case ROPE:
+		return NewRope()
+	}
+	return NewGapBuffer()
 }
 
 // A dynamic array with efficient insertion at a particular index
-// [0 1 2 3 ...][ GAP ][... 3 2 1 0]
-// Invariant: cursorIndex is always at len(left)
+// [0 1 2 3 ...][ GAP ][... n-2 n-1]
+// The runes are stored in one array, with a gap at the index of the last edit. Moving the index copies the runes between it and the gap across the gap, and inserting fills the gap.
+// Invariant: the gap starts at the current index, i.e. gapStart is the current index
 type GapBuffer struct {
-	left []rune
-	right []rune
-	cursorIndex int
+	data []rune
+	gapStart int // Index in data of the first rune of the gap
+	gapEnd int // Index in data of the first rune after the gap
+	lines lineIndex
 }
 
+// Smallest capacity the buffer grows to, so that small buffers don't grow one rune at a time.
+const GAPBUFFER_MIN_CAPACITY = 64
+
 func NewGapBuffer() *GapBuffer {
 	return &GapBuffer{
-		make([]rune, 0),
 		make([]rune, 0),
 		0,
+		0,
+		lineIndex{},
 	}
 }
 
 func (buf *GapBuffer) GetIndex() int {
-	return buf.cursorIndex
+	return buf.gapStart
 }
 
 // Moves index to `newIndex`. If `newIndex` is beyond the bounds of the buffer, it stays at the closest bound.
@@ -45,44 +81,53 @@ func (buf *GapBuffer) MoveIndex(newIndex int) {
 	} else if newIndex > buf.Length() {
 		newIndex = buf.Length()
 	}
-	buf.cursorIndex = newIndex
 
-	for buf.cursorIndex > len(buf.left) {
-		// Move characters from buf.right to buf.left
-		ch := buf.right[len(buf.right) - 1]
-		buf.right = buf.right[:len(buf.right) - 1]
-		buf.left = append(buf.left, ch)
+	if newIndex < buf.gapStart {
+		// Move the runes in [newIndex, gapStart) to the end of the gap
+		count := buf.gapStart - newIndex
+		copy(buf.data[buf.gapEnd - count:buf.gapEnd], buf.data[newIndex:buf.gapStart])
+		buf.gapStart -= count
+		buf.gapEnd -= count
+	} else if newIndex > buf.gapStart {
+		// Move the runes after the gap to its start
+		count := newIndex - buf.gapStart
+		copy(buf.data[buf.gapStart:buf.gapStart + count], buf.data[buf.gapEnd:buf.gapEnd + count])
+		buf.gapStart += count
+		buf.gapEnd += count
 	}
+}
 
-	for buf.cursorIndex < len(buf.left) {
-		// Move characters from buf.left to buf.right
-		ch := buf.left[len(buf.left) - 1]
-		buf.left = buf.left[:len(buf.left) - 1]
-		buf.right = append(buf.right, ch)
+// Makes the gap at least `size` runes long, growing the array by at least double so that a run of insertions takes amortised constant time per rune.
+func (buf *GapBuffer) growGap(size int) {
+	if buf.gapEnd - buf.gapStart >= size {
+		return
+	}
+	capacity := 2 * len(buf.data)
+	if capacity < buf.Length() + size {
+		capacity = buf.Length() + size
 	}
+	if capacity < GAPBUFFER_MIN_CAPACITY {
+		capacity = GAPBUFFER_MIN_CAPACITY
+	}
+
+	data := make([]rune, capacity)
+	copy(data, buf.data[:buf.gapStart])
+	afterGap := len(buf.data) - buf.gapEnd
+	copy(data[capacity - afterGap:], buf.data[buf.gapEnd:])
+	buf.data = data
+	buf.gapEnd = capacity - afterGap
 }
 
 func (buf *GapBuffer) String() string {
-	fullBuffer := make([]rune, buf.Length())
-	copy(fullBuffer, buf.left)
-	for i, ch := range(buf.right) {
-		fullBufferIndex := len(fullBuffer) -1 - i
-		fullBuffer[fullBufferIndex] = ch
-	}
-	return string(fullBuffer)
+	return string(buf.data[:buf.gapStart]) + string(buf.data[buf.gapEnd:])
 }
 
 func (buf *GapBuffer) StringBeforeIndex() string {
-	return string(buf.left)
+	return string(buf.data[:buf.gapStart])
 }
 
 func (buf *GapBuffer) StringAfterInclIndex() string {
-	buffer := make([]rune, len(buf.right))
-	for i, ch := range(buf.right) {
-		bufferIndex := len(buffer) - 1 - i
-		buffer[bufferIndex] = ch
-	}
-	return string(buffer)
+	return string(buf.data[buf.gapEnd:])
 }
 
 func (buf *GapBuffer) Insert(index int, ch rune) error {
@@ -90,48 +135,147 @@ func (buf *GapBuffer) Insert(index int, ch rune) error {
 		return errors.New("Index error")
 	}
 	
-	if index != buf.cursorIndex {
+	if index != buf.gapStart {
 		buf.MoveIndex(index)
 	}
 
-	// invariant after MoveIndex: insertion always appends to left stack
-	buf.left = append(buf.left, ch)
-	buf.cursorIndex++
+	// invariant after MoveIndex: insertion always fills the start of the gap
+	buf.growGap(1)
+	buf.data[buf.gapStart] = ch
+	buf.gapStart++
+	buf.lines.insert(index, buf.data[index:buf.gapStart])
 
 	return nil
 }
 
 func (buf *GapBuffer) Append(s string) error {
-	for _, c := range(s) {
-		err := buf.Insert(buf.Length(), c)
-		if err != nil {
-			return err
-		}
+	return buf.InsertString(buf.Length(), s)
+}
+
+func (buf *GapBuffer) Delete(index int) (rune, error) {
+	if index < 0 || index >= buf.Length() {
+		return rune(0), errors.New("Index error")
+	}
+
+	if index != buf.gapStart {
+		buf.MoveIndex(index)
+	}
+
+	// invariant after MoveIndex: deletion always takes the rune after the gap
+	ch := buf.data[buf.gapEnd]
+	buf.gapEnd++
+	buf.lines.delete(buf.gapStart, buf.gapStart + 1)
+	return ch, nil
+}
+
+// Inserts `s` at `index` in one step, rather than a rune at a time. Error raised if index is not in the range [0, Length()] (inclusive)
+func (buf *GapBuffer) InsertString(index int, s string) error {
+	if index < 0 || index > buf.Length() {
+		return errors.New("Index error")
+	}
+	buf.MoveIndex(index)
+	buf.growGap(utf8.RuneCountInString(s))
+	for _, ch := range(s) {
+		buf.data[buf.gapStart] = ch
+		buf.gapStart++
 	}
+	buf.lines.insert(index, buf.data[index:buf.gapStart])
 	return nil
 }
 
-func (buf *GapBuffer) Delete(index int) rune {
-	if index != buf.cursorIndex {
-		buf.MoveIndex(index)
+// Deletes and returns the runes in [start, end) in one step, rather than a rune at a time. The range is clipped to [0, Length()].
+func (buf *GapBuffer) DeleteRange(start, end int) string {
+	buf.MoveIndex(start)
+	count := end - buf.gapStart
+	if count > len(buf.data) - buf.gapEnd {
+		count = len(buf.data) - buf.gapEnd
+	}
+	if count <= 0 {
+		return ""
 	}
 
-	// invariant after MoveIndex: deletion always deletes from right stack
-	if len(buf.right) == 0 {
-		return rune(0) // \0 character
+	deleted := string(buf.data[buf.gapEnd:buf.gapEnd + count])
+	buf.gapEnd += count
+	buf.lines.delete(buf.gapStart, buf.gapStart + count)
+	return deleted
+}
+
+func (buf *GapBuffer) Slice(start, end int) string {
+	start, end = clampRange(start, end, buf.Length())
+	if end <= buf.gapStart {
+		return string(buf.data[start:end])
 	}
+	gap := buf.gapEnd - buf.gapStart
+	if start >= buf.gapStart {
+		return string(buf.data[start + gap:end + gap])
+	}
+	return string(buf.data[start:buf.gapStart]) + string(buf.data[buf.gapEnd:end + gap])
+}
 
-	ch := buf.right[len(buf.right)-1]
-	buf.right = buf.right[:len(buf.right)-1]
-	return ch
+// Replaces the runes in [start, end) with `s`, deleting them and filling the gap with `s` after moving it once.
+func (buf *GapBuffer) Replace(start, end int, s string) (string, error) {
+	if start < 0 || start > end || end > buf.Length() {
+		return "", errors.New("Index error")
+	}
+	deleted := buf.DeleteRange(start, end)
+	return deleted, buf.InsertString(start, s)
 }
 
 func (buf *GapBuffer) Clear() {
-	buf.left = make([]rune, 0)
-	buf.right = make([]rune, 0)
-	buf.cursorIndex = 0
+	buf.data = make([]rune, 0)
+	buf.gapStart, buf.gapEnd = 0, 0
+	buf.lines.clear()
 }
 
 func (buf *GapBuffer) Length() int {
-	return len(buf.left) + len(buf.right)
+	return len(buf.data) - (buf.gapEnd - buf.gapStart)
+}
+
+func (buf *GapBuffer) LineCount() int {
+	return buf.lines.count()
+}
+
+func (buf *GapBuffer) LineStart(line int) int {
+	return buf.lines.lineStart(buf.clampLine(line))
+}
+
+func (buf *GapBuffer) LineCol(index int) (line int, col int) {
+	if index < 0 {
+		index = 0
+	} else if index > buf.Length() {
+		index = buf.Length()
+	}
+	line = buf.lines.lineOf(index)
+	return line, index - buf.lines.lineStart(line)
+}
+
+func (buf *GapBuffer) Line(line int) string {
+	line = buf.clampLine(line)
+	return buf.Slice(buf.lines.lineStart(line), buf.lines.lineEnd(line))
+}
+
+func (buf *GapBuffer) clampLine(line int) int {
+	if line < 0 {
+		return 0
+	}
+	if line >= buf.lines.count() {
+		return buf.lines.count() - 1
+	}
+	return line
+}
+
+// Returns the range [start, end) clipped to [0, length], with an empty range at `start` if end < start.
+func clampRange(start, end, length int) (int, int) {
+	if start < 0 {
+		start = 0
+	} else if start > length {
+		start = length
+	}
+	if end > length {
+		end = length
+	}
+	if end < start {
+		end = start
+	}
+	return start, end
 }
@@ -0,0 +1,318 @@
+package tui
+
+import (
+	"github.com/gdamore/tcell/v2"
+	"github.com/Rye123/notepad--/util"
+)
+
+const DIALOG_DEFAULT_WIDTH = 60
+
+// Dialog results
+const DIALOG_CANCEL = "" // Result of a dialog closed with Esc
+const DIALOG_OK = "OK"
+const DIALOG_YES = "Yes"
+const DIALOG_NO = "No"
+
+// Dialog: A modal window drawn over the other elements, containing a vertical stack of widgets.
+// Tab and Shift-Tab move the focus between widgets, Esc cancels the dialog, and an Enter that is not used by the focused widget presses the default (first) button.
+type Dialog struct {
+	hidden bool
+	title string
+	width int
+	widgets []Widget
+	focusIndex int
+	buttons *Buttons // Row of buttons that closes the dialog, if any
+	closed bool
+	result string // Label of the button that closed the dialog, or DIALOG_CANCEL
+	onClose func(result string)
+	appstate *util.AppState
+}
+
+func NewDialog(appstate *util.AppState, title string) *Dialog {
+	return &Dialog{false, title, DIALOG_DEFAULT_WIDTH, make([]Widget, 0), -1, nil, false, DIALOG_CANCEL, nil, appstate}
+}
+
+// Sets the preferred width of the dialog, including borders. The dialog is narrowed to fit on screen if necessary.
+func (dialog *Dialog) SetWidth(width int) {
+	dialog.width = width
+}
+
+// Adds a widget to the bottom of the dialog. The first focusable widget added receives the focus.
+func (dialog *Dialog) AddWidget(widget Widget) {
+	dialog.widgets = append(dialog.widgets, widget)
+	if dialog.focusIndex < 0 && widget.CanFocus() {
+		dialog.focusIndex = len(dialog.widgets) - 1
+	}
+}
+
+// Adds a row of buttons to the bottom of the dialog. Pressing any of them closes the dialog, with the button's label as the result.
+func (dialog *Dialog) AddButtons(labels ...string) *Buttons {
+	buttons := NewButtons(dialog.appstate, dialog.Close, labels...)
+	if dialog.buttons == nil {
+		dialog.buttons = buttons
+	}
+	dialog.AddWidget(buttons)
+	return buttons
+}
+
+// Sets a function to be called when the dialog is closed.
+func (dialog *Dialog) SetOnClose(onClose func(result string)) {
+	dialog.onClose = onClose
+}
+
+// Closes the dialog with the given result.
+func (dialog *Dialog) Close(result string) {
+	if dialog.closed {
+		return
+	}
+	dialog.closed = true
+	dialog.result = result
+	dialog.appstate.Screen.HideCursor()
+	if dialog.onClose != nil {
+		dialog.onClose(result)
+	}
+}
+
+// Returns true once the dialog has been closed.
+func (dialog *Dialog) Closed() bool {
+	return dialog.closed
+}
+
+// Returns the label of the button that closed the dialog, or DIALOG_CANCEL.
+func (dialog *Dialog) Result() string {
+	return dialog.result
+}
+
+// Returns the currently focused widget, or nil if no widget can be focused.
+func (dialog *Dialog) Focused() Widget {
+	if dialog.focusIndex < 0 {
+		return nil
+	}
+	return dialog.widgets[dialog.focusIndex]
+}
+
+// Moves the focus to the next focusable widget in direction `step` (1 or -1), wrapping around.
+func (dialog *Dialog) cycleFocus(step int) {
+	if dialog.focusIndex < 0 {
+		return
+	}
+	count := len(dialog.widgets)
+	for i := 1; i <= count; i++ {
+		next := ((dialog.focusIndex + step*i) % count + count) % count
+		if dialog.widgets[next].CanFocus() {
+			dialog.focusIndex = next
+			return
+		}
+	}
+}
+
+// Returns the bounds of the dialog, centered on screen.
+func (dialog *Dialog) bounds() (x1, y1, x2, y2 int) {
+	scr_w, scr_h := dialog.appstate.Screen.Size()
+	width := dialog.width
+	if width > scr_w - 2 {
+		width = scr_w - 2
+	}
+
+	// Border and a blank row above and below the widgets
+	height := 4
+	for _, widget := range(dialog.widgets) {
+		height += widget.Height(width - 4)
+	}
+	if height > scr_h {
+		height = scr_h
+	}
+
+	x1, y1 = (scr_w - width) / 2, (scr_h - height) / 2
+	return x1, y1, x1 + width - 1, y1 + height - 1
+}
+
+func (dialog *Dialog) Draw() {
+	if dialog.hidden || dialog.closed {
+		return
+	}
+
+	appstate := dialog.appstate
+	x1, y1, x2, y2 := dialog.bounds()
+	innerW := x2 - x1 - 3
+
+	appstate.Screen.HideCursor()
+	drawBox(appstate.Screen, x1, y1, x2, y2, appstate.BarStyle, dialog.title)
+
+	row := y1 + 2
+	for i, widget := range(dialog.widgets) {
+		if row + widget.Height(innerW) > y2 - 1 {
+			break
+		}
+		widget.Draw(x1 + 2, row, innerW, i == dialog.focusIndex)
+		row += widget.Height(innerW)
+	}
+}
+
+func (dialog *Dialog) IsActive() bool {
+	return !dialog.closed
+}
+
+func (dialog *Dialog) Focus() {
+	return
+}
+
+func (dialog *Dialog) Unfocus() {
+	return
+}
+
+func (dialog *Dialog) GetCursorIndex() int {
+	return dialog.focusIndex
+}
+
+func (dialog *Dialog) SetCursorIndex(newCursorIndex int) {
+	if newCursorIndex >= 0 && newCursorIndex < len(dialog.widgets) && dialog.widgets[newCursorIndex].CanFocus() {
+		dialog.focusIndex = newCursorIndex
+	}
+}
+
+func (dialog *Dialog) IsHidden() bool {
+	return dialog.hidden
+}
+
+func (dialog *Dialog) Hide() {
+	dialog.hidden = true
+}
+
+func (dialog *Dialog) Show() {
+	dialog.hidden = false
+}
+
+func (dialog *Dialog) Redraw() {
+	return
+}
+
+func (dialog *Dialog) HandleKey(keyEvent *tcell.EventKey) {
+	if dialog.closed {
+		return
+	}
+
+	// Focused widget gets the first chance at the key
+	if focused := dialog.Focused(); focused != nil && focused.HandleKey(keyEvent) {
+		return
+	}
+
+	switch keyEvent.Key() {
+	case tcell.KeyTab, tcell.KeyDown:
+		dialog.cycleFocus(1)
+	case tcell.KeyBacktab, tcell.KeyUp:
+		dialog.cycleFocus(-1)
+	case tcell.KeyEscape:
+		dialog.Close(DIALOG_CANCEL)
+	case tcell.KeyEnter:
+		if dialog.buttons != nil {
+			dialog.buttons.Press(dialog.buttons.labels[0])
+		} else {
+			dialog.Close(DIALOG_OK)
+		}
+	}
+}
+
+/* COMMON DIALOGS */
+
+// Returns a dialog with a message and an editable text field pre-filled with `initial`, with OK and Cancel buttons.
+func NewInputDialog(appstate *util.AppState, title, message, initial string) (*Dialog, *TextInput) {
+	dialog := NewDialog(appstate, title)
+	input := NewTextInput(appstate, initial)
+	dialog.AddWidget(NewLabel(appstate, message))
+	dialog.AddWidget(input)
+	dialog.AddWidget(NewLabel(appstate, ""))
+	dialog.AddButtons(DIALOG_OK, "Cancel")
+	return dialog, input
+}
+
+// Returns a dialog with a message and a row of buttons, the first of which is the default.
+func NewMessageDialog(appstate *util.AppState, title, message string, buttons ...string) *Dialog {
+	if len(buttons) == 0 {
+		buttons = []string{DIALOG_OK}
+	}
+	dialog := NewDialog(appstate, title)
+	dialog.AddWidget(NewLabel(appstate, message))
+	dialog.AddWidget(NewLabel(appstate, ""))
+	dialog.AddButtons(buttons...)
+	return dialog
+}
+
+// Returns a dialog with a message and a list of items to choose from, with OK and Cancel buttons.
+func NewListDialog(appstate *util.AppState, title, message string, items []string, selected int) (*Dialog, *List) {
+	dialog := NewDialog(appstate, title)
+	list := NewList(appstate, items, 8)
+	list.SetCursorIndex(selected)
+	dialog.AddWidget(NewLabel(appstate, message))
+	dialog.AddWidget(list)
+	dialog.AddWidget(NewLabel(appstate, ""))
+	dialog.AddButtons(DIALOG_OK, "Cancel")
+	return dialog, list
+}
+
+/* DIALOG STACK */
+
+// Modal: Anything that can be opened on the dialog stack, taking all key events until it is closed, e.g. a Dialog or a Menu.
+type Modal interface {
+	Draw()
+	HandleKey(keyEvent *tcell.EventKey)
+	Close(result string) // Closes the modal with the given result
+	Closed() bool
+	Result() string
+}
+
+// DialogStack: The stack of open dialogs. Only the top-most dialog receives key events, but all of them are drawn, bottom-most first.
+type DialogStack struct {
+	dialogs []Modal
+}
+
+func NewDialogStack() *DialogStack {
+	return &DialogStack{make([]Modal, 0)}
+}
+
+// Opens `dialog` on top of the stack.
+func (stack *DialogStack) Push(dialog Modal) {
+	stack.dialogs = append(stack.dialogs, dialog)
+}
+
+// Returns the top-most dialog, or nil if there are none.
+func (stack *DialogStack) Top() Modal {
+	if len(stack.dialogs) == 0 {
+		return nil
+	}
+	return stack.dialogs[len(stack.dialogs) - 1]
+}
+
+func (stack *DialogStack) Len() int {
+	return len(stack.dialogs)
+}
+
+// Removes closed dialogs from the stack. Returns true if any were removed.
+func (stack *DialogStack) Prune() bool {
+	open := stack.dialogs[:0]
+	for _, dialog := range(stack.dialogs) {
+		if !dialog.Closed() {
+			open = append(open, dialog)
+		}
+	}
+	pruned := len(open) != len(stack.dialogs)
+	stack.dialogs = open
+	return pruned
+}
+
+// Draws every open dialog, bottom-most first.
+func (stack *DialogStack) Draw() {
+	for _, dialog := range(stack.dialogs) {
+		dialog.Draw()
+	}
+}
+
+// Hands the key event to the top-most dialog. Returns false if there are no dialogs.
+func (stack *DialogStack) HandleKey(keyEvent *tcell.EventKey) bool {
+	top := stack.Top()
+	if top == nil {
+		return false
+	}
+	top.HandleKey(keyEvent)
+	return true
+}
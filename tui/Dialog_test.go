@@ -0,0 +1,245 @@
+package tui
+
+import (
+	"strings"
+	"testing"
+	"github.com/gdamore/tcell/v2"
+	"github.com/Rye123/notepad--/util"
+)
+
+// Returns an appstate backed by an 80x24 simulation screen.
+func newTestAppState(t testing.TB) (*util.AppState, tcell.SimulationScreen) {
+	screen := tcell.NewSimulationScreen("")
+	if err := screen.Init(); err != nil {
+		t.Fatalf("%+v", err)
+	}
+	t.Cleanup(screen.Fini)
+	screen.SetSize(80, 24)
+
+	options := util.Options{LineEndMode: "LF", Encoding: "UTF-8", WordWrap: true}
+	return util.InitialiseAppState(screen, "", options), screen
+}
+
+// Injects `keys` into the screen, and hands the resulting events to the dialog stack.
+func injectKeys(screen tcell.SimulationScreen, stack *DialogStack, keys ...*tcell.EventKey) {
+	for _, key := range(keys) {
+		screen.InjectKey(key.Key(), key.Rune(), key.Modifiers())
+		if ev, ok := screen.PollEvent().(*tcell.EventKey); ok {
+			stack.HandleKey(ev)
+			stack.Prune()
+		}
+	}
+}
+
+// Returns the key events for typing `s`.
+func typed(s string) []*tcell.EventKey {
+	keys := make([]*tcell.EventKey, 0)
+	for _, r := range(s) {
+		keys = append(keys, tcell.NewEventKey(tcell.KeyRune, r, tcell.ModNone))
+	}
+	return keys
+}
+
+func key(k tcell.Key) *tcell.EventKey {
+	return tcell.NewEventKey(k, 0, tcell.ModNone)
+}
+
+// Returns the text shown on screen, one string per row.
+func screenRows(screen tcell.SimulationScreen) []string {
+	screen.Show()
+	cells, width, height := screen.GetContents()
+	rows := make([]string, height)
+	for y := 0; y < height; y++ {
+		var row strings.Builder
+		for x := 0; x < width; x++ {
+			if runes := cells[y*width + x].Runes; len(runes) > 0 {
+				row.WriteRune(runes[0])
+			} else {
+				row.WriteRune(' ')
+			}
+		}
+		rows[y] = row.String()
+	}
+	return rows
+}
+
+func screenContains(screen tcell.SimulationScreen, text string) bool {
+	return strings.Contains(strings.Join(screenRows(screen), "\n"), text)
+}
+
+func TestDialogTextInput(t *testing.T) {
+	appstate, screen := newTestAppState(t)
+	stack := NewDialogStack()
+	dialog, input := NewInputDialog(appstate, "Save As", "File name:", "note.txt")
+	stack.Push(dialog)
+
+	// Edit the pre-filled text
+	injectKeys(screen, stack, key(tcell.KeyBackspace2), key(tcell.KeyBackspace2), key(tcell.KeyBackspace2), key(tcell.KeyBackspace2))
+	injectKeys(screen, stack, typed(".md")...)
+	injectKeys(screen, stack, key(tcell.KeyHome), key(tcell.KeyDelete))
+	injectKeys(screen, stack, typed("m")...)
+	if input.Text() != "mote.md" {
+		t.Fatalf("Expected \"mote.md\", instead input.Text(): " + input.Text())
+	}
+
+	// Check that the dialog is drawn, with the cursor in the text field
+	stack.Draw()
+	if !screenContains(screen, "Save As") || !screenContains(screen, "mote.md") {
+		t.Fatalf("Expected dialog on screen, instead:\n" + strings.Join(screenRows(screen), "\n"))
+	}
+	_, _, visible := screen.GetCursor()
+	if !visible {
+		t.Fatalf("Expected cursor to be visible in text field")
+	}
+
+	// Enter in the text field presses the default button
+	injectKeys(screen, stack, key(tcell.KeyEnter))
+	if !dialog.Closed() || dialog.Result() != DIALOG_OK {
+		t.Fatalf("Expected dialog closed with OK, instead result: \"" + dialog.Result() + "\"")
+	}
+	if stack.Len() != 0 {
+		t.Fatalf("Expected closed dialog to be removed from the stack")
+	}
+}
+
+func TestDialogCancel(t *testing.T) {
+	appstate, screen := newTestAppState(t)
+	stack := NewDialogStack()
+	dialog, _ := NewInputDialog(appstate, "Save As", "File name:", "")
+	stack.Push(dialog)
+
+	injectKeys(screen, stack, key(tcell.KeyEscape))
+	if !dialog.Closed() || dialog.Result() != DIALOG_CANCEL {
+		t.Fatalf("Expected dialog cancelled, instead result: \"" + dialog.Result() + "\"")
+	}
+}
+
+func TestDialogButtons(t *testing.T) {
+	appstate, screen := newTestAppState(t)
+	stack := NewDialogStack()
+
+	// Arrow keys select, Enter presses
+	dialog := NewMessageDialog(appstate, "Notepad--", "Save changes?", "Save", "Don't Save", "Cancel")
+	stack.Push(dialog)
+	injectKeys(screen, stack, key(tcell.KeyRight), key(tcell.KeyRight), key(tcell.KeyLeft), key(tcell.KeyEnter))
+	if dialog.Result() != "Don't Save" {
+		t.Fatalf("Expected \"Don't Save\", instead result: \"" + dialog.Result() + "\"")
+	}
+
+	// Hotkeys press the button directly
+	dialog = NewMessageDialog(appstate, "Notepad--", "Save changes?", "Save", "Don't Save", "Cancel")
+	stack.Push(dialog)
+	injectKeys(screen, stack, typed("c")...)
+	if dialog.Result() != "Cancel" {
+		t.Fatalf("Expected \"Cancel\", instead result: \"" + dialog.Result() + "\"")
+	}
+}
+
+func TestDialogFocusAndCheckbox(t *testing.T) {
+	appstate, screen := newTestAppState(t)
+	stack := NewDialogStack()
+	dialog := NewDialog(appstate, "Find")
+	input := NewTextInput(appstate, "")
+	checkbox := NewCheckbox(appstate, "Match case", false)
+	dialog.AddWidget(input)
+	dialog.AddWidget(checkbox)
+	dialog.AddButtons("Find Next", "Cancel")
+	stack.Push(dialog)
+
+	if dialog.Focused() != input {
+		t.Fatalf("Expected first focusable widget to be focused")
+	}
+
+	// Space is typed into the text field, but toggles the checkbox
+	injectKeys(screen, stack, typed("a b")...)
+	injectKeys(screen, stack, key(tcell.KeyTab))
+	injectKeys(screen, stack, typed(" ")...)
+	if input.Text() != "a b" {
+		t.Fatalf("Expected \"a b\", instead input.Text(): " + input.Text())
+	}
+	if !checkbox.Checked() {
+		t.Fatalf("Expected checkbox to be checked")
+	}
+	stack.Draw()
+	if !screenContains(screen, "[x] Match case") {
+		t.Fatalf("Expected checked checkbox on screen, instead:\n" + strings.Join(screenRows(screen), "\n"))
+	}
+
+	// Focus wraps around in both directions
+	injectKeys(screen, stack, key(tcell.KeyTab), key(tcell.KeyTab))
+	if dialog.Focused() != input {
+		t.Fatalf("Expected focus to wrap around to the text field")
+	}
+	injectKeys(screen, stack, key(tcell.KeyBacktab))
+	if _, ok := dialog.Focused().(*Buttons); !ok {
+		t.Fatalf("Expected focus to wrap around to the buttons")
+	}
+}
+
+func TestDialogList(t *testing.T) {
+	appstate, screen := newTestAppState(t)
+	stack := NewDialogStack()
+	items := []string{"a", "b", "c", "d", "e", "f", "g", "h", "i", "j"}
+	dialog, list := NewListDialog(appstate, "Choose", "Item:", items, 1)
+	stack.Push(dialog)
+
+	injectKeys(screen, stack, key(tcell.KeyDown), key(tcell.KeyDown))
+	if list.Selected() != "d" {
+		t.Fatalf("Expected \"d\", instead list.Selected(): " + list.Selected())
+	}
+	injectKeys(screen, stack, key(tcell.KeyEnd))
+	if list.Selected() != "j" {
+		t.Fatalf("Expected \"j\", instead list.Selected(): " + list.Selected())
+	}
+
+	// List scrolls to show the selected item
+	stack.Draw()
+	rows := screenRows(screen)
+	found := false
+	for _, row := range(rows) {
+		if strings.Contains(row, " j ") {
+			found = true
+		}
+		if strings.Contains(row, " a ") {
+			t.Fatalf("Expected first item to be scrolled out of view")
+		}
+	}
+	if !found {
+		t.Fatalf("Expected selected item on screen, instead:\n" + strings.Join(rows, "\n"))
+	}
+
+	injectKeys(screen, stack, key(tcell.KeyEnter))
+	if dialog.Result() != DIALOG_OK {
+		t.Fatalf("Expected OK, instead result: \"" + dialog.Result() + "\"")
+	}
+}
+
+func TestDialogStack(t *testing.T) {
+	appstate, screen := newTestAppState(t)
+	stack := NewDialogStack()
+	bottom, bottomInput := NewInputDialog(appstate, "Bottom", "", "")
+	top, topInput := NewInputDialog(appstate, "Top", "", "")
+	stack.Push(bottom)
+	stack.Push(top)
+
+	// Only the top-most dialog receives keys
+	injectKeys(screen, stack, typed("x")...)
+	if topInput.Text() != "x" || bottomInput.Text() != "" {
+		t.Fatalf("Expected key to go to the top dialog only")
+	}
+
+	// Closing the top dialog hands focus back to the one below
+	injectKeys(screen, stack, key(tcell.KeyEscape))
+	if stack.Top() != bottom {
+		t.Fatalf("Expected bottom dialog to be on top")
+	}
+	injectKeys(screen, stack, typed("y")...)
+	if bottomInput.Text() != "y" {
+		t.Fatalf("Expected \"y\", instead bottomInput.Text(): " + bottomInput.Text())
+	}
+
+	injectKeys(screen, stack, key(tcell.KeyEscape))
+	if stack.Len() != 0 || stack.HandleKey(key(tcell.KeyEnter)) {
+		t.Fatalf("Expected empty stack to ignore keys")
+	}
+}
@@ -0,0 +1,297 @@
+package tui
+
+import (
+	"unicode"
+	"github.com/gdamore/tcell/v2"
+	"github.com/Rye123/notepad--/search"
+	"github.com/Rye123/notepad--/util"
+)
+
+//  Find:    [query               ] [x] Case (Alt+C)  [ ] Word (Alt+W)  [ ] Regex (Alt+R)
+//  Replace: [replacement         ] Enter: Replace  Alt+A: Replace All
+// FindBar: A bar below the textbox for searching it as you type, and optionally replacing the matches. Hidden until opened.
+type FindBar struct {
+	hidden bool
+	active bool
+	replacing bool // True if the replace row is shown
+	replaceFocused bool // True if keys go to the replacement rather than the query
+	input *TextInput
+	replaceInput *TextInput
+	matchCase *Checkbox
+	wholeWord *Checkbox
+	regex *Checkbox
+	onChange func(query string, options search.Options) // Called whenever the query or options change
+	onSubmit func() // Called when Enter is pressed in the query
+	onReplace func() // Called when Enter is pressed in the replacement
+	onReplaceAll func() // Called when Alt+A is pressed while replacing
+	drawn bool
+	appstate *util.AppState
+}
+
+const FINDBAR_LABEL = " Find:    "
+const REPLACEBAR_LABEL = " Replace: "
+const REPLACEBAR_HINT = "Enter: Replace  Alt+A: Replace All"
+
+func NewFindBar(appstate *util.AppState) *FindBar {
+	elem := &FindBar{
+		true,
+		false,
+		false,
+		false,
+		NewTextInput(appstate, ""),
+		NewTextInput(appstate, ""),
+		NewCheckbox(appstate, "Case (Alt+C)", false),
+		NewCheckbox(appstate, "Word (Alt+W)", false),
+		NewCheckbox(appstate, "Regex (Alt+R)", false),
+		nil,
+		nil,
+		nil,
+		nil,
+		false,
+		appstate,
+	}
+	changed := func() {
+		elem.drawn = false
+		if elem.onChange != nil {
+			elem.onChange(elem.Query(), elem.Options())
+		}
+	}
+	elem.input.SetOnChange(func(string) { changed() })
+	elem.matchCase.SetOnChange(func(bool) { changed() })
+	elem.wholeWord.SetOnChange(func(bool) { changed() })
+	elem.regex.SetOnChange(func(bool) { changed() })
+	return elem
+}
+
+// Sets the function called whenever the query or options change.
+func (elem *FindBar) SetOnChange(onChange func(query string, options search.Options)) {
+	elem.onChange = onChange
+}
+
+// Sets the function called when Enter is pressed in the query.
+func (elem *FindBar) SetOnSubmit(onSubmit func()) {
+	elem.onSubmit = onSubmit
+}
+
+// Sets the functions called to replace the current match, and to replace all the matches.
+func (elem *FindBar) SetOnReplace(onReplace func(), onReplaceAll func()) {
+	elem.onReplace = onReplace
+	elem.onReplaceAll = onReplaceAll
+}
+
+func (elem *FindBar) Query() string {
+	return elem.input.Text()
+}
+
+// Replaces the query, without calling the change function.
+func (elem *FindBar) SetQuery(query string) {
+	elem.input.SetText(query)
+	elem.drawn = false
+}
+
+func (elem *FindBar) Replacement() string {
+	return elem.replaceInput.Text()
+}
+
+func (elem *FindBar) Options() search.Options {
+	return search.Options{MatchCase: elem.matchCase.Checked(), WholeWord: elem.wholeWord.Checked(), Regex: elem.regex.Checked()}
+}
+
+func (elem *FindBar) IsReplacing() bool {
+	return elem.replacing
+}
+
+// Shows or hides the replace row. Keys go to the replacement while it is shown if `focusReplacement` is true, otherwise to the query.
+func (elem *FindBar) SetReplacing(replacing bool, focusReplacement bool) {
+	elem.replacing = replacing
+	elem.replaceFocused = replacing && focusReplacement
+	elem.drawn = false
+}
+
+// Returns the number of rows the bar takes up.
+func (elem *FindBar) Rows() int {
+	if elem.replacing {
+		return 2
+	}
+	return 1
+}
+
+// Returns the last row the bar is drawn on: the last row of the screen, or above the status bar if it is shown.
+func (elem *FindBar) row() int {
+	_, scr_h := elem.appstate.Screen.Size()
+	if elem.appstate.Options.HideStatusBar {
+		return scr_h - 1
+	}
+	return scr_h - 3
+}
+
+func (elem *FindBar) Draw() {
+	if elem.hidden {
+		return
+	}
+
+	// Don't update if not active and already drawn
+	if !elem.active && elem.drawn {
+		return
+	}
+
+	appstate := elem.appstate
+	scr_w, _ := appstate.Screen.Size()
+	row := elem.row() - elem.Rows() + 1
+	drawText(appstate.Screen, 0, row, scr_w, row, appstate.BarStyle, fitString(FINDBAR_LABEL, scr_w))
+
+	// The options are left out if they would leave too little room for the query
+	x := len(FINDBAR_LABEL)
+	options := []*Checkbox{elem.matchCase, elem.wholeWord, elem.regex}
+	optionsWidth := 0
+	for _, option := range(options) {
+		optionsWidth += 2 + len([]rune(option.label)) + 4
+	}
+	if scr_w - x - optionsWidth < 10 {
+		optionsWidth = 0
+	}
+	inputWidth := scr_w - x - optionsWidth - 1
+	if inputWidth < 1 {
+		inputWidth = 1
+	}
+	elem.input.Draw(x, row, inputWidth, elem.active && !elem.replaceFocused)
+	if optionsWidth > 0 {
+		x += inputWidth
+		for _, option := range(options) {
+			width := len([]rune(option.label)) + 4
+			option.Draw(x + 2, row, width, false)
+			x += 2 + width
+		}
+	}
+
+	if elem.replacing {
+		row++
+		drawText(appstate.Screen, 0, row, scr_w, row, appstate.BarStyle, fitString(REPLACEBAR_LABEL, scr_w))
+		x = len(REPLACEBAR_LABEL)
+		hintWidth := 2 + len(REPLACEBAR_HINT)
+		if scr_w - x - hintWidth < 10 {
+			hintWidth = 0
+		}
+		inputWidth = scr_w - x - hintWidth - 1
+		if inputWidth < 1 {
+			inputWidth = 1
+		}
+		elem.replaceInput.Draw(x, row, inputWidth, elem.active && elem.replaceFocused)
+		if hintWidth > 0 {
+			x += inputWidth + 2
+			drawText(appstate.Screen, x, row, x + len(REPLACEBAR_HINT), row, appstate.BarStyle, REPLACEBAR_HINT)
+		}
+	}
+
+	elem.drawn = true
+}
+
+func (elem *FindBar) IsActive() bool {
+	return elem.active
+}
+
+func (elem *FindBar) Focus() {
+	elem.active = true
+}
+
+func (elem *FindBar) Unfocus() {
+	elem.active = false
+	elem.drawn = false // update it one last time to hide the cursor
+}
+
+// Returns the cursor index of the focused input: the query, or the replacement.
+func (elem *FindBar) GetCursorIndex() int {
+	return elem.focusedInput().GetCursorIndex()
+}
+
+func (elem *FindBar) SetCursorIndex(newCursorIndex int) {
+	elem.focusedInput().SetCursorIndex(newCursorIndex)
+}
+
+func (elem *FindBar) focusedInput() *TextInput {
+	if elem.replaceFocused {
+		return elem.replaceInput
+	}
+	return elem.input
+}
+
+func (elem *FindBar) IsHidden() bool {
+	return elem.hidden
+}
+
+func (elem *FindBar) Hide() {
+	elem.hidden = true
+	elem.active = false
+	elem.drawn = false
+}
+
+func (elem *FindBar) Show() {
+	elem.hidden = false
+	elem.drawn = false
+}
+
+func (elem *FindBar) Redraw() {
+	elem.drawn = false
+}
+
+// Returns true if the bar handles `keyEvent` while it is focused: typing and editing the query or replacement, Enter, Tab between them, and the option toggles.
+// Other keys, e.g. F3 and Esc, are left to the key bindings.
+func (elem *FindBar) TakesKey(keyEvent *tcell.EventKey) bool {
+	mod := keyEvent.Modifiers()
+	switch keyEvent.Key() {
+	case tcell.KeyRune:
+		if mod & tcell.ModAlt != 0 {
+			switch keyEvent.Rune() {
+			case 'c', 'C', 'w', 'W', 'r', 'R':
+				return true
+			case 'a', 'A':
+				return elem.replacing
+			}
+			return false
+		}
+		return mod & tcell.ModCtrl == 0
+	case tcell.KeyTab:
+		return elem.replacing && mod & (tcell.ModCtrl | tcell.ModAlt) == 0
+	case tcell.KeyLeft, tcell.KeyRight, tcell.KeyHome, tcell.KeyEnd, tcell.KeyBackspace, tcell.KeyBackspace2, tcell.KeyDelete, tcell.KeyEnter:
+		return mod & (tcell.ModCtrl | tcell.ModAlt) == 0
+	}
+	return false
+}
+
+func (elem *FindBar) HandleKey(keyEvent *tcell.EventKey) {
+	if !elem.active || !elem.TakesKey(keyEvent) {
+		return
+	}
+	elem.drawn = false
+
+	switch {
+	case keyEvent.Key() == tcell.KeyTab:
+		elem.replaceFocused = !elem.replaceFocused
+	case keyEvent.Key() == tcell.KeyEnter && elem.replaceFocused:
+		if elem.onReplace != nil {
+			elem.onReplace()
+		}
+	case keyEvent.Key() == tcell.KeyEnter:
+		if elem.onSubmit != nil {
+			elem.onSubmit()
+		}
+	case keyEvent.Modifiers() & tcell.ModAlt != 0:
+		var checkbox *Checkbox
+		switch unicode.ToLower(keyEvent.Rune()) {
+		case 'a':
+			if elem.onReplaceAll != nil {
+				elem.onReplaceAll()
+			}
+			return
+		case 'c':
+			checkbox = elem.matchCase
+		case 'w':
+			checkbox = elem.wholeWord
+		case 'r':
+			checkbox = elem.regex
+		}
+		checkbox.SetChecked(!checkbox.Checked())
+	default:
+		elem.focusedInput().HandleKey(keyEvent)
+	}
+}
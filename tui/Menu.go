@@ -0,0 +1,180 @@
+package tui
+
+import (
+	"strings"
+	"unicode"
+	"github.com/gdamore/tcell/v2"
+	"github.com/Rye123/notepad--/util"
+)
+
+// Menu results, other than the label of the chosen item
+const MENU_LEFT = "<" // Left was pressed, to open the menu to the left
+const MENU_RIGHT = ">" // Right was pressed, to open the menu to the right
+
+// An entry in a drop-down menu.
+type MenuItem struct {
+	Label string
+	Hotkey rune // Letter that chooses this item, underlined in the label
+	Shortcut string // Hint for the key that performs the action outside the menu, e.g. "Ctrl+S"
+	Disabled bool // Disabled items are greyed out, and can't be chosen
+	Checked bool // Checked items have a tick beside them, e.g. for options that are turned on
+	Action func()
+}
+
+// Menu: A drop-down list of actions, opened below a menu bar button.
+// Up and Down move between the items, Enter or an item's hotkey chooses it, and Esc closes the menu. Left and Right close the menu so that the neighbouring menu can be opened.
+type Menu struct {
+	x int
+	y int
+	items []MenuItem
+	cursorIndex int
+	closed bool
+	result string // Label of the chosen item, MENU_LEFT, MENU_RIGHT or DIALOG_CANCEL
+	appstate *util.AppState
+}
+
+// Returns a menu of `items` with its top-left corner at (x, y).
+func NewMenu(appstate *util.AppState, x, y int, items []MenuItem) *Menu {
+	return &Menu{x, y, items, 0, false, DIALOG_CANCEL, appstate}
+}
+
+func (menu *Menu) Close(result string) {
+	menu.closed = true
+	menu.result = result
+}
+
+func (menu *Menu) Closed() bool {
+	return menu.closed
+}
+
+// Returns the label of the chosen item, MENU_LEFT, MENU_RIGHT or DIALOG_CANCEL.
+func (menu *Menu) Result() string {
+	return menu.result
+}
+
+// Returns the item under the cursor.
+func (menu *Menu) Selected() MenuItem {
+	return menu.items[menu.cursorIndex]
+}
+
+func (menu *Menu) GetCursorIndex() int {
+	return menu.cursorIndex
+}
+
+func (menu *Menu) SetCursorIndex(newCursorIndex int) {
+	// Wrap around at either end
+	count := len(menu.items)
+	menu.cursorIndex = (newCursorIndex % count + count) % count
+}
+
+// Chooses the item at `index`, closing the menu. Disabled items can't be chosen.
+func (menu *Menu) choose(index int) {
+	if menu.items[index].Disabled {
+		return
+	}
+	menu.cursorIndex = index
+	menu.Close(menu.items[index].Label)
+}
+
+// Returns the width of the label and shortcut columns.
+func (menu *Menu) columnWidths() (labelWidth, shortcutWidth int) {
+	for _, item := range(menu.items) {
+		if n := len([]rune(item.Label)); n > labelWidth {
+			labelWidth = n
+		}
+		if n := len([]rune(item.Shortcut)); n > shortcutWidth {
+			shortcutWidth = n
+		}
+	}
+	return labelWidth, shortcutWidth
+}
+
+func (menu *Menu) Draw() {
+	if menu.closed {
+		return
+	}
+
+	appstate := menu.appstate
+	labelWidth, shortcutWidth := menu.columnWidths()
+	// Padding, tick, label, gap, shortcut, padding
+	innerW := 1 + 2 + labelWidth + 1
+	if shortcutWidth > 0 {
+		innerW += 3 + shortcutWidth
+	}
+	x1, y1 := menu.x, menu.y
+	x2, y2 := x1 + innerW + 1, y1 + len(menu.items) + 1
+
+	appstate.Screen.HideCursor()
+	drawBox(appstate.Screen, x1, y1, x2, y2, appstate.BarStyle, "")
+
+	for i, item := range(menu.items) {
+		row := y1 + 1 + i
+		style := appstate.ButtonStyle
+		if item.Disabled {
+			style = appstate.DisabledStyle
+		}
+		if i == menu.cursorIndex {
+			style = style.Reverse(true)
+		}
+
+		check := "  "
+		if item.Checked {
+			check = "✓ "
+		}
+		text := " " + check + fitString(item.Label, labelWidth)
+		if shortcutWidth > 0 {
+			text += "   " + strings.Repeat(" ", shortcutWidth - len([]rune(item.Shortcut))) + item.Shortcut
+		}
+		text += " "
+		drawText(appstate.Screen, x1 + 1, row, x2, row, style, text)
+
+		// Underline the hotkey
+		if hotkeyIndex := menuHotkeyIndex(item); hotkeyIndex >= 0 {
+			ch := []rune(item.Label)[hotkeyIndex]
+			appstate.Screen.SetContent(x1 + 4 + hotkeyIndex, row, ch, nil, style.Underline(true))
+		}
+	}
+}
+
+func (menu *Menu) HandleKey(keyEvent *tcell.EventKey) {
+	if menu.closed {
+		return
+	}
+
+	switch keyEvent.Key() {
+	case tcell.KeyUp:
+		menu.SetCursorIndex(menu.cursorIndex - 1)
+	case tcell.KeyDown:
+		menu.SetCursorIndex(menu.cursorIndex + 1)
+	case tcell.KeyHome:
+		menu.SetCursorIndex(0)
+	case tcell.KeyEnd:
+		menu.SetCursorIndex(len(menu.items) - 1)
+	case tcell.KeyLeft:
+		menu.Close(MENU_LEFT)
+	case tcell.KeyRight:
+		menu.Close(MENU_RIGHT)
+	case tcell.KeyEscape:
+		menu.Close(DIALOG_CANCEL)
+	case tcell.KeyEnter:
+		menu.choose(menu.cursorIndex)
+	case tcell.KeyRune:
+		ch := unicode.ToLower(keyEvent.Rune())
+		for i, item := range(menu.items) {
+			if unicode.ToLower(item.Hotkey) == ch {
+				menu.choose(i)
+				return
+			}
+		}
+	}
+}
+
+// Returns the index in the label of the item's hotkey, or -1 if the label doesn't contain it.
+func menuHotkeyIndex(item MenuItem) int {
+	for i, ch := range([]rune(item.Label)) {
+		if unicode.ToLower(ch) == unicode.ToLower(item.Hotkey) {
+			return i
+		}
+	}
+	return -1
+}
@@ -1,6 +1,7 @@
 package tui
 
 import (
+	"unicode"
 	"github.com/gdamore/tcell/v2"
 	"github.com/Rye123/notepad--/util"
 )
@@ -11,17 +12,49 @@ const MENUBAR_ENDROW = 3
 // File | Edit | Format | View | Help
 const MENU_BUTTON_COUNT = 5
 
+// Indices of the menu bar buttons
+const (
+	MENU_FILE = iota
+	MENU_EDIT
+	MENU_FORMAT
+	MENU_VIEW
+	MENU_HELP
+)
+
 // MenuBar: A bar that shows Alt-functions (e.g. File, Edit, etc)
 type MenuBar struct {
 	hidden bool
 	active bool
 	cursorIndex int
 	drawn bool
+	onOpen func(menuIndex int) // Called to open the drop-down menu of a button
 	appstate *util.AppState
 }
 
 func NewMenuBar(appstate *util.AppState) *MenuBar {
-	return &MenuBar{false, false, 0, false, appstate}
+	return &MenuBar{false, false, 0, false, nil, appstate}
+}
+
+// Sets the function called to open the drop-down menu of the button at `menuIndex`, when it is chosen with Enter, Up, Down or its hotkey.
+func (elem *MenuBar) SetOnOpen(onOpen func(menuIndex int)) {
+	elem.onOpen = onOpen
+}
+
+// Returns the buttons of the menu bar, in order.
+func (elem *MenuBar) buttons() [MENU_BUTTON_COUNT]menuButton {
+	appstate := elem.appstate
+	return [MENU_BUTTON_COUNT]menuButton{
+		{false, 0, "File", 0, appstate},
+		{false, 6, "Edit", 0, appstate},
+		{false, 12, "Format", 1, appstate},
+		{false, 20, "View", 0, appstate},
+		{false, 26, "Help", 0, appstate},
+	}
+}
+
+// Returns the x-coordinate of the button at `menuIndex`, where its drop-down menu is opened.
+func (elem *MenuBar) ButtonX(menuIndex int) int {
+	return elem.buttons()[menuIndex].x
 }
 
 func (elem *MenuBar) Draw() {
@@ -38,14 +71,7 @@ func (elem *MenuBar) Draw() {
 	scr_w, scr_h := appstate.Screen.Size()
 	scr_w--; scr_h--
 
-	// Define Buttons
-	buttons := [MENU_BUTTON_COUNT]menuButton{
-		{false, 0, "File", 0, appstate},
-		{false, 6, "Edit", 0, appstate},
-		{false, 12, "Format", 1, appstate},
-		{false, 20, "View", 0, appstate},
-		{false, 26, "Help", 0, appstate},
-	}
+	buttons := elem.buttons()
 
 	// Set Active Button
 	if elem.active {
@@ -106,35 +132,43 @@ func (elem *MenuBar) Show() {
 	elem.hidden = false
 }
 
+func (elem *MenuBar) Redraw() {
+	elem.drawn = false
+}
+
 func (elem *MenuBar) HandleKey(keyEvent *tcell.EventKey) {
 	if !elem.IsActive() {
 		return
 	}
 	key, ch := keyEvent.Key(), keyEvent.Rune()
 	
-	// Arrow Keys: Move cursor index
-	if key == tcell.KeyLeft {
+	// Arrow Keys: Move cursor index, or open the menu of the current button
+	switch key {
+	case tcell.KeyLeft:
 		elem.SetCursorIndex(elem.GetCursorIndex() - 1)
-	} else if key == tcell.KeyRight {
+	case tcell.KeyRight:
 		elem.SetCursorIndex(elem.GetCursorIndex() + 1)
-	} else if key == tcell.KeyUp {
-		//TODO: Navigate internal menu of current active button
-	} else if key == tcell.KeyDown {
-		//TODO: Navigate internal menu of current active button
+	case tcell.KeyUp, tcell.KeyDown, tcell.KeyEnter:
+		elem.open()
+	}
+	if key != tcell.KeyRune {
+		return
 	}
 
-	// Handles Runes
-	switch ch {
-	case 'f', 'F':
-		elem.SetCursorIndex(0) // File
-	case 'e', 'E':
-		elem.SetCursorIndex(1) // Edit
-	case 'o', 'O':
-		elem.SetCursorIndex(2) // Format
-	case 'v', 'V':
-		elem.SetCursorIndex(3) // View
-	case 'h', 'H':
-		elem.SetCursorIndex(4) // Help
+	// Handles Runes: Hotkeys open the menu of their button
+	for i, button := range(elem.buttons()) {
+		if unicode.ToLower(ch) == unicode.ToLower(rune(button.text[button.hotkeyIndex])) {
+			elem.SetCursorIndex(i)
+			elem.open()
+			return
+		}
+	}
+}
+
+// Opens the drop-down menu of the current button.
+func (elem *MenuBar) open() {
+	if elem.onOpen != nil {
+		elem.onOpen(elem.cursorIndex)
 	}
 }
 
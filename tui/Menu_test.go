@@ -0,0 +1,100 @@
+package tui
+
+import (
+	"testing"
+	"github.com/gdamore/tcell/v2"
+)
+
+func newTestMenu(t *testing.T) (*Menu, tcell.SimulationScreen, *[]string) {
+	appstate, screen := newTestAppState(t)
+	chosen := make([]string, 0)
+	choose := func(label string) func() {
+		return func() { chosen = append(chosen, label) }
+	}
+	menu := NewMenu(appstate, 0, MENUBAR_ENDROW, []MenuItem{
+		{Label: "Undo", Hotkey: 'u', Shortcut: "Ctrl+Z", Disabled: true, Action: choose("Undo")},
+		{Label: "Cut", Hotkey: 't', Shortcut: "Ctrl+X", Action: choose("Cut")},
+		{Label: "Word Wrap", Hotkey: 'w', Checked: true, Action: choose("Word Wrap")},
+	})
+	return menu, screen, &chosen
+}
+
+func TestMenuNavigation(t *testing.T) {
+	menu, screen, _ := newTestMenu(t)
+	stack := NewDialogStack()
+	stack.Push(menu)
+
+	// Wraps around at either end
+	injectKeys(screen, stack, key(tcell.KeyUp))
+	if menu.GetCursorIndex() != 2 {
+		t.Fatalf("Expected cursor to wrap to the last item, instead cursor index: %d", menu.GetCursorIndex())
+	}
+	injectKeys(screen, stack, key(tcell.KeyDown))
+	if menu.GetCursorIndex() != 0 {
+		t.Fatalf("Expected cursor to wrap to the first item, instead cursor index: %d", menu.GetCursorIndex())
+	}
+
+	// Disabled items can't be chosen
+	injectKeys(screen, stack, key(tcell.KeyEnter))
+	injectKeys(screen, stack, typed("u")...)
+	if menu.Closed() {
+		t.Fatalf("Expected disabled item not to close the menu")
+	}
+
+	// Enter chooses the item under the cursor
+	injectKeys(screen, stack, key(tcell.KeyDown), key(tcell.KeyEnter))
+	if !menu.Closed() || menu.Result() != "Cut" || menu.Selected().Label != "Cut" {
+		t.Fatalf("Expected \"Cut\" to be chosen, instead result: \"%v\"", menu.Result())
+	}
+}
+
+func TestMenuHotkeysAndNeighbours(t *testing.T) {
+	menu, screen, chosen := newTestMenu(t)
+	stack := NewDialogStack()
+	stack.Push(menu)
+	injectKeys(screen, stack, typed("W")...)
+	if menu.Result() != "Word Wrap" {
+		t.Fatalf("Expected hotkey to choose \"Word Wrap\", instead result: \"%v\"", menu.Result())
+	}
+	menu.Selected().Action()
+	if len(*chosen) != 1 || (*chosen)[0] != "Word Wrap" {
+		t.Fatalf("Expected the action of \"Word Wrap\" to run, instead: %v", *chosen)
+	}
+
+	for k, expected := range(map[tcell.Key]string{tcell.KeyLeft: MENU_LEFT, tcell.KeyRight: MENU_RIGHT, tcell.KeyEscape: DIALOG_CANCEL}) {
+		menu, screen, _ := newTestMenu(t)
+		stack := NewDialogStack()
+		stack.Push(menu)
+		injectKeys(screen, stack, key(k))
+		if !menu.Closed() || menu.Result() != expected {
+			t.Fatalf("Expected result \"%v\", instead \"%v\"", expected, menu.Result())
+		}
+	}
+}
+
+func TestMenuDraw(t *testing.T) {
+	menu, screen, _ := newTestMenu(t)
+	menu.Draw()
+	screen.Show()
+
+	rows := screenRows(screen)
+	expected := []string{
+		"┌──────────────────────┐",
+		"│   Undo        Ctrl+Z │",
+		"│   Cut         Ctrl+X │",
+		"│ ✓ Word Wrap          │",
+		"└──────────────────────┘",
+	}
+	for i, row := range(expected) {
+		if got := rows[MENUBAR_ENDROW + i][:len(row)]; got != row {
+			t.Fatalf("Expected row %d to be %q, instead %q", i, row, got)
+		}
+	}
+
+	// Disabled items are greyed out
+	_, _, style, _ := screen.GetContent(4, MENUBAR_ENDROW + 1)
+	fg, _, _ := style.Decompose()
+	if fg != tcell.ColorGray {
+		t.Fatalf("Expected disabled item to be grey")
+	}
+}
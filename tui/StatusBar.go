@@ -12,16 +12,27 @@ import (
 type StatusBar struct {
 	hidden bool
 	textbox *Textbox
+	message string // Shown after the cursor position, e.g. the number of search matches
 	drawn bool
 	appstate *util.AppState
 }
 
 func NewStatusBar(appstate *util.AppState, textbox *Textbox) *StatusBar {
-	return &StatusBar{false, textbox, false, appstate}
+	return &StatusBar{false, textbox, "", false, appstate}
+}
+
+// Sets the message shown after the cursor position. "" removes it.
+func (elem *StatusBar) SetMessage(message string) {
+	elem.message = message
+	elem.drawn = false
+}
+
+func (elem *StatusBar) Message() string {
+	return elem.message
 }
 
 func (elem *StatusBar) Draw() {
-	if elem.hidden {
+	if elem.hidden || elem.appstate.Options.HideStatusBar {
 		return
 	}
 
@@ -40,7 +51,17 @@ func (elem *StatusBar) Draw() {
 	cursorX, cursorY := elem.textbox.GetCursorXY()
 	//TODO: Remove debugging cursorIndex
 	cursorText := fmt.Sprintf("Ln %d, Col %d (%d)", cursorY+1, cursorX+1, elem.textbox.cursorIndex)
-	otherText := fmt.Sprintf("| 100%% | %v | %v ", appstate.Options.LineEndModeString(), appstate.Options.Encoding)
+	if elem.message != "" {
+		cursorText += " | " + elem.message
+	}
+	if pending := appstate.Keymap.Pending(); pending != "" {
+		cursorText = "(" + pending + ") was pressed, waiting for the next key..."
+	}
+	lineEndText := appstate.Options.LineEndModeString()
+	if appstate.MixedLineEnds {
+		lineEndText = "Mixed (" + appstate.Options.LineEndMode + ")"
+	}
+	otherText := fmt.Sprintf("| 100%% | %v | %v ", lineEndText, appstate.Options.Encoding)
 
 	// Generate full string
 	spaceBetween := scr_w - len(otherText) - len(cursorText)
@@ -88,6 +109,10 @@ func (elem *StatusBar) Show() {
 	elem.hidden = false
 }
 
+func (elem *StatusBar) Redraw() {
+	elem.drawn = false
+}
+
 func (elem *StatusBar) HandleKey(keyEvent *tcell.EventKey) {
 	return
 }
@@ -1,11 +1,13 @@
 package tui
 
 import (
-	"fmt"
-	"strings"
+	"sort"
+	"unicode/utf8"
 	"github.com/gdamore/tcell/v2"
 	"github.com/Rye123/notepad--/util"
 	"github.com/Rye123/notepad--/textbuffer"
+	"github.com/Rye123/notepad--/command"
+	"github.com/Rye123/notepad--/search"
 )
 
 const TEXTBOX_STARTROW = 4
@@ -17,6 +19,12 @@ type Textbox struct {
 	cursorX int
 	cursorY int
 	leftIndex int // x-coordinate of leftmost index, to allow horizontal scrolling for non-wordwrapped text
+	topLine int // Index of the topmost visible line, to allow vertical scrolling
+	topSubRow int // Index of the topmost visible row within topLine, if word wrap is enabled
+	stickyX int // x-coordinate the cursor returns to when moving up and down through shorter lines
+	selectionAnchor int // Index where the selection started, or -1 if nothing is selected. The selection spans from here to the cursor.
+	highlights []search.Match // Ranges highlighted in the view, e.g. search matches, in order
+	bottomRows int // Rows at the bottom of the view covered by other elements, e.g. the find bar
 	buf textbuffer.TextBuffer
 	drawn bool // True if element has been drawn already
 	appstate *util.AppState
@@ -29,12 +37,18 @@ func NewTextbox(appstate *util.AppState) *Textbox {
 		0,
 		0, 0,
 		0,
+		0, 0,
+		0,
+		-1,
+		nil,
+		0,
 		appstate.TextBuffer,
 		false,
 		appstate,
 	}
 
 	textbox.SetCursorIndex(appstate.TextBuffer.Length())
+	textbox.registerCommands(appstate.Commands)
 	return &textbox
 }
 
@@ -49,114 +63,93 @@ func (elem *Textbox) Draw() {
 	}
 
 	appstate := elem.appstate
-	scr_w, scr_h := appstate.Screen.Size()
-	scr_w--; scr_h--
-
-	startRow, endRow := TEXTBOX_STARTROW, scr_h - 2
-	
-	rows := make([]string, endRow - startRow + 1)
-
-	// While GetCursorXY returns the true X and Y coordinates of the cursor, if word-wrapped is enabled, we need to calculate the view X and Y coordinates of the cursor.
-	cursorX, cursorY := 0, 0
-	if appstate.Options.WordWrap {
-		// Convert text into lines short enough to fit in [0, scr_w]
-		curLine := 0
-		for _, str := range(strings.Split(elem.buf.StringBeforeIndex(), "\n")) {
-			if (startRow + curLine) > endRow {
-				break
-			}
-
-			// Split string
-			if len(str) <= scr_w {
-				rows[curLine] = str
-			} else {
-				fullString := str
-				for len(fullString) > scr_w {
-					if (startRow + curLine) >= endRow {
-						break
-					}
-					rows[curLine] = fullString[:scr_w]
-					curLine++
-					fullString = fullString[scr_w:]
-				}
-				// Add the final line
-				if len(fullString) <= scr_w {
-					rows[curLine] = fullString
-				} else {
-					rows[curLine] = fullString[:scr_w]
-				}
-			}
-			curLine++
+	scr_w, _ := appstate.Screen.Size()
+	width, height := elem.viewSize()
+	lines := elem.lines()
+	elem.clampView(lines)
+
+	// Convert the lines from topLine onwards into rows
+	type textRow struct {
+		text []rune
+		indices []int // Buffer index of the rune shown in each cell
+		end int // Buffer index of the line end, if the row is the last row of its line, otherwise -1
+	}
+	rows := make([]textRow, 0, height)
+	lineStart := elem.buf.LineStart(elem.topLine)
+	for i := elem.topLine; i < lines.Len() && len(rows) < height; i++ {
+		line := lines.Get(i)
+		cells, cols := elem.expandTabs(line)
+		indices := make([]int, len(cols))
+		for j, col := range(cols) {
+			indices[j] = lineStart + col
 		}
+		lineEnd := lineStart + len(line)
+		lineStart = lineEnd + 1
 
-		// Set view X and Y coordinates
-		curLine--;
-		cursorX = len(rows[curLine])
-		cursorY = curLine + startRow
-
-		// Process the other lines
-		for _, str := range(strings.Split(elem.buf.StringAfterInclIndex(), "\n")) {
-			if (startRow + curLine) > endRow {
-				break
-			}
-
-			// Split string
-			fullString := rows[curLine] + str
-			if len(fullString) <= scr_w {
-				rows[curLine] = fullString
+		if !appstate.Options.WordWrap {
+			// Truncate line based on leftIndex
+			if len(cells) <= elem.leftIndex {
+				rows = append(rows, textRow{nil, nil, lineEnd})
 			} else {
-				for len(fullString) > scr_w {
-					if (startRow + curLine) >= endRow {
-						break
-					}
-					rows[curLine] = fullString[:scr_w]
-					curLine++
-					fullString = fullString[scr_w:]
-				}
-				// Add the final line
-				if len(fullString) <= scr_w {
-					rows[curLine] = fullString
-				} else {
-					rows[curLine] = fullString[:scr_w]
-				}
+				rows = append(rows, textRow{cells[elem.leftIndex:], indices[elem.leftIndex:], lineEnd})
 			}
-			curLine++
+			continue
 		}
-	} else {
-		cursorX, cursorY = elem.GetCursorXY()
-
-		// Convert text into a series of truncated lines starting from leftIndex
-		for i, str := range(strings.Split(elem.buf.String(), "\n")) {
-			if (startRow + i) > endRow {
-				break
-			}
 
-			// Truncate string based on leftIndex
-			if len(str) < elem.leftIndex {
-				rows[i] = ""
-			} else if len(str[elem.leftIndex:]) <= scr_w {
-				rows[i] = str[elem.leftIndex:]
-			} else {
-				rows[i] = str[elem.leftIndex:elem.leftIndex + scr_w+1]
+		// Split line into rows of at most `width` cells
+		subRow := 0
+		if i == elem.topLine {
+			subRow = elem.topSubRow
+		}
+		for ; subRow < lineRows(len(cells), width) && len(rows) < height; subRow++ {
+			rowStart, rowEnd := subRow * width, (subRow + 1) * width
+			end := -1
+			if rowEnd >= len(cells) {
+				rowEnd, end = len(cells), lineEnd
 			}
+			rows = append(rows, textRow{cells[rowStart:rowEnd], indices[rowStart:rowEnd], end})
 		}
-
-		// Update cursor with textbox positioning
-		cursorX -= elem.leftIndex
-		cursorY += TEXTBOX_STARTROW
 	}
 
-	// Draw text
-	for i, str := range(rows) {
-		if startRow + i > endRow {
-			break
+	// Draw text, highlighting the selection and highlighted ranges
+	selStart, selEnd, selected := elem.Selection()
+	isSelected := func(index int) bool {
+		return selected && index >= selStart && index < selEnd
+	}
+	isHighlighted := func(index int) bool {
+		i := sort.Search(len(elem.highlights), func(i int) bool { return elem.highlights[i].End > index })
+		return i < len(elem.highlights) && elem.highlights[i].Start <= index
+	}
+	for i := 0; i < height; i++ {
+		row := textRow{nil, nil, -1}
+		if i < len(rows) {
+			row = rows[i]
+		}
+		for x := 0; x < scr_w; x++ {
+			ch, style := ' ', appstate.TextboxStyle
+			if x < len(row.text) {
+				ch = row.text[x]
+				if isSelected(row.indices[x]) {
+					style = appstate.SelectionStyle
+				} else if isHighlighted(row.indices[x]) {
+					style = appstate.HighlightStyle
+				}
+			} else if x == len(row.text) && row.end >= 0 && isSelected(row.end) {
+				// Selected line end
+				style = appstate.SelectionStyle
+			}
+			appstate.Screen.SetContent(x, TEXTBOX_STARTROW + i, ch, nil, style)
 		}
-		drawText(appstate.Screen, 0, TEXTBOX_STARTROW + i, scr_w, TEXTBOX_STARTROW + i, appstate.TextboxStyle, fmt.Sprintf("%-*s", scr_w, str))
 	}
 
-	// Show Cursor
+	// Show Cursor, if it is within the view
 	if elem.active {
-		appstate.Screen.ShowCursor(cursorX, cursorY)
+		cursorX, cursorY, visible := elem.viewCursorXY(lines)
+		if visible {
+			appstate.Screen.ShowCursor(cursorX, cursorY + TEXTBOX_STARTROW)
+		} else {
+			appstate.Screen.HideCursor()
+		}
 	}
 
 	elem.drawn = true
@@ -193,18 +186,11 @@ func (elem *Textbox) SetCursorIndex(newCursorIndex int) {
 	// Update true cursorXY
 	elem.UpdateCursorXY()
 
-	// Update left accordingly
-	cursorX, _ := elem.GetCursorXY()
-	scr_w, _ := elem.appstate.Screen.Size()
-	scr_w --
-
-	for (cursorX - elem.leftIndex) > scr_w {
-		elem.leftIndex++
-	}
+	// Scroll the view to keep the cursor visible
+	elem.ScrollToCursor()
 
-	for (cursorX - elem.leftIndex) < 0 {
-		elem.leftIndex--
-	}
+	// Remember the column for vertical movement
+	elem.stickyX = elem.viewCursorX()
 }
 
 func (elem *Textbox) IsHidden() bool {
@@ -220,47 +206,105 @@ func (elem *Textbox) Show() {
 	elem.hidden = false
 }
 
+func (elem *Textbox) Redraw() {
+	elem.drawn = false
+}
+
+// Handles typed characters. Other keys are bound to the textbox's commands in the keymap.
 func (elem *Textbox) HandleKey(keyEvent *tcell.EventKey) {
 	if !elem.IsActive() {
 		return
 	}
-	key, ch := keyEvent.Key(), keyEvent.Rune()
-	
-	// Arrow Keys: Move cursor index
-	switch key {
-	case tcell.KeyLeft:
-		elem.SetCursorIndex(elem.GetCursorIndex() - 1)
-		return
-	case tcell.KeyRight:
-		elem.SetCursorIndex(elem.GetCursorIndex() + 1)
-		return
-	case tcell.KeyUp:
-		//TODO: Go up based on sticky x
-		return
-	case tcell.KeyDown:
-		//TODO: Go down based on sticky x
+	if keyEvent.Key() != tcell.KeyRune || keyEvent.Modifiers() & (tcell.ModCtrl | tcell.ModAlt) != 0 {
 		return
 	}
 
-	// Non-control keys
-	switch key {
-	case tcell.KeyBackspace, tcell.KeyBackspace2:
-		elem.Backspace()
-	case tcell.KeyDelete:
-		elem.Delete()
-	case tcell.KeyEnter:
-		elem.Insert('\n')
-	case tcell.KeyTab:
-		elem.Insert('\t')
-	default:
-		elem.Insert(ch)
+	elem.Insert(keyEvent.Rune())
+}
+
+// Returns false if the buffer is read-only.
+func (elem *Textbox) Editable() bool {
+	return !elem.appstate.ReadOnly
+}
+
+// Registers the cursor movement, selection, editing and scrolling commands of the textbox.
+func (elem *Textbox) registerCommands(registry *command.Registry) {
+	// Each movement is registered as "cursor.<name>", and as "select.<name>" which extends the selection instead
+	movements := []struct {
+		name string
+		title string
+		move func()
+	}{
+		{"left", "Left", func() { elem.SetCursorIndex(elem.cursorIndex - 1) }},
+		{"right", "Right", func() { elem.SetCursorIndex(elem.cursorIndex + 1) }},
+		{"lineUp", "Line Up", func() { elem.MoveRows(-1) }},
+		{"lineDown", "Line Down", func() { elem.MoveRows(1) }},
+		{"pageUp", "Page Up", func() { elem.MovePages(-1) }},
+		{"pageDown", "Page Down", func() { elem.MovePages(1) }},
+		{"lineStart", "to Line Start", elem.MoveToRowStart},
+		{"lineEnd", "to Line End", elem.MoveToRowEnd},
+		{"bufferStart", "to Start of File", func() { elem.SetCursorIndex(0) }},
+		{"bufferEnd", "to End of File", func() { elem.SetCursorIndex(elem.buf.Length()) }},
 	}
 
-	if !elem.appstate.FileModified {
-		elem.appstate.FileModified = true
+	// Without Shift, Left and Right collapse the selection to its start and end instead of moving
+	collapseTo := map[string]func(start, end int) int{
+		"left": func(start, end int) int { return start },
+		"right": func(start, end int) int { return end },
 	}
+
+	for _, movement := range(movements) {
+		move, cursorMove := movement.move, movement.move
+		if collapse, ok := collapseTo[movement.name]; ok {
+			cursorMove = func() {
+				if start, end, selected := elem.Selection(); selected {
+					elem.SetCursorIndex(collapse(start, end))
+				} else {
+					move()
+				}
+			}
+		}
+		registry.Register(command.Command{Name: "cursor." + movement.name, Title: "Move " + movement.title, Run: func() { elem.navigate(cursorMove, false) }})
+		registry.Register(command.Command{Name: "select." + movement.name, Title: "Select " + movement.title, Run: func() { elem.navigate(move, true) }})
+	}
+
+	registry.Register(command.Command{Name: "view.scrollUp", Title: "Scroll Up", Run: func() { elem.ScrollView(-1) }})
+	registry.Register(command.Command{Name: "view.scrollDown", Title: "Scroll Down", Run: func() { elem.ScrollView(1) }})
+
+	canUndo := func() bool {
+		return elem.Editable() && elem.appstate.History.CanUndo()
+	}
+	canRedo := func() bool {
+		return elem.Editable() && elem.appstate.History.CanRedo()
+	}
+
+	registry.Register(command.Command{Name: "edit.undo", Title: "Undo", Run: elem.Undo, Enabled: canUndo})
+	registry.Register(command.Command{Name: "edit.redo", Title: "Redo", Run: elem.Redo, Enabled: canRedo})
+	registry.Register(command.Command{Name: "edit.selectAll", Title: "Select All", Run: elem.SelectAll})
+	registry.Register(command.Command{Name: "edit.backspace", Title: "Backspace", Run: elem.Backspace, Enabled: elem.Editable})
+	registry.Register(command.Command{Name: "edit.delete", Title: "Delete", Run: elem.Delete, Enabled: elem.Editable})
+	registry.Register(command.Command{Name: "edit.newline", Title: "New Line", Run: func() { elem.Insert('\n') }, Enabled: elem.Editable})
+	registry.Register(command.Command{Name: "edit.tab", Title: "Insert Tab", Run: func() { elem.Insert('\t') }, Enabled: elem.Editable})
 }
 
+// Moves the cursor with `move`. If `extend` is true, the selection is extended to the new cursor position, otherwise it is cleared.
+func (elem *Textbox) navigate(move func(), extend bool) {
+	if _, _, selected := elem.Selection(); extend && !selected {
+		elem.selectionAnchor = elem.cursorIndex
+	}
+	move()
+	if !extend {
+		elem.ClearSelection()
+	}
+	elem.appstate.History.Break()
+}
+
+// Scrolls the view by `delta` pages, moving the cursor with it.
+func (elem *Textbox) MovePages(delta int) {
+	_, height := elem.viewSize()
+	elem.ScrollView(delta * height)
+	elem.MoveRows(delta * height)
+}
 
 func (elem *Textbox) Content() string {
 	return elem.buf.String()
@@ -277,25 +321,601 @@ func (elem *Textbox) GetCursorXY() (x int, y int) {
 }
 
 func (elem *Textbox) UpdateCursorXY() {
-	// x is number of characters in last line, y is number of lines
-	lines := strings.Split(elem.buf.StringBeforeIndex(), "\n")
-	elem.cursorX = len(lines[len(lines) - 1])
-	elem.cursorY = len(lines) - 1
+	elem.cursorY, elem.cursorX = elem.buf.LineCol(elem.cursorIndex)
+}
+
+// Returns the width and height of the text area. Rows are wrapped at `width` runes, leaving the last column for the cursor.
+func (elem *Textbox) viewSize() (width int, height int) {
+	scr_w, scr_h := elem.appstate.Screen.Size()
+	width, height = scr_w - 1, scr_h - 2 - TEXTBOX_STARTROW
+	if elem.appstate.Options.HideStatusBar {
+		// Extend over the rows of the status bar
+		height += 2
+	}
+	height -= elem.bottomRows
+	if width < 1 {
+		width = 1
+	}
+	if height < 1 {
+		height = 1
+	}
+	return width, height
+}
+
+// textLines: The lines of the buffer, each fetched from it when first needed rather than splitting the whole buffer.
+type textLines struct {
+	buf textbuffer.TextBuffer
+	fetched map[int][]rune
+}
+
+// Returns the lines of the buffer.
+func (elem *Textbox) lines() *textLines {
+	return &textLines{elem.buf, make(map[int][]rune)}
+}
+
+func (lines *textLines) Len() int {
+	return lines.buf.LineCount()
+}
+
+// Returns line `i`, without its line end.
+func (lines *textLines) Get(i int) []rune {
+	line, ok := lines.fetched[i]
+	if !ok {
+		line = []rune(lines.buf.Line(i))
+		lines.fetched[i] = line
+	}
+	return line
+}
+
+// Returns the width of a tab stop, in cells.
+func (elem *Textbox) tabWidth() int {
+	if elem.appstate.Options.TabWidth > 0 {
+		return elem.appstate.Options.TabWidth
+	}
+	return util.DEFAULT_TAB_WIDTH
+}
+
+// Returns the cells that `line` is displayed in, with each tab expanded to spaces up to the next tab stop, along with the column of the line shown in each cell.
+func (elem *Textbox) expandTabs(line []rune) (cells []rune, cols []int) {
+	tabWidth := elem.tabWidth()
+	cells, cols = make([]rune, 0, len(line)), make([]int, 0, len(line))
+	for col, ch := range(line) {
+		if ch != '\t' {
+			cells, cols = append(cells, ch), append(cols, col)
+			continue
+		}
+		for n := tabWidth - len(cells) % tabWidth; n > 0; n-- {
+			cells, cols = append(cells, ' '), append(cols, col)
+		}
+	}
+	return cells, cols
+}
+
+// Returns the display column (i.e. the cell from the start of the line) of column `col` of `line`.
+func (elem *Textbox) displayCol(line []rune, col int) int {
+	tabWidth := elem.tabWidth()
+	x := 0
+	for _, ch := range(line[:col]) {
+		if ch == '\t' {
+			x += tabWidth - x % tabWidth
+		} else {
+			x++
+		}
+	}
+	return x
+}
+
+// Returns the number of cells `line` is displayed in.
+func (elem *Textbox) displayLen(line []rune) int {
+	return elem.displayCol(line, len(line))
+}
+
+// Returns the column of `line` displayed at display column `x`. Cells within a tab belong to the tab, and cells past the end of the line to the line end.
+func (elem *Textbox) lineCol(line []rune, x int) int {
+	tabWidth := elem.tabWidth()
+	next := 0
+	for col, ch := range(line) {
+		if ch == '\t' {
+			next += tabWidth - next % tabWidth
+		} else {
+			next++
+		}
+		if x < next {
+			return col
+		}
+	}
+	return len(line)
+}
+
+// Returns the number of rows a line of `length` cells takes up when wrapped at `width`.
+func lineRows(length, width int) int {
+	if length == 0 {
+		return 1
+	}
+	return (length + width - 1) / width
+}
+
+// Returns the row within its line, and the x-coordinate within that row, of display column `col` of a line of `length` cells wrapped at `width`.
+// A cursor at the very end of a full row stays on that row, in the column reserved for it.
+func wrappedPosition(col, length, width int) (subRow int, x int) {
+	if col > 0 && col == length && col % width == 0 {
+		return col / width - 1, width
+	}
+	return col / width, col % width
+}
+
+// Returns the row within its line of the cursor, or 0 if word wrap is disabled.
+func (elem *Textbox) cursorSubRow(lines *textLines) int {
+	if !elem.appstate.Options.WordWrap {
+		return 0
+	}
+	width, _ := elem.viewSize()
+	line := lines.Get(elem.cursorY)
+	subRow, _ := wrappedPosition(elem.displayCol(line, elem.cursorX), elem.displayLen(line), width)
+	return subRow
+}
+
+// Returns the number of visible rows from the top of the view to the start of row `subRow` of line `line`. Negative if the row is above the view.
+func (elem *Textbox) rowsFromTop(lines *textLines, line, subRow int) int {
+	if !elem.appstate.Options.WordWrap {
+		return line - elem.topLine
+	}
+
+	width, _ := elem.viewSize()
+	if line < elem.topLine || (line == elem.topLine && subRow < elem.topSubRow) {
+		return -1
+	}
+	rows := subRow - elem.topSubRow
+	for i := elem.topLine; i < line; i++ {
+		rows += lineRows(elem.displayLen(lines.Get(i)), width)
+	}
+	return rows
+}
+
+// Returns the position of the cursor relative to the view, and whether it is within the view.
+func (elem *Textbox) viewCursorXY(lines *textLines) (x int, y int, visible bool) {
+	width, height := elem.viewSize()
+	if elem.cursorY - elem.topLine >= height {
+		return 0, 0, false
+	}
+	line := lines.Get(elem.cursorY)
+	if !elem.appstate.Options.WordWrap {
+		x, y = elem.displayCol(line, elem.cursorX) - elem.leftIndex, elem.cursorY - elem.topLine
+	} else {
+		var subRow int
+		subRow, x = wrappedPosition(elem.displayCol(line, elem.cursorX), elem.displayLen(line), width)
+		y = elem.rowsFromTop(lines, elem.cursorY, subRow)
+	}
+	return x, y, x >= 0 && x <= width && y >= 0 && y < height
+}
+
+// Keeps topLine and topSubRow within the buffer, e.g. after the screen is resized or lines are deleted.
+func (elem *Textbox) clampView(lines *textLines) {
+	if elem.topLine >= lines.Len() {
+		elem.topLine = lines.Len() - 1
+	}
+	if elem.topLine < 0 {
+		elem.topLine = 0
+	}
+
+	if !elem.appstate.Options.WordWrap {
+		elem.topSubRow = 0
+		return
+	}
+	width, _ := elem.viewSize()
+	if maxSubRow := lineRows(elem.displayLen(lines.Get(elem.topLine)), width) - 1; elem.topSubRow > maxSubRow {
+		elem.topSubRow = maxSubRow
+	}
+	if elem.topSubRow < 0 {
+		elem.topSubRow = 0
+	}
+}
+
+// Turns word wrap on or off, scrolling the view to keep the cursor visible.
+func (elem *Textbox) SetWordWrap(wordWrap bool) {
+	elem.appstate.Options.WordWrap = wordWrap
+	elem.leftIndex, elem.topSubRow = 0, 0
+	elem.SetCursorIndex(elem.cursorIndex)
+}
+
+// Returns the number of lines in the buffer.
+func (elem *Textbox) LineCount() int {
+	return elem.buf.LineCount()
+}
+
+// Moves the cursor to column `col` of line `line`, both counted from 1, clearing the selection. Positions past the end of the buffer or of the line are clamped to it.
+func (elem *Textbox) GoTo(line, col int) {
+	lines := elem.lines()
+	if line > lines.Len() {
+		line = lines.Len()
+	}
+	if line < 1 {
+		line = 1
+	}
+	if col > len(lines.Get(line - 1)) + 1 {
+		col = len(lines.Get(line - 1)) + 1
+	}
+	if col < 1 {
+		col = 1
+	}
+
+	index := elem.buf.LineStart(line - 1) + col - 1
+	elem.ClearSelection()
+	elem.SetCursorIndex(index)
+	elem.appstate.History.Break()
+}
+
+// Scrolls the view so that the cursor is visible.
+func (elem *Textbox) ScrollToCursor() {
+	width, height := elem.viewSize()
+	lines := elem.lines()
+
+	if !elem.appstate.Options.WordWrap {
+		// Horizontal
+		cursorX := elem.displayCol(lines.Get(elem.cursorY), elem.cursorX)
+		if cursorX - elem.leftIndex > width {
+			elem.leftIndex = cursorX - width
+		}
+		if cursorX < elem.leftIndex {
+			elem.leftIndex = cursorX
+		}
+
+		// Vertical
+		if elem.cursorY < elem.topLine {
+			elem.topLine = elem.cursorY
+		}
+		if elem.cursorY >= elem.topLine + height {
+			elem.topLine = elem.cursorY - height + 1
+		}
+		elem.topSubRow = 0
+		return
+	}
+
+	elem.leftIndex = 0
+	if elem.cursorY - elem.topLine >= height {
+		// Every line takes up at least one row, so the view has to start at least this far down
+		elem.topLine, elem.topSubRow = elem.cursorY - height + 1, 0
+	}
+	elem.clampView(lines)
+	subRow := elem.cursorSubRow(lines)
+	rows := elem.rowsFromTop(lines, elem.cursorY, subRow)
+	if rows < 0 {
+		elem.topLine, elem.topSubRow = elem.cursorY, subRow
+	} else if rows >= height {
+		elem.scrollRows(lines, rows - height + 1)
+	}
+}
+
+// Returns the x-coordinate of the cursor within its row, ignoring horizontal scrolling.
+func (elem *Textbox) viewCursorX() int {
+	line := elem.lines().Get(elem.cursorY)
+	if !elem.appstate.Options.WordWrap {
+		return elem.displayCol(line, elem.cursorX)
+	}
+	width, _ := elem.viewSize()
+	_, x := wrappedPosition(elem.displayCol(line, elem.cursorX), elem.displayLen(line), width)
+	return x
+}
+
+// Moves the cursor `delta` rows down (or up, if negative), keeping it as close to stickyX as the row allows.
+// Rows are lines, or the wrapped rows of lines if word wrap is enabled. The cursor stops at the first or last row.
+func (elem *Textbox) MoveRows(delta int) {
+	lines := elem.lines()
+	line, subRow := elem.cursorY, elem.cursorSubRow(lines)
+	width, _ := elem.viewSize()
+	rows := func(line int) int {
+		if !elem.appstate.Options.WordWrap {
+			return 1
+		}
+		return lineRows(elem.displayLen(lines.Get(line)), width)
+	}
+	down := delta > 0
+
+	// Find the target row
+	for ; delta > 0; delta-- {
+		if subRow + 1 < rows(line) {
+			subRow++
+		} else if line + 1 < lines.Len() {
+			line, subRow = line + 1, 0
+		} else {
+			break
+		}
+	}
+	for ; delta < 0; delta++ {
+		if subRow > 0 {
+			subRow--
+		} else if line > 0 {
+			line--
+			subRow = rows(line) - 1
+		} else {
+			break
+		}
+	}
+
+	// Find the column within the target row closest to stickyX. Only the last row of a line has room for the cursor after its last rune.
+	x := elem.stickyX
+	col := 0
+	if elem.appstate.Options.WordWrap {
+		rowStart := subRow * width
+		maxX := elem.displayLen(lines.Get(line)) - rowStart
+		if maxX > width || subRow + 1 < rows(line) {
+			maxX = width - 1
+		}
+		if x > maxX {
+			x = maxX
+		}
+		col = elem.lineCol(lines.Get(line), x + rowStart)
+
+		// A tab that starts on the row above belongs to that row, so moving down skips past it
+		if down && elem.displayCol(lines.Get(line), col) < rowStart {
+			col++
+		}
+	} else {
+		col = elem.lineCol(lines.Get(line), x)
+	}
+
+	// Convert (line, col) to an index
+	index := elem.buf.LineStart(line) + col
+
+	stickyX := elem.stickyX
+	elem.SetCursorIndex(index)
+	elem.stickyX = stickyX
+}
+
+// Moves the cursor to the start of its row: the start of the line, or of the wrapped row of the line if word wrap is enabled, as rows are for MoveRows.
+func (elem *Textbox) MoveToRowStart() {
+	col := 0
+	if elem.appstate.Options.WordWrap {
+		lines := elem.lines()
+		line := lines.Get(elem.cursorY)
+		width, _ := elem.viewSize()
+		rowStart := elem.cursorSubRow(lines) * width
+		col = elem.lineCol(line, rowStart)
+
+		// A tab that starts on the row above belongs to that row
+		if elem.displayCol(line, col) < rowStart {
+			col++
+		}
+	}
+	elem.SetCursorIndex(elem.cursorIndex - elem.cursorX + col)
+}
+
+// Moves the cursor to the end of its row: the end of the line, or of the wrapped row of the line if word wrap is enabled.
+// As with MoveRows, the cursor stops before the last rune of a row that continues on the next row.
+func (elem *Textbox) MoveToRowEnd() {
+	lines := elem.lines()
+	line := lines.Get(elem.cursorY)
+	col := len(line)
+	if elem.appstate.Options.WordWrap {
+		width, _ := elem.viewSize()
+		subRow := elem.cursorSubRow(lines)
+		if subRow + 1 < lineRows(elem.displayLen(line), width) {
+			col = elem.lineCol(line, (subRow + 1) * width - 1)
+		}
+	}
+	elem.SetCursorIndex(elem.cursorIndex - elem.cursorX + col)
+}
+
+// Scrolls the view by `delta` rows (positive is down) without moving the cursor.
+func (elem *Textbox) ScrollView(delta int) {
+	lines := elem.lines()
+	elem.clampView(lines)
+	elem.scrollRows(lines, delta)
+}
+
+// Moves the top of the view by `delta` rows, stopping at the first and last rows of the buffer.
+func (elem *Textbox) scrollRows(lines *textLines, delta int) {
+	if !elem.appstate.Options.WordWrap {
+		elem.topLine += delta
+		elem.clampView(lines)
+		return
+	}
+
+	width, _ := elem.viewSize()
+	for ; delta > 0; delta-- {
+		if elem.topSubRow + 1 < lineRows(elem.displayLen(lines.Get(elem.topLine)), width) {
+			elem.topSubRow++
+		} else if elem.topLine + 1 < lines.Len() {
+			elem.topLine, elem.topSubRow = elem.topLine + 1, 0
+		} else {
+			break
+		}
+	}
+	for ; delta < 0; delta++ {
+		if elem.topSubRow > 0 {
+			elem.topSubRow--
+		} else if elem.topLine > 0 {
+			elem.topLine--
+			elem.topSubRow = lineRows(elem.displayLen(lines.Get(elem.topLine)), width) - 1
+		} else {
+			break
+		}
+	}
+}
+
+// Returns the selected range [start, end) of the buffer, and false if nothing is selected.
+func (elem *Textbox) Selection() (start int, end int, ok bool) {
+	if elem.selectionAnchor < 0 {
+		return 0, 0, false
+	}
+	anchor := elem.selectionAnchor
+	if anchor > elem.buf.Length() {
+		anchor = elem.buf.Length()
+	}
+	start, end = anchor, elem.cursorIndex
+	if start > end {
+		start, end = end, start
+	}
+	return start, end, start != end
+}
+
+// Returns the selected text, or "" if nothing is selected.
+func (elem *Textbox) SelectedText() string {
+	start, end, ok := elem.Selection()
+	if !ok {
+		return ""
+	}
+	return elem.buf.Slice(start, end)
+}
+
+// Selects the range [start, end) of the buffer, leaving the cursor at the end.
+func (elem *Textbox) Select(start, end int) {
+	elem.selectionAnchor = start
+	elem.SetCursorIndex(end)
+	elem.appstate.History.Break()
+	elem.drawn = false
+}
+
+// Highlights the ranges `highlights`, which must be in order and not overlap. nil removes the highlights.
+func (elem *Textbox) SetHighlights(highlights []search.Match) {
+	elem.highlights = highlights
+	elem.drawn = false
+}
+
+// Sets the number of rows at the bottom of the view that are covered by other elements.
+func (elem *Textbox) SetBottomRows(rows int) {
+	elem.bottomRows = rows
+	elem.SetCursorIndex(elem.cursorIndex)
+	elem.drawn = false
+}
+
+// Selects the whole buffer, leaving the cursor at the end.
+func (elem *Textbox) SelectAll() {
+	elem.selectionAnchor = 0
+	elem.SetCursorIndex(elem.buf.Length())
+}
+
+func (elem *Textbox) ClearSelection() {
+	elem.selectionAnchor = -1
+}
+
+// Deletes the selected text. Returns false if nothing was selected.
+func (elem *Textbox) DeleteSelection() bool {
+	start, end, ok := elem.Selection()
+	if !ok || !elem.Editable() {
+		return false
+	}
+	elem.appstate.History.Delete(start, end, elem.cursorIndex)
+	elem.ClearSelection()
+	elem.SetCursorIndex(start)
+	return true
+}
+
+// Inserts `s` at the cursor, replacing the selection if there is one. Does nothing if the buffer is read-only.
+func (elem *Textbox) InsertText(s string) {
+	if !elem.Editable() {
+		return
+	}
+	history := elem.appstate.History
+	start, end, selected := elem.Selection()
+	if selected {
+		history.Break()
+		history.Replace(start, end, s, elem.cursorIndex)
+		history.Break()
+		elem.ClearSelection()
+	} else {
+		start = elem.cursorIndex
+		history.Insert(start, s, elem.cursorIndex)
+	}
+	elem.SetCursorIndex(start + utf8.RuneCountInString(s))
 }
 
 func (elem *Textbox) Insert(key rune) {
-	elem.buf.Insert(elem.cursorIndex, key)
-	elem.SetCursorIndex(elem.cursorIndex + 1)
+	elem.InsertText(string(key))
 }
 
-// Deletes the character directly after the cursor.
+// Deletes the character directly after the cursor, or the selection if there is one.
 func (elem *Textbox) Delete() {
-	elem.buf.Delete(elem.cursorIndex)
+	if !elem.Editable() || elem.DeleteSelection() {
+		return
+	}
+	elem.appstate.History.Delete(elem.cursorIndex, elem.cursorIndex + 1, elem.cursorIndex)
+	elem.SetCursorIndex(elem.cursorIndex)
 }
 
-// Deletes the character directly before the cursor, and shifts the cursor backward
+// Deletes the character directly before the cursor, and shifts the cursor backward. Deletes the selection instead if there is one.
 func (elem *Textbox) Backspace() {
+	if !elem.Editable() || elem.DeleteSelection() || elem.cursorIndex == 0 {
+		return
+	}
+	elem.appstate.History.Delete(elem.cursorIndex - 1, elem.cursorIndex, elem.cursorIndex)
 	elem.SetCursorIndex(elem.cursorIndex - 1)
-	elem.buf.Delete(elem.cursorIndex)
 }
 
+// Copies the selection to the clipboard. Does nothing if nothing is selected.
+func (elem *Textbox) Copy() error {
+	if _, _, ok := elem.Selection(); !ok {
+		return nil
+	}
+	return elem.appstate.Clipboard.Set(elem.SelectedText())
+}
+
+// Copies the selection to the clipboard, then deletes it. The selection is kept if copying fails.
+func (elem *Textbox) Cut() error {
+	if _, _, ok := elem.Selection(); !ok || !elem.Editable() {
+		return nil
+	}
+	if err := elem.appstate.Clipboard.Set(elem.SelectedText()); err != nil {
+		return err
+	}
+	elem.appstate.History.Break()
+	elem.DeleteSelection()
+	elem.appstate.History.Break()
+	return nil
+}
+
+// Inserts the text on the clipboard at the cursor, replacing the selection, as a single undoable edit.
+func (elem *Textbox) Paste() error {
+	text, err := elem.appstate.Clipboard.Get()
+	if err != nil {
+		return err
+	}
+	elem.PasteText(text)
+	return nil
+}
+
+// Inserts pasted `text` at the cursor, replacing the selection, as a single undoable edit.
+func (elem *Textbox) PasteText(text string) {
+	text = util.NormaliseLineEndings(text)
+	if text == "" {
+		return
+	}
+	elem.appstate.History.Break()
+	elem.InsertText(text)
+	elem.appstate.History.Break()
+}
+
+// Replaces the range [start, end) of the buffer with `s` as a single undoable edit, leaving the cursor at the end of `s`. Does nothing if the buffer is read-only.
+func (elem *Textbox) ReplaceRange(start, end int, s string) {
+	if !elem.Editable() {
+		return
+	}
+	history := elem.appstate.History
+	history.Break()
+	history.Replace(start, end, s, elem.cursorIndex)
+	history.Break()
+	elem.ClearSelection()
+	elem.SetCursorIndex(start + utf8.RuneCountInString(s))
+}
+
+// Reverts the last group of edits, moving the cursor to where it was before them.
+func (elem *Textbox) Undo() {
+	if !elem.Editable() {
+		return
+	}
+	elem.ClearSelection()
+	if cursorIndex, ok := elem.appstate.History.Undo(); ok {
+		elem.SetCursorIndex(cursorIndex)
+	}
+}
+
+// Re-applies the last undone group of edits.
+func (elem *Textbox) Redo() {
+	if !elem.Editable() {
+		return
+	}
+	elem.ClearSelection()
+	if cursorIndex, ok := elem.appstate.History.Redo(); ok {
+		elem.SetCursorIndex(cursorIndex)
+	}
+}
@@ -0,0 +1,464 @@
+package tui
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"github.com/gdamore/tcell/v2"
+)
+
+// Returns a textbox on an 80x24 simulation screen containing `text`, with the cursor at the end.
+func newTestTextbox(t testing.TB, text string, wordWrap bool) (*Textbox, tcell.SimulationScreen) {
+	appstate, screen := newTestAppState(t)
+	appstate.Options.WordWrap = wordWrap
+	appstate.TextBuffer.Append(text)
+	return NewTextbox(appstate), screen
+}
+
+// Returns the text shown in the text area of the screen.
+func textboxRows(textbox *Textbox, screen tcell.SimulationScreen) []string {
+	textbox.Draw()
+	_, height := textbox.viewSize()
+	rows := screenRows(screen)[TEXTBOX_STARTROW:TEXTBOX_STARTROW + height]
+	for i, row := range(rows) {
+		rows[i] = strings.TrimRight(row, " ")
+	}
+	return rows
+}
+
+func numberedLines(count int) string {
+	lines := make([]string, count)
+	for i := range(lines) {
+		lines[i] = fmt.Sprintf("line %d", i)
+	}
+	return strings.Join(lines, "\n")
+}
+
+func TestTextboxScrollsToCursor(t *testing.T) {
+	textbox, screen := newTestTextbox(t, numberedLines(100), false)
+	_, height := textbox.viewSize()
+
+	// Cursor at the end: last line is at the bottom of the view
+	rows := textboxRows(textbox, screen)
+	if rows[height - 1] != "line 99" {
+		t.Fatalf("Expected \"line 99\" at the bottom, instead:\n" + strings.Join(rows, "\n"))
+	}
+	x, y, visible := screen.GetCursor()
+	if !visible || x != 7 || y != TEXTBOX_STARTROW + height - 1 {
+		t.Fatalf("Expected cursor at the end of the last row, instead (%d, %d, %v)", x, y, visible)
+	}
+
+	// Cursor at the start: first line is at the top of the view
+	textbox.SetCursorIndex(0)
+	rows = textboxRows(textbox, screen)
+	if rows[0] != "line 0" {
+		t.Fatalf("Expected \"line 0\" at the top, instead:\n" + strings.Join(rows, "\n"))
+	}
+}
+
+func TestTextboxScrollView(t *testing.T) {
+	textbox, screen := newTestTextbox(t, numberedLines(100), false)
+	textbox.SetCursorIndex(0)
+
+	// Ctrl-Down scrolls without moving the cursor, which goes off-screen
+	for i := 0; i < 3; i++ {
+		pressKey(textbox, tcell.KeyDown, tcell.ModCtrl)
+	}
+	rows := textboxRows(textbox, screen)
+	if rows[0] != "line 3" {
+		t.Fatalf("Expected \"line 3\" at the top, instead:\n" + strings.Join(rows, "\n"))
+	}
+	if textbox.GetCursorIndex() != 0 {
+		t.Fatalf("Expected cursor not to move, instead cursor index: %d", textbox.GetCursorIndex())
+	}
+	if _, _, visible := screen.GetCursor(); visible {
+		t.Fatalf("Expected cursor to be hidden while scrolled out of view")
+	}
+
+	// Ctrl-Up stops at the top
+	for i := 0; i < 5; i++ {
+		pressKey(textbox, tcell.KeyUp, tcell.ModCtrl)
+	}
+	rows = textboxRows(textbox, screen)
+	if rows[0] != "line 0" {
+		t.Fatalf("Expected \"line 0\" at the top, instead:\n" + strings.Join(rows, "\n"))
+	}
+
+	// Typing brings the cursor back into view
+	for i := 0; i < 3; i++ {
+		pressKey(textbox, tcell.KeyDown, tcell.ModCtrl)
+	}
+	textbox.HandleKey(tcell.NewEventKey(tcell.KeyRune, 'x', tcell.ModNone))
+	rows = textboxRows(textbox, screen)
+	if rows[0] != "xline 0" {
+		t.Fatalf("Expected \"xline 0\" at the top, instead:\n" + strings.Join(rows, "\n"))
+	}
+}
+
+func TestTextboxScrollWordWrap(t *testing.T) {
+	// Each long line wraps into 3 rows of 79 runes
+	long := strings.Repeat("a", 79) + strings.Repeat("b", 79) + strings.Repeat("c", 10)
+	text := strings.Repeat(long + "\n", 20) + "end"
+	textbox, screen := newTestTextbox(t, text, true)
+	_, height := textbox.viewSize()
+
+	rows := textboxRows(textbox, screen)
+	if rows[height - 1] != "end" || rows[height - 2] != strings.Repeat("c", 10) {
+		t.Fatalf("Expected the last rows at the bottom, instead:\n" + strings.Join(rows, "\n"))
+	}
+
+	// Scrolling moves by wrapped rows, not lines
+	textbox.SetCursorIndex(0)
+	textbox.ScrollView(1)
+	rows = textboxRows(textbox, screen)
+	if rows[0] != strings.Repeat("b", 79) {
+		t.Fatalf("Expected the second row of the first line at the top, instead:\n" + strings.Join(rows, "\n"))
+	}
+
+	// Moving the cursor to a row above the view scrolls up to it
+	textbox.SetCursorIndex(1)
+	rows = textboxRows(textbox, screen)
+	if rows[0] != strings.Repeat("a", 79) {
+		t.Fatalf("Expected the first row at the top, instead:\n" + strings.Join(rows, "\n"))
+	}
+	if x, y, _ := screen.GetCursor(); x != 1 || y != TEXTBOX_STARTROW {
+		t.Fatalf("Expected cursor at (1, %d), instead (%d, %d)", TEXTBOX_STARTROW, x, y)
+	}
+
+	// A cursor at the end of a full row stays on that row
+	textbox.SetCursorIndex(0)
+	textbox.buf.Clear()
+	textbox.buf.Append(strings.Repeat("a", 79))
+	textbox.SetCursorIndex(79)
+	textboxRows(textbox, screen)
+	if x, y, _ := screen.GetCursor(); x != 79 || y != TEXTBOX_STARTROW {
+		t.Fatalf("Expected cursor at (79, %d), instead (%d, %d)", TEXTBOX_STARTROW, x, y)
+	}
+}
+
+
+// Presses a key as the app does: keys bound to commands run them, and other keys are handed to the textbox.
+func pressKey(textbox *Textbox, k tcell.Key, mod tcell.ModMask) {
+	keyEvent := tcell.NewEventKey(k, 0, mod)
+	if !textbox.appstate.Keymap.Dispatch(keyEvent, textbox.appstate.Commands) {
+		textbox.HandleKey(keyEvent)
+	}
+}
+
+func expectCursorXY(t *testing.T, textbox *Textbox, x, y int) {
+	t.Helper()
+	if cx, cy := textbox.GetCursorXY(); cx != x || cy != y {
+		t.Fatalf("Expected cursor at (%d, %d), instead (%d, %d)", x, y, cx, cy)
+	}
+}
+
+func TestTextboxStickyColumn(t *testing.T) {
+	textbox, _ := newTestTextbox(t, "a long line\nab\n\nanother long line", false)
+	textbox.SetCursorIndex(9) // "a long li|ne"
+
+	// Column is remembered through shorter lines
+	pressKey(textbox, tcell.KeyDown, tcell.ModNone)
+	expectCursorXY(t, textbox, 2, 1)
+	pressKey(textbox, tcell.KeyDown, tcell.ModNone)
+	expectCursorXY(t, textbox, 0, 2)
+	pressKey(textbox, tcell.KeyDown, tcell.ModNone)
+	expectCursorXY(t, textbox, 9, 3)
+
+	// Stops at the last line
+	pressKey(textbox, tcell.KeyDown, tcell.ModNone)
+	expectCursorXY(t, textbox, 9, 3)
+
+	// Moving horizontally resets the column
+	pressKey(textbox, tcell.KeyLeft, tcell.ModNone)
+	pressKey(textbox, tcell.KeyUp, tcell.ModNone)
+	pressKey(textbox, tcell.KeyUp, tcell.ModNone)
+	pressKey(textbox, tcell.KeyUp, tcell.ModNone)
+	expectCursorXY(t, textbox, 8, 0)
+}
+
+func TestTextboxHomeEnd(t *testing.T) {
+	textbox, _ := newTestTextbox(t, "first\nsecond line\nthird", false)
+	textbox.SetCursorIndex(9) // "sec|ond line"
+
+	pressKey(textbox, tcell.KeyHome, tcell.ModNone)
+	expectCursorXY(t, textbox, 0, 1)
+	pressKey(textbox, tcell.KeyEnd, tcell.ModNone)
+	expectCursorXY(t, textbox, 11, 1)
+	pressKey(textbox, tcell.KeyHome, tcell.ModCtrl)
+	expectCursorXY(t, textbox, 0, 0)
+	pressKey(textbox, tcell.KeyEnd, tcell.ModCtrl)
+	expectCursorXY(t, textbox, 5, 2)
+}
+
+func TestTextboxHomeEndWrapped(t *testing.T) {
+	// First line wraps into 3 rows of 79 runes. Home and End move within the row, as Up and Down move between rows
+	long := strings.Repeat("a", 79) + strings.Repeat("b", 79) + strings.Repeat("c", 10)
+	textbox, _ := newTestTextbox(t, long + "\nshort", true)
+	textbox.SetCursorIndex(79 + 20)
+
+	pressKey(textbox, tcell.KeyEnd, tcell.ModNone)
+	expectCursorXY(t, textbox, 79 + 78, 0)
+	pressKey(textbox, tcell.KeyEnd, tcell.ModNone)
+	expectCursorXY(t, textbox, 79 + 78, 0)
+	pressKey(textbox, tcell.KeyHome, tcell.ModNone)
+	expectCursorXY(t, textbox, 79, 0)
+	pressKey(textbox, tcell.KeyHome, tcell.ModNone)
+	expectCursorXY(t, textbox, 79, 0)
+
+	// The last row ends after the last rune of the line
+	pressKey(textbox, tcell.KeyDown, tcell.ModNone)
+	pressKey(textbox, tcell.KeyEnd, tcell.ModNone)
+	expectCursorXY(t, textbox, 158 + 10, 0)
+	pressKey(textbox, tcell.KeyHome, tcell.ModShift)
+	expectSelection(t, textbox, strings.Repeat("c", 10))
+}
+
+func TestTextboxPageUpDown(t *testing.T) {
+	textbox, screen := newTestTextbox(t, numberedLines(100), false)
+	_, height := textbox.viewSize()
+	textbox.SetCursorIndex(2) // "li|ne 0"
+
+	// Moves a page at a time, keeping the cursor on the same screen row
+	pressKey(textbox, tcell.KeyPgDn, tcell.ModNone)
+	expectCursorXY(t, textbox, 2, height)
+	rows := textboxRows(textbox, screen)
+	if rows[0] != fmt.Sprintf("line %d", height) {
+		t.Fatalf("Expected the next page at the top, instead:\n" + strings.Join(rows, "\n"))
+	}
+	pressKey(textbox, tcell.KeyPgUp, tcell.ModNone)
+	expectCursorXY(t, textbox, 2, 0)
+
+	// Stops at the last line
+	for i := 0; i < 10; i++ {
+		pressKey(textbox, tcell.KeyPgDn, tcell.ModNone)
+	}
+	expectCursorXY(t, textbox, 2, 99)
+}
+
+func TestTextboxMoveWrappedRows(t *testing.T) {
+	// First line wraps into 3 rows of 79 runes
+	long := strings.Repeat("a", 79) + strings.Repeat("b", 79) + strings.Repeat("c", 10)
+	textbox, _ := newTestTextbox(t, long + "\nshort", true)
+	textbox.SetCursorIndex(50)
+
+	// Down moves through the wrapped rows of a line before the next line
+	pressKey(textbox, tcell.KeyDown, tcell.ModNone)
+	expectCursorXY(t, textbox, 79 + 50, 0)
+	pressKey(textbox, tcell.KeyDown, tcell.ModNone)
+	expectCursorXY(t, textbox, 158 + 10, 0)
+	pressKey(textbox, tcell.KeyDown, tcell.ModNone)
+	expectCursorXY(t, textbox, 5, 1)
+
+	// Column is remembered on the way back up
+	pressKey(textbox, tcell.KeyUp, tcell.ModNone)
+	pressKey(textbox, tcell.KeyUp, tcell.ModNone)
+	expectCursorXY(t, textbox, 79 + 50, 0)
+
+	// The cursor can't sit after the last rune of a row that continues on the next row
+	textbox.buf.Append(strings.Repeat("d", 74))
+	pressKey(textbox, tcell.KeyEnd, tcell.ModCtrl)
+	expectCursorXY(t, textbox, 79, 1)
+	pressKey(textbox, tcell.KeyUp, tcell.ModNone)
+	expectCursorXY(t, textbox, 158 + 10, 0)
+	pressKey(textbox, tcell.KeyUp, tcell.ModNone)
+	expectCursorXY(t, textbox, 79 + 78, 0)
+}
+
+func expectSelection(t *testing.T, textbox *Textbox, expected string) {
+	t.Helper()
+	if selected := textbox.SelectedText(); selected != expected {
+		t.Fatalf("Expected selection \"%v\", instead \"%v\"", expected, selected)
+	}
+}
+
+func TestTextboxShiftSelection(t *testing.T) {
+	textbox, _ := newTestTextbox(t, "hello world\nsecond", false)
+	textbox.SetCursorIndex(6) // "hello |world"
+
+	pressKey(textbox, tcell.KeyRight, tcell.ModShift)
+	pressKey(textbox, tcell.KeyRight, tcell.ModShift)
+	expectSelection(t, textbox, "wo")
+	pressKey(textbox, tcell.KeyEnd, tcell.ModShift)
+	expectSelection(t, textbox, "world")
+	pressKey(textbox, tcell.KeyDown, tcell.ModShift)
+	expectSelection(t, textbox, "world\nsecond")
+
+	// Moving back past the anchor selects before it
+	pressKey(textbox, tcell.KeyHome, tcell.ModShift|tcell.ModCtrl)
+	expectSelection(t, textbox, "hello ")
+
+	// Left and Right collapse the selection to its ends
+	pressKey(textbox, tcell.KeyRight, tcell.ModNone)
+	expectSelection(t, textbox, "")
+	if textbox.GetCursorIndex() != 6 {
+		t.Fatalf("Expected cursor at 6, instead cursor index: %d", textbox.GetCursorIndex())
+	}
+
+	// Ctrl-A selects everything
+	pressKey(textbox, tcell.KeyCtrlA, tcell.ModCtrl)
+	expectSelection(t, textbox, "hello world\nsecond")
+	pressKey(textbox, tcell.KeyUp, tcell.ModNone)
+	expectSelection(t, textbox, "")
+}
+
+func TestTextboxSelectionEdits(t *testing.T) {
+	textbox, _ := newTestTextbox(t, "hello world", false)
+	textbox.SetCursorIndex(0)
+	for i := 0; i < 5; i++ {
+		pressKey(textbox, tcell.KeyRight, tcell.ModShift)
+	}
+
+	// Typing replaces the selection, and is undone in one step
+	textbox.HandleKey(tcell.NewEventKey(tcell.KeyRune, 'J', tcell.ModNone))
+	if textbox.Content() != "J world" || textbox.GetCursorIndex() != 1 {
+		t.Fatalf("Expected \"J world\" with cursor at 1, instead \"%v\" with cursor at %d", textbox.Content(), textbox.GetCursorIndex())
+	}
+	textbox.Undo()
+	if textbox.Content() != "hello world" {
+		t.Fatalf("Expected \"hello world\" after undo, instead \"%v\"", textbox.Content())
+	}
+
+	// Backspace and Delete remove the selection
+	textbox.SetCursorIndex(11)
+	pressKey(textbox, tcell.KeyLeft, tcell.ModShift)
+	pressKey(textbox, tcell.KeyLeft, tcell.ModShift)
+	pressKey(textbox, tcell.KeyBackspace2, tcell.ModNone)
+	if textbox.Content() != "hello wor" {
+		t.Fatalf("Expected \"hello wor\", instead \"%v\"", textbox.Content())
+	}
+	pressKey(textbox, tcell.KeyHome, tcell.ModShift)
+	pressKey(textbox, tcell.KeyDelete, tcell.ModNone)
+	if textbox.Content() != "" {
+		t.Fatalf("Expected empty buffer, instead \"%v\"", textbox.Content())
+	}
+}
+
+func TestTextboxSelectionHighlight(t *testing.T) {
+	textbox, screen := newTestTextbox(t, "abc\ndef", false)
+	textbox.SetCursorIndex(2)
+	pressKey(textbox, tcell.KeyDown, tcell.ModShift) // Selects "c\nde"
+	textbox.Draw()
+
+	selectionStyle := textbox.appstate.SelectionStyle
+	for x, expected := range([]bool{false, false, true, true}) {
+		_, _, style, _ := screen.GetContent(x, TEXTBOX_STARTROW)
+		if (style == selectionStyle) != expected {
+			t.Fatalf("Expected highlight at (%d, 0) to be %v", x, expected)
+		}
+	}
+	for x, expected := range([]bool{true, true, false, false}) {
+		_, _, style, _ := screen.GetContent(x, TEXTBOX_STARTROW + 1)
+		if (style == selectionStyle) != expected {
+			t.Fatalf("Expected highlight at (%d, 1) to be %v", x, expected)
+		}
+	}
+}
+
+func TestTextboxKeySequence(t *testing.T) {
+	textbox, _ := newTestTextbox(t, "hello world", false)
+	if err := textbox.appstate.Keymap.Bind("Ctrl+K Ctrl+A", "edit.selectAll"); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	// Ctrl+K Ctrl+A: Select all
+	pressKey(textbox, tcell.KeyCtrlK, tcell.ModCtrl)
+	pressKey(textbox, tcell.KeyCtrlA, tcell.ModCtrl)
+	expectSelection(t, textbox, "hello world")
+
+	// A cancelled sequence doesn't type anything
+	textbox.ClearSelection()
+	pressKey(textbox, tcell.KeyCtrlK, tcell.ModCtrl)
+	textbox.appstate.Keymap.Dispatch(tcell.NewEventKey(tcell.KeyRune, 'x', tcell.ModNone), textbox.appstate.Commands)
+	if textbox.Content() != "hello world" {
+		t.Fatalf("Expected \"hello world\", instead \"%v\"", textbox.Content())
+	}
+	if _, _, ok := textbox.Selection(); ok {
+		t.Fatalf("Expected no selection after a cancelled sequence")
+	}
+}
+
+func TestTextboxTabs(t *testing.T) {
+	textbox, screen := newTestTextbox(t, "a\tb\n\tc\nabcdefghij", false)
+	textbox.appstate.Options.TabWidth = 4
+
+	// Tabs are expanded to the next tab stop
+	rows := textboxRows(textbox, screen)
+	if rows[0] != "a   b" || rows[1] != "    c" {
+		t.Fatalf("Expected tabs to be expanded, instead:\n" + strings.Join(rows, "\n"))
+	}
+
+	// The cursor is shown after the tab
+	textbox.SetCursorIndex(2)
+	textbox.Draw()
+	if x, y, _ := screen.GetCursor(); x != 4 || y != TEXTBOX_STARTROW {
+		t.Fatalf("Expected cursor at (4, %d), instead (%d, %d)", TEXTBOX_STARTROW, x, y)
+	}
+
+	// Moving up into a tab lands on the tab, and moving back down returns to the remembered column
+	textbox.SetCursorIndex(9)
+	pressKey(textbox, tcell.KeyUp, tcell.ModNone)
+	expectCursorXY(t, textbox, 0, 1)
+	pressKey(textbox, tcell.KeyDown, tcell.ModNone)
+	expectCursorXY(t, textbox, 2, 2)
+}
+
+func TestTextboxReadOnlyAndGoTo(t *testing.T) {
+	textbox, _ := newTestTextbox(t, "one\ntwo\nthree", false)
+	textbox.appstate.ReadOnly = true
+
+	// Positions are clamped to the buffer and line
+	textbox.GoTo(2, 3)
+	expectCursorXY(t, textbox, 2, 1)
+	textbox.GoTo(9, 9)
+	expectCursorXY(t, textbox, 5, 2)
+
+	// Edits do nothing
+	textbox.HandleKey(tcell.NewEventKey(tcell.KeyRune, 'x', tcell.ModNone))
+	pressKey(textbox, tcell.KeyBackspace, tcell.ModNone)
+	pressKey(textbox, tcell.KeyCtrlA, tcell.ModCtrl)
+	pressKey(textbox, tcell.KeyDelete, tcell.ModNone)
+	if textbox.Content() != "one\ntwo\nthree" {
+		t.Fatalf("Expected the buffer not to change, instead \"%v\"", textbox.Content())
+	}
+}
+
+// Returns a textbox containing 50 MB of text, with the cursor in the middle.
+func newLargeTextbox(b *testing.B, wordWrap bool) *Textbox {
+	line := "The quick brown fox jumps over the lazy dog.\n"
+	textbox, _ := newTestTextbox(b, strings.Repeat(line, (50 << 20) / len(line)), wordWrap)
+	textbox.SetCursorIndex(textbox.buf.Length() / 2)
+	textbox.Draw()
+	return textbox
+}
+
+// Typing or deleting a character and redrawing, per keystroke. Characters are deleted as they are typed, so that the line doesn't grow.
+func BenchmarkTextboxTypingLargeFile(b *testing.B) {
+	for _, wordWrap := range([]bool{false, true}) {
+		b.Run(fmt.Sprintf("WordWrap=%v", wordWrap), func(b *testing.B) {
+			textbox := newLargeTextbox(b, wordWrap)
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if i % 2 == 0 {
+					textbox.HandleKey(tcell.NewEventKey(tcell.KeyRune, 'x', tcell.ModNone))
+				} else {
+					textbox.Backspace()
+				}
+				textbox.Draw()
+			}
+		})
+	}
+}
+
+// Moving the cursor up or down a line and redrawing, per keystroke.
+func BenchmarkTextboxCursorLargeFile(b *testing.B) {
+	for _, wordWrap := range([]bool{false, true}) {
+		b.Run(fmt.Sprintf("WordWrap=%v", wordWrap), func(b *testing.B) {
+			textbox := newLargeTextbox(b, wordWrap)
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				textbox.MoveRows(1 - 2 * (i % 2))
+				textbox.Draw()
+			}
+		})
+	}
+}
@@ -3,6 +3,7 @@ package tui
 import (
 	"fmt"
 	"github.com/gdamore/tcell/v2"
+	"github.com/Rye123/notepad--/textbuffer"
 	"github.com/Rye123/notepad--/util"
 )
 
@@ -14,10 +15,31 @@ type TitleBar struct {
 	textbox *Textbox
 	drawn bool
 	appstate *util.AppState
+	temporaryTitle string // Title shown when the file is untitled, from the first line of the buffer
 }
 
 func NewTitleBar(appstate *util.AppState, textbox *Textbox) *TitleBar {
-	return &TitleBar{false, textbox, false, appstate}
+	elem := &TitleBar{false, textbox, false, appstate, ""}
+	elem.updateTemporaryTitle()
+	appstate.TextBuffer.Subscribe(elem.bufferChanged)
+	return elem
+}
+
+// Updates the temporary title if `change` was made to the first line of the buffer.
+func (elem *TitleBar) bufferChanged(change textbuffer.Change) {
+	// The text before the change is unchanged, so the change is after the first line if there is a line end before it
+	if line, _ := elem.appstate.TextBuffer.LineCol(change.Index); line > 0 {
+		return
+	}
+	elem.updateTemporaryTitle()
+}
+
+func (elem *TitleBar) updateTemporaryTitle() {
+	title := util.GetTemporaryTitle(elem.appstate.TextBuffer.Line(0))
+	if title != elem.temporaryTitle {
+		elem.temporaryTitle = title
+		elem.drawn = false
+	}
 }
 
 func (elem *TitleBar) Draw() {
@@ -37,16 +59,18 @@ func (elem *TitleBar) Draw() {
 	
 	if len(filename) == 0 {
 		// If filename not set, set temporary title
-		filename = util.GetTemporaryTitle(elem.appstate.TextBuffer.String())
+		filename = elem.temporaryTitle
 		if len(filename) == 0 {
 			filename = "Untitled"
-			elem.appstate.FileModified = false
 		}
 	}
 	
 	if elem.appstate.FileModified {
 		filename = "*" + filename
 	}
+	if elem.appstate.ReadOnly {
+		filename += " [Read-Only]"
+	}
 
 	titleText := "🗒 " + filename + " - " + elem.appstate.AppName
 
@@ -92,6 +116,10 @@ func (elem *TitleBar) Show() {
 	elem.hidden = false
 }
 
+func (elem *TitleBar) Redraw() {
+	elem.drawn = false
+}
+
 func (elem *TitleBar) HandleKey(keyEvent *tcell.EventKey) {
 	return
 }
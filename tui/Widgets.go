@@ -0,0 +1,458 @@
+package tui
+
+import (
+	"strings"
+	"github.com/gdamore/tcell/v2"
+	"github.com/Rye123/notepad--/util"
+)
+
+// Widget: A control laid out in a Dialog. Widgets are stacked vertically, and drawn within the dialog's borders.
+type Widget interface {
+	Height(width int) int // Returns the number of rows needed by the widget, when given `width` columns
+	CanFocus() bool // Returns true if the widget accepts keyboard focus
+	Draw(x, y, width int, focused bool) // Draws the widget with its top-left corner at (x, y)
+	HandleKey(keyEvent *tcell.EventKey) bool // Handles a key event when focused. Returns false if the key was not used.
+}
+
+/* LABEL */
+
+// Label: Static text, wrapped to the width of the dialog.
+type Label struct {
+	text string
+	appstate *util.AppState
+}
+
+func NewLabel(appstate *util.AppState, text string) *Label {
+	return &Label{text, appstate}
+}
+
+func (w *Label) SetText(text string) {
+	w.text = text
+}
+
+func (w *Label) Height(width int) int {
+	return len(wrapString(w.text, width))
+}
+
+func (w *Label) CanFocus() bool {
+	return false
+}
+
+func (w *Label) Draw(x, y, width int, focused bool) {
+	for i, line := range(wrapString(w.text, width)) {
+		drawText(w.appstate.Screen, x, y + i, x + width, y + i, w.appstate.BarStyle, fitString(line, width))
+	}
+}
+
+func (w *Label) HandleKey(keyEvent *tcell.EventKey) bool {
+	return false
+}
+
+/* TEXT INPUT */
+
+// TextInput: A single-line editable text field.
+type TextInput struct {
+	text []rune
+	cursorIndex int
+	leftIndex int // Index of the leftmost visible rune, to allow horizontal scrolling
+	onChange func(text string)
+	appstate *util.AppState
+}
+
+// Returns a text input pre-filled with `initial`, with the cursor at the end.
+func NewTextInput(appstate *util.AppState, initial string) *TextInput {
+	text := []rune(initial)
+	return &TextInput{text, len(text), 0, nil, appstate}
+}
+
+func (w *TextInput) Text() string {
+	return string(w.text)
+}
+
+func (w *TextInput) SetText(text string) {
+	w.text = []rune(text)
+	w.SetCursorIndex(len(w.text))
+}
+
+// Sets a function to be called whenever the text is edited.
+func (w *TextInput) SetOnChange(onChange func(text string)) {
+	w.onChange = onChange
+}
+
+func (w *TextInput) GetCursorIndex() int {
+	return w.cursorIndex
+}
+
+func (w *TextInput) SetCursorIndex(newCursorIndex int) {
+	if newCursorIndex < 0 {
+		newCursorIndex = 0
+	} else if newCursorIndex > len(w.text) {
+		newCursorIndex = len(w.text)
+	}
+	w.cursorIndex = newCursorIndex
+}
+
+func (w *TextInput) Height(width int) int {
+	return 1
+}
+
+func (w *TextInput) CanFocus() bool {
+	return true
+}
+
+func (w *TextInput) Draw(x, y, width int, focused bool) {
+	// Scroll so that the cursor is always visible
+	for w.cursorIndex - w.leftIndex >= width {
+		w.leftIndex++
+	}
+	for w.cursorIndex < w.leftIndex {
+		w.leftIndex--
+	}
+
+	visible := w.text[w.leftIndex:]
+	if len(visible) > width {
+		visible = visible[:width]
+	}
+	drawText(w.appstate.Screen, x, y, x + width, y, w.appstate.ButtonActiveStyle, fitString(string(visible), width))
+
+	if focused {
+		w.appstate.Screen.ShowCursor(x + w.cursorIndex - w.leftIndex, y)
+	}
+}
+
+func (w *TextInput) HandleKey(keyEvent *tcell.EventKey) bool {
+	key, ch := keyEvent.Key(), keyEvent.Rune()
+	edited := false
+
+	switch key {
+	case tcell.KeyLeft:
+		w.SetCursorIndex(w.cursorIndex - 1)
+	case tcell.KeyRight:
+		w.SetCursorIndex(w.cursorIndex + 1)
+	case tcell.KeyHome:
+		w.SetCursorIndex(0)
+	case tcell.KeyEnd:
+		w.SetCursorIndex(len(w.text))
+	case tcell.KeyBackspace, tcell.KeyBackspace2:
+		if w.cursorIndex > 0 {
+			w.text = append(w.text[:w.cursorIndex-1], w.text[w.cursorIndex:]...)
+			w.SetCursorIndex(w.cursorIndex - 1)
+			edited = true
+		}
+	case tcell.KeyDelete:
+		if w.cursorIndex < len(w.text) {
+			w.text = append(w.text[:w.cursorIndex], w.text[w.cursorIndex+1:]...)
+			edited = true
+		}
+	case tcell.KeyRune:
+		if keyEvent.Modifiers() & (tcell.ModAlt | tcell.ModCtrl) != 0 {
+			return false
+		}
+		w.text = append(w.text[:w.cursorIndex], append([]rune{ch}, w.text[w.cursorIndex:]...)...)
+		w.SetCursorIndex(w.cursorIndex + 1)
+		edited = true
+	default:
+		return false
+	}
+
+	if edited && w.onChange != nil {
+		w.onChange(string(w.text))
+	}
+	return true
+}
+
+/* BUTTONS */
+
+// Buttons: A row of buttons. Left/Right selects a button, Enter or the first letter of a label presses it.
+type Buttons struct {
+	labels []string
+	cursorIndex int
+	onPress func(label string)
+	appstate *util.AppState
+}
+
+// Returns a row of buttons. `onPress` is called with the label of the pressed button.
+func NewButtons(appstate *util.AppState, onPress func(label string), labels ...string) *Buttons {
+	return &Buttons{labels, 0, onPress, appstate}
+}
+
+func (w *Buttons) GetCursorIndex() int {
+	return w.cursorIndex
+}
+
+func (w *Buttons) SetCursorIndex(newCursorIndex int) {
+	if newCursorIndex < 0 {
+		newCursorIndex = 0
+	} else if newCursorIndex >= len(w.labels) {
+		newCursorIndex = len(w.labels) - 1
+	}
+	w.cursorIndex = newCursorIndex
+}
+
+// Returns the label of the currently selected button.
+func (w *Buttons) Selected() string {
+	return w.labels[w.cursorIndex]
+}
+
+// Presses the button with the given label.
+func (w *Buttons) Press(label string) {
+	if w.onPress != nil {
+		w.onPress(label)
+	}
+}
+
+func (w *Buttons) Height(width int) int {
+	return 1
+}
+
+func (w *Buttons) CanFocus() bool {
+	return true
+}
+
+func (w *Buttons) Draw(x, y, width int, focused bool) {
+	drawText(w.appstate.Screen, x, y, x + width, y, w.appstate.BarStyle, fitString("", width))
+
+	col := x
+	for i, label := range(w.labels) {
+		text := "[ " + label + " ]"
+		style := w.appstate.ButtonStyle
+		if i == w.cursorIndex {
+			style = w.appstate.ButtonActiveStyle
+			if !focused {
+				style = style.Dim(true)
+			}
+		}
+		drawText(w.appstate.Screen, col, y, col + len([]rune(text)), y, style, text)
+		drawText(w.appstate.Screen, col + 2, y, col + 3, y, style.Underline(true), string([]rune(label)[0]))
+		col += len([]rune(text)) + 1
+	}
+}
+
+func (w *Buttons) HandleKey(keyEvent *tcell.EventKey) bool {
+	key, ch := keyEvent.Key(), keyEvent.Rune()
+
+	switch key {
+	case tcell.KeyLeft:
+		w.SetCursorIndex(w.cursorIndex - 1)
+		return true
+	case tcell.KeyRight:
+		w.SetCursorIndex(w.cursorIndex + 1)
+		return true
+	case tcell.KeyEnter:
+		w.Press(w.Selected())
+		return true
+	case tcell.KeyRune:
+		// Hotkey: first letter of a label
+		for i, label := range(w.labels) {
+			if strings.EqualFold(string([]rune(label)[0]), string(ch)) {
+				w.SetCursorIndex(i)
+				w.Press(label)
+				return true
+			}
+		}
+	}
+	return false
+}
+
+/* CHECKBOX */
+
+// Checkbox: A labelled on/off toggle, toggled with Space.
+type Checkbox struct {
+	label string
+	checked bool
+	onChange func(checked bool)
+	appstate *util.AppState
+}
+
+func NewCheckbox(appstate *util.AppState, label string, checked bool) *Checkbox {
+	return &Checkbox{label, checked, nil, appstate}
+}
+
+func (w *Checkbox) Checked() bool {
+	return w.checked
+}
+
+func (w *Checkbox) SetChecked(checked bool) {
+	w.checked = checked
+	if w.onChange != nil {
+		w.onChange(checked)
+	}
+}
+
+// Sets a function to be called whenever the checkbox is toggled.
+func (w *Checkbox) SetOnChange(onChange func(checked bool)) {
+	w.onChange = onChange
+}
+
+func (w *Checkbox) Height(width int) int {
+	return 1
+}
+
+func (w *Checkbox) CanFocus() bool {
+	return true
+}
+
+func (w *Checkbox) Draw(x, y, width int, focused bool) {
+	box := "[ ] "
+	if w.checked {
+		box = "[x] "
+	}
+	style := w.appstate.BarStyle
+	if focused {
+		style = w.appstate.ButtonActiveStyle
+	}
+	drawText(w.appstate.Screen, x, y, x + width, y, w.appstate.BarStyle, fitString("", width))
+	text := box + w.label
+	if len([]rune(text)) > width {
+		text = fitString(text, width)
+	}
+	drawText(w.appstate.Screen, x, y, x + len([]rune(text)), y, style, text)
+}
+
+func (w *Checkbox) HandleKey(keyEvent *tcell.EventKey) bool {
+	if keyEvent.Key() == tcell.KeyRune && keyEvent.Rune() == ' ' {
+		w.SetChecked(!w.checked)
+		return true
+	}
+	return false
+}
+
+/* LIST */
+
+// List: A scrollable list of items with a selected item.
+type List struct {
+	items []string
+	cursorIndex int
+	topIndex int // Index of the first visible item
+	rows int // Number of visible rows
+	appstate *util.AppState
+}
+
+// Returns a list showing at most `rows` items at once.
+func NewList(appstate *util.AppState, items []string, rows int) *List {
+	if rows > len(items) {
+		rows = len(items)
+	}
+	if rows < 1 {
+		rows = 1
+	}
+	return &List{items, 0, 0, rows, appstate}
+}
+
+func (w *List) GetCursorIndex() int {
+	return w.cursorIndex
+}
+
+func (w *List) SetCursorIndex(newCursorIndex int) {
+	if newCursorIndex >= len(w.items) {
+		newCursorIndex = len(w.items) - 1
+	}
+	if newCursorIndex < 0 {
+		newCursorIndex = 0
+	}
+	w.cursorIndex = newCursorIndex
+
+	// Scroll so that the selected item is visible
+	if w.cursorIndex < w.topIndex {
+		w.topIndex = w.cursorIndex
+	} else if w.cursorIndex >= w.topIndex + w.rows {
+		w.topIndex = w.cursorIndex - w.rows + 1
+	}
+}
+
+// Returns the selected item, or "" if the list is empty.
+func (w *List) Selected() string {
+	if len(w.items) == 0 {
+		return ""
+	}
+	return w.items[w.cursorIndex]
+}
+
+func (w *List) Height(width int) int {
+	return w.rows
+}
+
+func (w *List) CanFocus() bool {
+	return len(w.items) > 0
+}
+
+func (w *List) Draw(x, y, width int, focused bool) {
+	for row := 0; row < w.rows; row++ {
+		text := ""
+		style := w.appstate.BarStyle
+		i := w.topIndex + row
+		if i < len(w.items) {
+			text = w.items[i]
+			if i == w.cursorIndex {
+				style = w.appstate.ButtonActiveStyle
+				if !focused {
+					style = style.Dim(true)
+				}
+			}
+		}
+		drawText(w.appstate.Screen, x, y + row, x + width, y + row, style, fitString(text, width))
+	}
+}
+
+func (w *List) HandleKey(keyEvent *tcell.EventKey) bool {
+	switch keyEvent.Key() {
+	case tcell.KeyUp:
+		w.SetCursorIndex(w.cursorIndex - 1)
+	case tcell.KeyDown:
+		w.SetCursorIndex(w.cursorIndex + 1)
+	case tcell.KeyPgUp:
+		w.SetCursorIndex(w.cursorIndex - w.rows)
+	case tcell.KeyPgDn:
+		w.SetCursorIndex(w.cursorIndex + w.rows)
+	case tcell.KeyHome:
+		w.SetCursorIndex(0)
+	case tcell.KeyEnd:
+		w.SetCursorIndex(len(w.items) - 1)
+	default:
+		return false
+	}
+	return true
+}
+
+/* HELPER FUNCTIONS */
+
+// Splits `s` into lines of at most `width` runes, breaking at spaces where possible.
+func wrapString(s string, width int) []string {
+	lines := make([]string, 0)
+	if width <= 0 {
+		return lines
+	}
+
+	for _, paragraph := range(strings.Split(s, "\n")) {
+		line := []rune(paragraph)
+		for len(line) > width {
+			// Break at the last space that fits
+			cut := width
+			for i := width; i > 0; i-- {
+				if line[i] == ' ' {
+					cut = i
+					break
+				}
+			}
+			lines = append(lines, string(line[:cut]))
+			line = line[cut:]
+			if len(line) > 0 && line[0] == ' ' {
+				line = line[1:]
+			}
+		}
+		lines = append(lines, string(line))
+	}
+	return lines
+}
+
+// Pads or truncates `s` to exactly `width` runes.
+func fitString(s string, width int) string {
+	if width <= 0 {
+		return ""
+	}
+	runes := []rune(s)
+	if len(runes) > width {
+		return string(runes[:width])
+	}
+	return s + strings.Repeat(" ", width - len(runes))
+}
@@ -14,6 +14,7 @@ type TUIElem interface {
 	IsHidden() bool
 	Hide() // Hides the element
 	Show() // Shows the element
+	Redraw() // Forces the element to be redrawn on the next Draw, e.g. after being covered by a prompt
 	HandleKey(keyEvent *tcell.EventKey)
 }
 
@@ -47,3 +48,32 @@ func drawHorizontalLine(screen tcell.Screen, x1, x2, y int, style tcell.Style) {
 		screen.SetContent(col, y, tcell.RuneHLine, nil, style)
 	}
 }
+
+// Draw a box with a border, filling the inside with blanks. If `title` is non-empty, it is drawn on the top border.
+func drawBox(screen tcell.Screen, x1, y1, x2, y2 int, style tcell.Style, title string) {
+	for row := y1; row <= y2; row++ {
+		for col := x1; col <= x2; col++ {
+			ch := ' '
+			switch {
+			case row == y1 && col == x1:
+				ch = tcell.RuneULCorner
+			case row == y1 && col == x2:
+				ch = tcell.RuneURCorner
+			case row == y2 && col == x1:
+				ch = tcell.RuneLLCorner
+			case row == y2 && col == x2:
+				ch = tcell.RuneLRCorner
+			case row == y1 || row == y2:
+				ch = tcell.RuneHLine
+			case col == x1 || col == x2:
+				ch = tcell.RuneVLine
+			}
+			screen.SetContent(col, row, ch, nil, style)
+		}
+	}
+
+	if len(title) > 0 {
+		title = " " + title + " "
+		drawText(screen, x1 + 2, y1, x2 - 1, y1, style, title)
+	}
+}
@@ -0,0 +1,141 @@
+package util
+
+import (
+	"bytes"
+	"fmt"
+	"unicode/utf8"
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/charmap"
+	"golang.org/x/text/encoding/unicode"
+)
+
+// Character encodings
+const ENCODING_UTF8 = "UTF-8"
+const ENCODING_UTF8_BOM = "UTF-8 BOM"
+const ENCODING_UTF16LE = "UTF-16 LE"
+const ENCODING_UTF16BE = "UTF-16 BE"
+const ENCODING_WINDOWS1252 = "Windows-1252"
+const ENCODING_LATIN1 = "ISO-8859-1"
+
+// Supported encodings, in the order they are offered to the user.
+var Encodings = []string{
+	ENCODING_UTF8,
+	ENCODING_UTF8_BOM,
+	ENCODING_UTF16LE,
+	ENCODING_UTF16BE,
+	ENCODING_WINDOWS1252,
+	ENCODING_LATIN1,
+}
+
+// Character used in place of characters that can't be represented in the chosen encoding
+const ENCODING_REPLACEMENT = '?'
+
+var bomUTF8 = []byte{0xEF, 0xBB, 0xBF}
+var bomUTF16LE = []byte{0xFF, 0xFE}
+var bomUTF16BE = []byte{0xFE, 0xFF}
+
+// Returns the single-byte charmap for `name`, or nil if `name` is a Unicode encoding.
+func charmapFor(name string) *charmap.Charmap {
+	switch name {
+	case ENCODING_WINDOWS1252:
+		return charmap.Windows1252
+	case ENCODING_LATIN1:
+		return charmap.ISO8859_1
+	}
+	return nil
+}
+
+// Returns the Unicode encoding for `name`, or nil if `name` is a single-byte encoding. UTF-16 is always written with a BOM.
+func unicodeEncodingFor(name string) encoding.Encoding {
+	switch name {
+	case ENCODING_UTF8:
+		return unicode.UTF8
+	case ENCODING_UTF8_BOM:
+		return unicode.UTF8BOM
+	case ENCODING_UTF16LE:
+		return unicode.UTF16(unicode.LittleEndian, unicode.UseBOM)
+	case ENCODING_UTF16BE:
+		return unicode.UTF16(unicode.BigEndian, unicode.UseBOM)
+	}
+	return nil
+}
+
+// Guesses the encoding of `data` from its byte order mark, or failing that, whether it is valid UTF-8.
+// Data that isn't valid UTF-8 is assumed to be Windows-1252, unless it uses bytes that are undefined in Windows-1252.
+func DetectEncoding(data []byte) string {
+	switch {
+	case bytes.HasPrefix(data, bomUTF8):
+		return ENCODING_UTF8_BOM
+	case bytes.HasPrefix(data, bomUTF16LE):
+		return ENCODING_UTF16LE
+	case bytes.HasPrefix(data, bomUTF16BE):
+		return ENCODING_UTF16BE
+	case utf8.Valid(data):
+		return ENCODING_UTF8
+	}
+
+	for _, b := range(data) {
+		switch b {
+		case 0x81, 0x8D, 0x8F, 0x90, 0x9D:
+			return ENCODING_LATIN1
+		}
+	}
+	return ENCODING_WINDOWS1252
+}
+
+// Decodes `data` from the encoding `name`. Bytes that are invalid in the encoding are decoded as U+FFFD.
+func DecodeText(data []byte, name string) (string, error) {
+	if cm := charmapFor(name); cm != nil {
+		runes := make([]rune, len(data))
+		for i, b := range(data) {
+			runes[i] = cm.DecodeByte(b)
+		}
+		return string(runes), nil
+	}
+
+	enc := unicodeEncodingFor(name)
+	if enc == nil {
+		return "", fmt.Errorf("unsupported encoding %v", name)
+	}
+	decoded, err := enc.NewDecoder().Bytes(data)
+	return string(decoded), err
+}
+
+// Encodes `text` into the encoding `name`. Characters that can't be represented are replaced with ENCODING_REPLACEMENT.
+func EncodeText(text string, name string) ([]byte, error) {
+	if cm := charmapFor(name); cm != nil {
+		encoded := make([]byte, 0, len(text))
+		for _, r := range(text) {
+			b, ok := cm.EncodeRune(r)
+			if !ok {
+				b = ENCODING_REPLACEMENT
+			}
+			encoded = append(encoded, b)
+		}
+		return encoded, nil
+	}
+
+	enc := unicodeEncodingFor(name)
+	if enc == nil {
+		return nil, fmt.Errorf("unsupported encoding %v", name)
+	}
+	return enc.NewEncoder().Bytes([]byte(text))
+}
+
+// Returns the distinct characters in `text` that can't be represented in the encoding `name`, in order of appearance.
+func Unrepresentable(text string, name string) []rune {
+	unrepresentable := make([]rune, 0)
+	cm := charmapFor(name)
+	if cm == nil {
+		return unrepresentable
+	}
+
+	seen := make(map[rune]bool)
+	for _, r := range(text) {
+		if _, ok := cm.EncodeRune(r); !ok && !seen[r] {
+			seen[r] = true
+			unrepresentable = append(unrepresentable, r)
+		}
+	}
+	return unrepresentable
+}
@@ -0,0 +1,104 @@
+package util
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+	"github.com/gdamore/tcell/v2"
+)
+
+func TestDetectEncoding(t *testing.T) {
+	tests := []struct {
+		data []byte
+		encoding string
+	}{
+		{[]byte("plain ascii"), ENCODING_UTF8},
+		{[]byte("caf\xc3\xa9"), ENCODING_UTF8},
+		{[]byte("\xef\xbb\xbfcaf\xc3\xa9"), ENCODING_UTF8_BOM},
+		{[]byte("\xff\xfec\x00a\x00"), ENCODING_UTF16LE},
+		{[]byte("\xfe\xff\x00c\x00a"), ENCODING_UTF16BE},
+		{[]byte("caf\xe9 \x80"), ENCODING_WINDOWS1252},
+		{[]byte("caf\xe9 \x81"), ENCODING_LATIN1},
+	}
+
+	for _, test := range(tests) {
+		if encoding := DetectEncoding(test.data); encoding != test.encoding {
+			t.Fatalf("DetectEncoding(%q): expected %v, instead %v", test.data, test.encoding, encoding)
+		}
+	}
+}
+
+func TestEncodingRoundTrip(t *testing.T) {
+	text := "café €5 «ok»\n"
+	for _, encoding := range(Encodings) {
+		data, err := EncodeText(text, encoding)
+		if err != nil {
+			t.Fatalf("%v: %+v", encoding, err)
+		}
+		if DetectEncoding(data) != encoding && encoding != ENCODING_LATIN1 {
+			t.Fatalf("%v: encoded data detected as %v", encoding, DetectEncoding(data))
+		}
+		decoded, err := DecodeText(data, encoding)
+		if err != nil {
+			t.Fatalf("%v: %+v", encoding, err)
+		}
+
+		// Latin-1 has no euro sign
+		expected := text
+		if encoding == ENCODING_LATIN1 {
+			expected = "café ?5 «ok»\n"
+		}
+		if decoded != expected {
+			t.Fatalf("%v: expected %q, instead %q", encoding, expected, decoded)
+		}
+	}
+}
+
+func TestUnrepresentable(t *testing.T) {
+	text := "naïve – 日本 日"
+	if chars := Unrepresentable(text, ENCODING_UTF16LE); len(chars) != 0 {
+		t.Fatalf("Expected all characters representable in UTF-16, instead %q", string(chars))
+	}
+	if chars := Unrepresentable(text, ENCODING_WINDOWS1252); string(chars) != "日本" {
+		t.Fatalf("Expected \"日本\", instead %q", string(chars))
+	}
+	if chars := Unrepresentable(text, ENCODING_LATIN1); string(chars) != "–日本" {
+		t.Fatalf("Expected \"–日本\", instead %q", string(chars))
+	}
+}
+
+func TestEncodingLoadSave(t *testing.T) {
+	screen := tcell.NewSimulationScreen("")
+	screen.Init()
+	defer screen.Fini()
+
+	// UTF-16 LE with BOM and CRLF
+	filename := filepath.Join(t.TempDir(), "utf16.txt")
+	original := []byte("\xff\xfeh\x00\xe9\x00\r\x00\n\x00")
+	os.WriteFile(filename, original, 0660)
+
+	appstate := InitialiseAppState(screen, filename, Options{LineEndMode: LINE_END_LF, Encoding: ENCODING_UTF8})
+	if appstate.Options.Encoding != ENCODING_UTF16LE {
+		t.Fatalf("Expected UTF-16 LE, instead " + appstate.Options.Encoding)
+	}
+	if appstate.TextBuffer.String() != "hé\n" {
+		t.Fatalf("Expected \"hé\\n\", instead %q", appstate.TextBuffer.String())
+	}
+
+	// Saved back in the same encoding
+	appstate.FileModified = true
+	appstate.Save()
+	data, _ := os.ReadFile(filename)
+	if !bytes.Equal(data, original) {
+		t.Fatalf("Expected %q, instead %q", original, data)
+	}
+
+	// Reopening with another encoding decodes the same bytes differently
+	if err := appstate.Reopen(ENCODING_LATIN1); err != nil {
+		t.Fatalf("%+v", err)
+	}
+	if appstate.Options.Encoding != ENCODING_LATIN1 || appstate.TextBuffer.String() != "ÿþh\x00é\x00\n\x00\n\x00" {
+		t.Fatalf("Expected file decoded as Latin-1, instead %q", appstate.TextBuffer.String())
+	}
+}
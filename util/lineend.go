@@ -0,0 +1,55 @@
+package util
+
+import (
+	"strings"
+)
+
+// Line end modes
+const LINE_END_LF = "LF"
+const LINE_END_CRLF = "CRLF"
+const LINE_END_CR = "CR"
+
+// Returns the line end mode used in `text`, i.e. the most common of LF, CRLF and CR, and whether more than one of them is used. Returns "" if `text` has no line ends.
+func DetectLineEnding(text string) (mode string, mixed bool) {
+	lf, crlf, cr := 0, 0, 0
+	for i := 0; i < len(text); i++ {
+		switch text[i] {
+		case '\r':
+			if i+1 < len(text) && text[i+1] == '\n' {
+				crlf++
+				i++
+			} else {
+				cr++
+			}
+		case '\n':
+			lf++
+		}
+	}
+
+	kinds := 0
+	for _, count := range([]int{lf, crlf, cr}) {
+		if count > 0 {
+			kinds++
+		}
+	}
+
+	switch {
+	case kinds == 0:
+		return "", false
+	case crlf >= lf && crlf >= cr:
+		return LINE_END_CRLF, kinds > 1
+	case lf >= cr:
+		return LINE_END_LF, kinds > 1
+	default:
+		return LINE_END_CR, kinds > 1
+	}
+}
+
+// Converts all line ends in `text` (CRLF or CR) to LF.
+func NormaliseLineEndings(text string) string {
+	if !strings.Contains(text, "\r") {
+		return text
+	}
+	text = strings.ReplaceAll(text, "\r\n", "\n")
+	return strings.ReplaceAll(text, "\r", "\n")
+}
@@ -0,0 +1,77 @@
+package util
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"github.com/gdamore/tcell/v2"
+)
+
+func TestDetectLineEnding(t *testing.T) {
+	tests := []struct {
+		text string
+		mode string
+		mixed bool
+	}{
+		{"", "", false},
+		{"no line ends", "", false},
+		{"a\nb\n", LINE_END_LF, false},
+		{"a\r\nb\r\n", LINE_END_CRLF, false},
+		{"a\rb\r", LINE_END_CR, false},
+		{"a\r\nb\nc\r\n", LINE_END_CRLF, true},
+		{"a\nb\rc\n", LINE_END_LF, true},
+		{"a\rb\rc\n", LINE_END_CR, true},
+	}
+
+	for _, test := range(tests) {
+		mode, mixed := DetectLineEnding(test.text)
+		if mode != test.mode || mixed != test.mixed {
+			t.Fatalf("DetectLineEnding(%q): expected (%q, %v), instead (%q, %v)", test.text, test.mode, test.mixed, mode, mixed)
+		}
+	}
+}
+
+func TestNormaliseLineEndings(t *testing.T) {
+	text := NormaliseLineEndings("a\r\nb\rc\nd\r\r\n")
+	if text != "a\nb\nc\nd\n\n" {
+		t.Fatalf("Expected LF line ends only, instead %q", text)
+	}
+}
+
+func TestLineEndingRoundTrip(t *testing.T) {
+	screen := tcell.NewSimulationScreen("")
+	screen.Init()
+	defer screen.Fini()
+
+	filename := filepath.Join(t.TempDir(), "crlf.txt")
+	os.WriteFile(filename, []byte("one\r\ntwo\r\n"), 0660)
+
+	// Loaded with LF only, and the file's mode
+	appstate := InitialiseAppState(screen, filename, Options{LineEndMode: LINE_END_LF, Encoding: "UTF-8"})
+	if appstate.TextBuffer.String() != "one\ntwo\n" {
+		t.Fatalf("Expected normalised contents, instead %q", appstate.TextBuffer.String())
+	}
+	if appstate.Options.LineEndMode != LINE_END_CRLF {
+		t.Fatalf("Expected CRLF mode, instead " + appstate.Options.LineEndMode)
+	}
+
+	// Saved in the file's mode
+	appstate.History.Insert(appstate.TextBuffer.Length(), "three\n", 0)
+	appstate.FileModified = true
+	appstate.Save()
+	data, _ := os.ReadFile(filename)
+	if string(data) != "one\r\ntwo\r\nthree\r\n" {
+		t.Fatalf("Expected CRLF line ends, instead %q", string(data))
+	}
+
+	// Converting marks the file as modified, and saves in the new mode
+	appstate.SetLineEndMode(LINE_END_CR)
+	if !appstate.FileModified {
+		t.Fatalf("Expected file to be modified after converting line ends")
+	}
+	appstate.Save()
+	data, _ = os.ReadFile(filename)
+	if string(data) != "one\rtwo\rthree\r" {
+		t.Fatalf("Expected CR line ends, instead %q", string(data))
+	}
+}
@@ -0,0 +1,85 @@
+package util
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Position: A line and column to go to, as typed into Go To Line or given on the command line.
+type Position struct {
+	Line int // 1-based line; an offset from the current line if Relative, or a percentage if Percent
+	Col int // 1-based column, or 0 if not given
+	Relative bool // True for +N or -N: N lines after or before the current line
+	Percent bool // True for N%: N percent of the way through the file
+}
+
+// Parses LINE, LINE:COL, +N or -N (relative to the current line, optionally followed by :COL), or N% (from 0% to 100%).
+func ParsePosition(s string) (Position, error) {
+	s = strings.TrimSpace(s)
+	if percent, ok := strings.CutSuffix(s, "%"); ok {
+		line, err := strconv.Atoi(strings.TrimSpace(percent))
+		if err != nil || line < 0 || line > 100 {
+			return Position{}, fmt.Errorf("expected a percentage from 0%% to 100%%")
+		}
+		return Position{line, 0, false, true}, nil
+	}
+
+	lineString, colString, hasCol := strings.Cut(s, ":")
+	var pos Position
+	var err error
+	if strings.HasPrefix(lineString, "+") || strings.HasPrefix(lineString, "-") {
+		pos.Relative = true
+		pos.Line, err = strconv.Atoi(lineString)
+		if err != nil {
+			return Position{}, fmt.Errorf("expected a number of lines after %v", lineString[:1])
+		}
+	} else {
+		pos.Line, err = strconv.Atoi(lineString)
+		if err != nil || pos.Line < 1 {
+			return Position{}, fmt.Errorf("expected a line number of 1 or more")
+		}
+	}
+	if hasCol {
+		pos.Col, err = strconv.Atoi(colString)
+		if err != nil || pos.Col < 1 {
+			return Position{}, fmt.Errorf("expected a column number of 1 or more")
+		}
+	}
+	return pos, nil
+}
+
+// Returns the 1-based line and column the position refers to, in a file of `lineCount` lines with the cursor on `currentLine`.
+// Returns an error if the line is outside the file.
+func (pos Position) Resolve(currentLine, lineCount int) (line int, col int, err error) {
+	switch {
+	case pos.Percent:
+		line = 1 + (lineCount - 1) * pos.Line / 100
+	case pos.Relative:
+		line = currentLine + pos.Line
+	default:
+		line = pos.Line
+	}
+	if line < 1 {
+		return 0, 0, fmt.Errorf("line %d is before the start of the file", line)
+	}
+	if line > lineCount {
+		return 0, 0, fmt.Errorf("line %d is past the end of the file, whose last line is %d", line, lineCount)
+	}
+	return line, pos.Col, nil
+}
+
+// Returns the position as it is parsed, e.g. "12:4", "+5" or "50%".
+func (pos Position) String() string {
+	if pos.Percent {
+		return fmt.Sprintf("%d%%", pos.Line)
+	}
+	s := strconv.Itoa(pos.Line)
+	if pos.Relative && pos.Line >= 0 {
+		s = "+" + s
+	}
+	if pos.Col > 0 {
+		s += fmt.Sprintf(":%d", pos.Col)
+	}
+	return s
+}
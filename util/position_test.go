@@ -0,0 +1,66 @@
+package util
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParsePosition(t *testing.T) {
+	tests := []struct {
+		s string
+		expected Position
+		line int // Resolved with the cursor on line 10 of 101
+		col int
+	}{
+		{"12", Position{12, 0, false, false}, 12, 0},
+		{" 12:4 ", Position{12, 4, false, false}, 12, 4},
+		{"+5", Position{5, 0, true, false}, 15, 0},
+		{"-9:2", Position{-9, 2, true, false}, 1, 2},
+		{"+0", Position{0, 0, true, false}, 10, 0},
+		{"50%", Position{50, 0, false, true}, 51, 0},
+		{"0%", Position{0, 0, false, true}, 1, 0},
+		{"100%", Position{100, 0, false, true}, 101, 0},
+	}
+	for _, test := range(tests) {
+		pos, err := ParsePosition(test.s)
+		if err != nil || pos != test.expected {
+			t.Fatalf("Expected %+v for %q, instead %+v, %v", test.expected, test.s, pos, err)
+		}
+		line, col, err := pos.Resolve(10, 101)
+		if err != nil || line != test.line || col != test.col {
+			t.Fatalf("Expected %q to resolve to %d:%d, instead %d:%d, %v", test.s, test.line, test.col, line, col, err)
+		}
+		if strings.TrimSpace(test.s) != pos.String() {
+			t.Fatalf("Expected %+v as a string to be %q, instead %q", pos, test.s, pos.String())
+		}
+	}
+}
+
+func TestParsePositionErrors(t *testing.T) {
+	tests := []struct {
+		s string
+		expected string // Part of the error message
+	}{
+		{"", "line number"},
+		{"0", "line number"},
+		{"abc", "line number"},
+		{"3:0", "column number"},
+		{"+x", "number of lines after +"},
+		{"101%", "percentage"},
+		{"-5%", "percentage"},
+	}
+	for _, test := range(tests) {
+		_, err := ParsePosition(test.s)
+		if err == nil || !strings.Contains(err.Error(), test.expected) {
+			t.Fatalf("Expected error containing %q for %q, instead %v", test.expected, test.s, err)
+		}
+	}
+
+	// Lines outside the file
+	for _, s := range([]string{"102", "-10", "+92"}) {
+		pos, _ := ParsePosition(s)
+		if _, _, err := pos.Resolve(10, 101); err == nil || !strings.Contains(err.Error(), "the file") {
+			t.Fatalf("Expected %q to be outside the file, instead %v", s, err)
+		}
+	}
+}
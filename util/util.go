@@ -2,41 +2,66 @@
 package util
 
 import (
+	"fmt"
 	"os"
+	"path/filepath"
+	"time"
 	"strings"
 	"log"
 	"errors"
 	"github.com/gdamore/tcell/v2"
 	"github.com/Rye123/notepad--/textbuffer"
+	"github.com/Rye123/notepad--/clipboard"
+	"github.com/Rye123/notepad--/command"
 )
 
 const APP_NAME = "Notepad--"
+const APP_VERSION = "0.2.0"
+
+// Width of a tab stop, in cells, if the options don't give one
+const DEFAULT_TAB_WIDTH = 8
 
 // Options for Notepad--
 type Options struct {
 	LineEndMode string
 	Encoding string
+	DefaultEncoding string // Encoding of new files, and of files that don't exist yet; ENCODING_UTF8 if ""
 	WordWrap bool
+	HideStatusBar bool
+	TabWidth int // Width of a tab stop, in cells; DEFAULT_TAB_WIDTH if 0
+	Buffer string // Kind of TextBuffer the text is stored in, one of textbuffer.Kinds; a GapBuffer if ""
 }
 
 func (opt *Options) LineEndModeString() string {
 	switch opt.LineEndMode {
-	case "LF":
+	case LINE_END_LF:
 		return "Unix (LF)"
-	case "CRLF":
+	case LINE_END_CRLF:
 		return "Windows (CRLF)"
+	case LINE_END_CR:
+		return "Macintosh (CR)"
 	default:
 		return "Windows (CRLF)"
 	}
 }
 
+// Returns the encoding of new files.
+func (opt *Options) NewFileEncoding() string {
+	if opt.DefaultEncoding == "" {
+		return ENCODING_UTF8
+	}
+	return opt.DefaultEncoding
+}
+
 // Returns the line-end character.
 func (opt *Options) LE() string {
 	switch opt.LineEndMode {
-	case "LF":
+	case LINE_END_LF:
 		return "\n"
-	case "CRLF":
+	case LINE_END_CRLF:
 		return "\r\n"
+	case LINE_END_CR:
+		return "\r"
 	default:
 		return "\r\n"
 	}
@@ -47,11 +72,20 @@ type AppState struct {
 	Filename string
 	FileModified bool
 	Screen tcell.Screen
-	TextBuffer textbuffer.TextBuffer
+	TextBuffer *textbuffer.Observed // Subscribe to it to be notified of changes to the text
+	History *textbuffer.History // Undo/redo history of TextBuffer; all edits should go through this
+	MixedLineEnds bool // True if the file had more than one kind of line end when loaded
+	ReadOnly bool // True if the buffer can't be edited
+	Clipboard clipboard.Clipboard
+	Commands *command.Registry // Every command that can be bound to keys or shown in menus
+	Keymap *command.Keymap
 	BarStyle tcell.Style
 	TextboxStyle tcell.Style
 	ButtonStyle tcell.Style
 	ButtonActiveStyle tcell.Style
+	SelectionStyle tcell.Style
+	HighlightStyle tcell.Style // Search matches
+	DisabledStyle tcell.Style
 	Options Options
 }
 
@@ -60,22 +94,26 @@ func InitialiseAppState(screen tcell.Screen, filename string, options Options) *
 	screen.SetStyle(defaultStyle)
 	screen.SetCursorStyle(tcell.CursorStyleDefault)
 
-	textbuffer := textbuffer.NewGapBuffer()
+	buffer := textbuffer.NewObserved(textbuffer.New(options.Buffer))
+	mixed := false
 
 	// Read file, if given
 	if len(filename) > 0 {
-		data, err := os.ReadFile(filename)
+		initialText, encodingName, mode, isMixed, err := readFile(filename, "")
 		if err != nil {
-			// Report if it's an error unrelated to the file not existing
-			if !errors.Is(err, os.ErrNotExist) {
-				log.Fatalf("%+v", err)
-			}
-		} else {
-			initialText := string(data)
-			// Load into buffer
-			if len(initialText) > 0 {
-				textbuffer.Append(initialText)
-			}
+			log.Fatalf("%+v", err)
+		}
+		if encodingName != "" {
+			options.Encoding = encodingName
+		}
+		if mode != "" {
+			options.LineEndMode = mode
+			mixed = isMixed
+		}
+
+		// Load into buffer
+		if len(initialText) > 0 {
+			buffer.Append(initialText)
 		}
 	}
 
@@ -84,17 +122,32 @@ func InitialiseAppState(screen tcell.Screen, filename string, options Options) *
 		Filename: filename,
 		FileModified: false,
 		Screen: screen,
-		TextBuffer: textbuffer,
+		TextBuffer: buffer,
+		History: textbuffer.NewHistory(buffer),
+		MixedLineEnds: mixed,
+		Clipboard: clipboard.NewInternal(),
+		Commands: command.NewRegistry(),
+		Keymap: command.DefaultKeymap(),
 		BarStyle: defaultStyle,
 		TextboxStyle: defaultStyle,
 		ButtonStyle: defaultStyle,
 		ButtonActiveStyle: defaultStyle.Reverse(true),
+		SelectionStyle: defaultStyle.Reverse(true),
+		HighlightStyle: defaultStyle.Background(tcell.ColorOlive).Foreground(tcell.ColorBlack),
+		DisabledStyle: defaultStyle.Foreground(tcell.ColorGray),
 		Options: options,
 	}
+	buffer.Subscribe(func(textbuffer.Change) { appstate.updateModified() })
 
 	return &appstate
 }
 
+// Updates whether the file is modified, after a change to the textbuffer. An untitled file that is empty is never modified, so it can be closed without a prompt.
+func (appstate *AppState) updateModified() {
+	untitledEmpty := appstate.Filename == "" && appstate.TextBuffer.Length() == 0
+	appstate.FileModified = !appstate.History.IsSaved() && !untitledEmpty
+}
+
 // Saves the current textbuffer to disk. An error is returned if the save was unsuccessful. Note that saving an unmodified file is considered a "success".
 func (appstate *AppState) Save() error{
 	if !appstate.FileModified {
@@ -103,25 +156,231 @@ func (appstate *AppState) Save() error{
 	
 	err := os.WriteFile(
 		appstate.Filename,
-		[]byte(appstate.TextBuffer.String()),
+		appstate.fileContents(),
 		0660, // R/W for Owner and Group
 	)
 	
 	if err == nil {
 		appstate.FileModified = false
+		appstate.History.MarkSaved()
 	}
 	
 	return err
 }
 
-// 
+// Saves the current textbuffer to `filename`, which becomes the new filename of the app. Unlike Save, the textbuffer is written even if it is unmodified. On failure, the filename is left unchanged.
+func (appstate *AppState) SaveAs(filename string) error {
+	oldFilename, oldModified := appstate.Filename, appstate.FileModified
+	appstate.Filename = filename
+	appstate.FileModified = true
+
+	err := appstate.Save()
+	if err != nil {
+		appstate.Filename, appstate.FileModified = oldFilename, oldModified
+	}
+	return err
+}
+
+// Writes the current textbuffer to a recovery file, without changing the filename or modified state. The recovery file is placed next to the original file if possible, otherwise in the temporary directory. Returns the path of the recovery file.
+func (appstate *AppState) WriteRecovery() (string, error) {
+	timestamp := time.Now().Format("20060102-150405")
+	candidates := make([]string, 0)
+	if appstate.Filename != "" {
+		candidates = append(candidates, appstate.Filename + "." + timestamp + ".recovered")
+	}
+	candidates = append(candidates, filepath.Join(os.TempDir(), "notepad--." + timestamp + ".recovered"))
+
+	var err error
+	for _, recoveryFilename := range(candidates) {
+		err = os.WriteFile(recoveryFilename, appstate.fileContents(), 0600)
+		if err == nil {
+			return recoveryFilename, nil
+		}
+	}
+	return "", err
+}
+
+// Returns the contents of the textbuffer as they are to be written to disk, in the chosen line end mode and encoding.
+func (appstate *AppState) fileContents() []byte {
+	text := appstate.TextBuffer.String()
+	if le := appstate.Options.LE(); le != "\n" {
+		text = strings.ReplaceAll(text, "\n", le)
+	}
+
+	data, err := EncodeText(text, appstate.Options.Encoding)
+	if err != nil {
+		// Unknown encodings are written as UTF-8 rather than losing the contents
+		return []byte(text)
+	}
+	return data
+}
+
+// Decodes the contents of a file from the encoding `encodingName`. Returns the text with LF line ends, along with the line end mode of the file.
+func decodeFileContents(data []byte, encodingName string) (text string, lineEndMode string, mixed bool, err error) {
+	text, err = DecodeText(data, encodingName)
+	if err != nil {
+		return "", "", false, err
+	}
+
+	// Line ends are stored as LF, and converted back on save
+	lineEndMode, mixed = DetectLineEnding(text)
+	return NormaliseLineEndings(text), lineEndMode, mixed, nil
+}
+
+// Reads `filename` and decodes it from the encoding `encodingName`, or the detected encoding if it is "". A file that doesn't exist is read as empty, with no encoding or line end mode.
+func readFile(filename string, encodingName string) (text string, encoding string, lineEndMode string, mixed bool, err error) {
+	data, err := os.ReadFile(filename)
+	if errors.Is(err, os.ErrNotExist) {
+		return "", "", "", false, nil
+	} else if err != nil {
+		return "", "", "", false, err
+	}
+
+	if encodingName == "" {
+		encodingName = DetectEncoding(data)
+	}
+	text, lineEndMode, mixed, err = decodeFileContents(data, encodingName)
+	return text, encodingName, lineEndMode, mixed, err
+}
+
+// Replaces the textbuffer with an empty, untitled file. Any unsaved changes and undo history are discarded.
+func (appstate *AppState) New() {
+	appstate.History.Clear()
+	appstate.TextBuffer.Clear()
+	appstate.Filename = ""
+	appstate.Options.Encoding = appstate.Options.NewFileEncoding()
+	appstate.MixedLineEnds = false
+	appstate.FileModified = false
+}
+
+// Replaces the textbuffer with the contents of `filename`, which becomes the new filename of the app. A file that doesn't exist is opened as empty, and created when saved. Any unsaved changes and undo history are discarded.
+func (appstate *AppState) Open(filename string) error {
+	return appstate.OpenWithEncoding(filename, "")
+}
+
+// Opens `filename` like Open, decoding it from the encoding `encodingName` instead of detecting it. A file that doesn't exist is created in that encoding when saved.
+func (appstate *AppState) OpenWithEncoding(filename string, encodingName string) error {
+	text, encodingName, mode, mixed, err := readFile(filename, encodingName)
+	if err != nil {
+		return err
+	}
+	if encodingName == "" {
+		encodingName = appstate.Options.NewFileEncoding()
+	}
+	appstate.load(text, filename, encodingName, mode, mixed)
+	appstate.FileModified = false
+	return nil
+}
+
+// Replaces the textbuffer with `data`, decoded from the encoding `encodingName` or the detected encoding if it is "", as an untitled file. The file is considered modified if `data` isn't empty, since it hasn't been saved anywhere.
+func (appstate *AppState) OpenData(data []byte, encodingName string) error {
+	if encodingName == "" {
+		encodingName = DetectEncoding(data)
+	}
+	text, mode, mixed, err := decodeFileContents(data, encodingName)
+	if err != nil {
+		return err
+	}
+	appstate.load(text, "", encodingName, mode, mixed)
+	if len(data) > 0 {
+		appstate.History.MarkModified()
+		appstate.FileModified = true
+	}
+	return nil
+}
+
+// Replaces the textbuffer with `text`, discarding the undo history. The history is cleared first, so that replacing the text isn't counted as a modification.
+func (appstate *AppState) load(text string, filename string, encodingName string, lineEndMode string, mixed bool) {
+	appstate.History.Clear()
+	appstate.TextBuffer.Clear()
+	appstate.TextBuffer.Append(text)
+	appstate.Filename = filename
+	appstate.Options.Encoding = encodingName
+	if lineEndMode != "" {
+		appstate.Options.LineEndMode = lineEndMode
+	}
+	appstate.MixedLineEnds = mixed
+}
+
+// Reloads the file from disk, decoding it with the encoding `encodingName`. Any unsaved changes and undo history are discarded.
+func (appstate *AppState) Reopen(encodingName string) error {
+	data, err := os.ReadFile(appstate.Filename)
+	if err != nil {
+		return err
+	}
+	text, mode, mixed, err := decodeFileContents(data, encodingName)
+	if err != nil {
+		return err
+	}
+
+	appstate.load(text, appstate.Filename, encodingName, mode, mixed)
+	appstate.FileModified = false
+	return nil
+}
+
+// Returns the characters in the textbuffer that can't be represented in the chosen encoding.
+func (appstate *AppState) Unrepresentable() []rune {
+	return Unrepresentable(appstate.TextBuffer.String(), appstate.Options.Encoding)
+}
+
+// Changes the line end mode used when saving. The file is considered modified if the mode changes.
+func (appstate *AppState) SetLineEndMode(mode string) {
+	if mode == appstate.Options.LineEndMode && !appstate.MixedLineEnds {
+		return
+	}
+	appstate.Options.LineEndMode = mode
+	appstate.MixedLineEnds = false
+	appstate.History.MarkModified()
+	appstate.FileModified = true
+}
+
+// Returns an error if `filename` cannot be written to, i.e. if its directory doesn't exist or isn't writable.
+func CheckWritable(filename string) error {
+	dir := filepath.Dir(filename)
+	info, err := os.Stat(dir)
+	if err != nil {
+		return err
+	}
+	if !info.IsDir() {
+		return fmt.Errorf("%v is not a directory", dir)
+	}
+
+	// Existing files only need to be writable themselves
+	if file, err := os.OpenFile(filename, os.O_WRONLY, 0); err == nil {
+		return file.Close()
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+
+	// Otherwise, check that a file can be created in the directory
+	tmp, err := os.CreateTemp(dir, ".notepad--*")
+	if err != nil {
+		return fmt.Errorf("cannot write to %v: %w", dir, errors.Unwrap(err))
+	}
+	tmp.Close()
+	return os.Remove(tmp.Name())
+}
+
+// Longest temporary title, in characters
+const TEMPORARY_TITLE_LENGTH = 45
 
 // Used to generate a temporary title if no file was used
 func GetTemporaryTitle(content string) string {
 	title, _, _ := strings.Cut(content, "\n")
 	title = strings.TrimSpace(title)
-	if len(title) > 45 {
-		title = title[:45]
+	if runes := []rune(title); len(runes) > TEMPORARY_TITLE_LENGTH {
+		title = strings.TrimSpace(string(runes[:TEMPORARY_TITLE_LENGTH]))
 	}
 	return title
 }
+
+// Returns the filename suggested for saving an untitled file, from its temporary title without the characters that are invalid in filenames. "" if there is nothing left.
+func GetTemporaryFilename(content string) string {
+	filename := strings.Map(func(ch rune) rune {
+		if ch < ' ' || ch == 0x7f || strings.ContainsRune(`/\:*?"<>|`, ch) {
+			return -1
+		}
+		return ch
+	}, GetTemporaryTitle(content))
+	return strings.Trim(filename, " .")
+}
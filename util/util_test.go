@@ -0,0 +1,114 @@
+package util
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"unicode/utf8"
+	"github.com/gdamore/tcell/v2"
+)
+
+func TestFileModifiedByChanges(t *testing.T) {
+	screen := tcell.NewSimulationScreen("")
+	screen.Init()
+	defer screen.Fini()
+
+	filename := filepath.Join(t.TempDir(), "notes.txt")
+	appstate := InitialiseAppState(screen, filename, Options{LineEndMode: LINE_END_LF, Encoding: ENCODING_UTF8})
+	appstate.History.Insert(0, "abc", 0)
+	if !appstate.FileModified {
+		t.Fatalf("Expected the file to be modified after an edit")
+	}
+	appstate.History.Undo()
+	if appstate.FileModified {
+		t.Fatalf("Expected the file to be unmodified after undoing the edit")
+	}
+	appstate.History.Redo()
+	if err := appstate.Save(); err != nil || appstate.FileModified {
+		t.Fatalf("Expected the file to be saved, instead %v, %v", err, appstate.FileModified)
+	}
+	appstate.History.Delete(0, 3, 3)
+	if !appstate.FileModified {
+		t.Fatalf("Expected the file to be modified after deleting everything")
+	}
+
+	// An untitled file that is empty is never modified
+	appstate.New()
+	appstate.History.Insert(0, "x", 0)
+	appstate.History.Delete(0, 1, 1)
+	if appstate.FileModified {
+		t.Fatalf("Expected an empty untitled file to be unmodified")
+	}
+}
+
+func TestOpenAndNew(t *testing.T) {
+	screen := tcell.NewSimulationScreen("")
+	screen.Init()
+	defer screen.Fini()
+
+	dir := t.TempDir()
+	appstate := InitialiseAppState(screen, "", Options{LineEndMode: LINE_END_LF, Encoding: ENCODING_UTF8})
+	appstate.History.Insert(0, "unsaved", 0)
+	appstate.FileModified = true
+
+	// Opening replaces the buffer, detecting the encoding and line ends
+	filename := filepath.Join(dir, "latin1.txt")
+	os.WriteFile(filename, []byte("caf\xe9\r\n"), 0660)
+	if err := appstate.Open(filename); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if appstate.TextBuffer.String() != "café\n" || appstate.Filename != filename || appstate.FileModified {
+		t.Fatalf("Unexpected state after open: %q, %v, %v", appstate.TextBuffer.String(), appstate.Filename, appstate.FileModified)
+	}
+	if appstate.Options.Encoding != ENCODING_WINDOWS1252 || appstate.Options.LineEndMode != LINE_END_CRLF {
+		t.Fatalf("Unexpected options after open: %+v", appstate.Options)
+	}
+	if appstate.History.CanUndo() {
+		t.Fatalf("Expected history to be cleared")
+	}
+
+	// A file that doesn't exist is opened as empty
+	missing := filepath.Join(dir, "missing.txt")
+	if err := appstate.Open(missing); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if appstate.TextBuffer.String() != "" || appstate.Filename != missing || appstate.Options.Encoding != ENCODING_UTF8 {
+		t.Fatalf("Unexpected state after opening a missing file: %q, %v", appstate.TextBuffer.String(), appstate.Filename)
+	}
+
+	// Directories can't be opened
+	if err := appstate.Open(dir); err == nil {
+		t.Fatalf("Expected an error opening a directory")
+	}
+
+	appstate.History.Insert(0, "text", 0)
+	appstate.New()
+	if appstate.TextBuffer.String() != "" || appstate.Filename != "" || appstate.FileModified {
+		t.Fatalf("Unexpected state after new: %q, %v, %v", appstate.TextBuffer.String(), appstate.Filename, appstate.FileModified)
+	}
+}
+
+func TestTemporaryTitle(t *testing.T) {
+	tests := []struct {
+		content string
+		title string
+		filename string
+	}{
+		{"  hello world \nmore", "hello world", "hello world"},
+		{"\nhello", "", ""},
+		{strings.Repeat("é", 50), strings.Repeat("é", 45), strings.Repeat("é", 45)},
+		{strings.Repeat("ab ", 14) + "日本語です", strings.Repeat("ab ", 14) + "日本語", strings.Repeat("ab ", 14) + "日本語"},
+		{"a/b: c?*<d>|\"e\"\\f", "a/b: c?*<d>|\"e\"\\f", "ab cdef"},
+		{"../..", "../..", ""},
+	}
+	for _, test := range(tests) {
+		title, filename := GetTemporaryTitle(test.content), GetTemporaryFilename(test.content)
+		if title != test.title || filename != test.filename {
+			t.Fatalf("Expected %q and %q for %q, instead %q and %q", test.title, test.filename, test.content, title, filename)
+		}
+		if !utf8.ValidString(title) {
+			t.Fatalf("Expected a valid title for %q, instead %q", test.content, title)
+		}
+	}
+}